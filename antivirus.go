@@ -0,0 +1,61 @@
+package yadloader
+
+import (
+	"fmt"
+	"strings"
+)
+
+// AntivirusPolicy decides what happens to files the Yandex.Disk antivirus
+// reports as infected.
+type AntivirusPolicy int
+
+const (
+	// AntivirusAllow downloads infected files like any other.
+	AntivirusAllow AntivirusPolicy = iota
+	// AntivirusWarn downloads them and logs a warning.
+	AntivirusWarn
+	// AntivirusSkip leaves them out with EventSkipped.
+	AntivirusSkip
+)
+
+var antivirusNames = map[AntivirusPolicy]string{
+	AntivirusAllow: "allow",
+	AntivirusWarn:  "warn",
+	AntivirusSkip:  "skip",
+}
+
+func (p AntivirusPolicy) String() string {
+	if s, ok := antivirusNames[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("AntivirusPolicy(%d)", int(p))
+}
+
+func ParseAntivirusPolicy(s string) (AntivirusPolicy, error) {
+	for p, name := range antivirusNames {
+		if strings.EqualFold(s, name) {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown antivirus policy %q", s)
+}
+
+// Infected reports whether the Yandex.Disk antivirus has flagged the file.
+func (f DiskFile) Infected() bool {
+	return f.AntivirusStatus == "infected"
+}
+
+// skipInfected applies Config.Antivirus to file.
+func (c *YaDiskClient) skipInfected(file DiskFile) bool {
+	if !file.Infected() {
+		return false
+	}
+	switch c.config.Antivirus {
+	case AntivirusSkip:
+		c.logf("skipping %s, reported infected", file.Path)
+		return true
+	case AntivirusWarn:
+		c.logf("warning: %s is reported infected", file.Path)
+	}
+	return false
+}
@@ -0,0 +1,109 @@
+package yadloader
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+var (
+	ErrNotFound        = errors.New("yadloader: resource not found")
+	ErrRateLimited     = errors.New("yadloader: rate limited")
+	ErrResourceBlocked = errors.New("yadloader: resource blocked")
+)
+
+// APIError is an error answer of the Yandex Disk API, e.g.
+// {"error": "DiskNotFoundError", "message": "...", "description": "..."}.
+// It matches ErrNotFound, ErrRateLimited and ErrResourceBlocked with
+// errors.Is.
+type APIError struct {
+	// Method and URL are the request that failed.
+	Method     string `json:"-"`
+	URL        string `json:"-"`
+	StatusCode int    `json:"-"`
+	// Body is an excerpt of the response when it is not an API error
+	// object, such as an HTML page from a proxy.
+	Body        string `json:"-"`
+	Code        string `json:"error"`
+	Message     string `json:"message"`
+	Description string `json:"description"`
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = e.Description
+	}
+	if e.Code != "" {
+		msg = e.Code + ": " + msg
+	}
+	if e.Body != "" {
+		msg += fmt.Sprintf(" (body: %q)", e.Body)
+	}
+	return fmt.Sprintf("yandex disk: %s %s: %d %s", cmp.Or(e.Method, "GET"), e.URL, e.StatusCode, msg)
+}
+
+func (e *APIError) Is(target error) bool {
+	switch target {
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrRateLimited:
+		return e.StatusCode == http.StatusTooManyRequests
+	case ErrResourceBlocked:
+		return e.StatusCode == http.StatusLocked ||
+			strings.HasSuffix(e.Code, "BlockedError") ||
+			strings.HasSuffix(e.Code, "LockedError") ||
+			e.Code == "DiskResourceDownloadLimitExceededError"
+	}
+	return false
+}
+
+// Temporary reports whether the same request may succeed later.
+func (e *APIError) Temporary() bool {
+	return e.StatusCode == http.StatusTooManyRequests || e.StatusCode >= 500
+}
+
+// maxErrorBody limits how much of an error response is read.
+const maxErrorBody = 64 << 10
+
+// checkResponse turns a non-2xx response into an *APIError. The body is
+// consumed in that case.
+func checkResponse(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	e := &APIError{Method: resp.Request.Method, URL: errorURL(resp.Request.URL), StatusCode: resp.StatusCode}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if json.Unmarshal(body, e) != nil || e.Message == "" && e.Description == "" {
+		e.Message = http.StatusText(resp.StatusCode)
+		e.Body = excerpt(body)
+	}
+	return e
+}
+
+// errorURL drops the long fields parameter from API URLs in messages.
+func errorURL(u *url.URL) string {
+	q := u.Query()
+	if !q.Has("fields") {
+		return u.String()
+	}
+	q.Del("fields")
+	short := *u
+	short.RawQuery = q.Encode()
+	return short.String()
+}
+
+// excerpt shortens a response body for error messages.
+func excerpt(body []byte) string {
+	const max = 200
+	s := strings.Join(strings.Fields(string(body)), " ")
+	if len(s) > max {
+		s = s[:max] + "..."
+	}
+	return s
+}
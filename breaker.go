@@ -0,0 +1,85 @@
+package yadloader
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// breaker pauses all API calls and downloads of a client for a cool-down
+// once threshold calls in a row have failed in a way that points at the
+// service rather than a single file: server errors, rate limiting, blocked
+// resources and network failures. After the pause one more failure opens it
+// again, one success closes it.
+type breaker struct {
+	threshold int
+	cooldown  time.Duration
+	logf      func(format string, v ...any)
+
+	mu        sync.Mutex
+	failures  int
+	openUntil time.Time
+}
+
+// newBreaker returns nil, which never pauses, when threshold is not
+// positive.
+func newBreaker(threshold int, cooldown time.Duration, logf func(string, ...any)) *breaker {
+	if threshold <= 0 {
+		return nil
+	}
+	return &breaker{threshold: threshold, cooldown: cooldown, logf: logf}
+}
+
+// wait blocks while the breaker is open.
+func (b *breaker) wait(ctx context.Context) error {
+	if b == nil {
+		return nil
+	}
+	for {
+		b.mu.Lock()
+		d := time.Until(b.openUntil)
+		b.mu.Unlock()
+		if d <= 0 {
+			return nil
+		}
+		if err := sleep(ctx, d); err != nil {
+			return err
+		}
+	}
+}
+
+// record counts the outcome of a call.
+func (b *breaker) record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err == nil {
+		b.failures = 0
+		return
+	}
+	if !tripsBreaker(err) || time.Now().Before(b.openUntil) {
+		return
+	}
+	if b.failures++; b.failures < b.threshold {
+		return
+	}
+	b.openUntil = time.Now().Add(b.cooldown)
+	b.failures = b.threshold - 1
+	b.logf("%d failures in a row, pausing all requests for %s: %v", b.threshold, b.cooldown, err)
+}
+
+func tripsBreaker(err error) bool {
+	if errors.Is(err, context.Canceled) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary() || errors.Is(err, ErrResourceBlocked)
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded)
+}
@@ -0,0 +1,63 @@
+package yadloader
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ErrRetryBudget fails what would be retried once Config.RetryBudget
+// retries, or Config.RetryBudgetTime of waiting for them, are used up.
+var ErrRetryBudget = errors.New("yadloader: retry budget exhausted")
+
+// retryBudget counts the retries of a client across all of its API calls,
+// download reconnects and retry passes, and the time spent waiting before
+// them. A zero limit is no limit.
+type retryBudget struct {
+	retries int
+	wait    time.Duration
+
+	mu     sync.Mutex
+	used   int
+	waited time.Duration
+}
+
+func newRetryBudget(retries int, wait time.Duration) *retryBudget {
+	return &retryBudget{retries: retries, wait: wait}
+}
+
+// take accounts for n more retries, or returns ErrRetryBudget without
+// taking any if that is more than is left.
+func (b *retryBudget) take(n int) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.retries > 0 && b.used+n > b.retries:
+		return fmt.Errorf("%w after %d retries", ErrRetryBudget, b.used)
+	case b.wait > 0 && b.waited >= b.wait:
+		return fmt.Errorf("%w after %s of waiting to retry", ErrRetryBudget, b.wait)
+	}
+	b.used += n
+	return nil
+}
+
+// pause accounts for waiting d before a retry and returns how long to wait,
+// which is no longer than the time left.
+func (b *retryBudget) pause(d time.Duration) time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.wait > 0 {
+		d = min(d, b.wait-b.waited)
+	}
+	b.waited += d
+	return d
+}
+
+// Retries returns how many retries the client has made so far and how long
+// it has waited before them, counted as for Config.RetryBudget.
+func (c *YaDiskClient) Retries() (int, time.Duration) {
+	c.budget.mu.Lock()
+	defer c.budget.mu.Unlock()
+	return c.budget.used, c.budget.waited
+}
@@ -0,0 +1,93 @@
+package yadloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ListCache keeps tree listings on disk so repeated runs over the same share
+// do not have to crawl the API again. Entries older than TTL are ignored.
+type ListCache struct {
+	Dir string
+	TTL time.Duration
+}
+
+type cacheEntry struct {
+	Created time.Time  `json:"created"`
+	Files   []DiskFile `json:"files"`
+}
+
+// DefaultCacheDir is the yadloader folder in the user's cache directory.
+func DefaultCacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "yadloader"), nil
+}
+
+// CacheKey combines what a listing depends on, such as the link, path and
+// listing options, into a cache key.
+func CacheKey(parts ...string) string {
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c ListCache) filename(key string) string {
+	return filepath.Join(c.Dir, key+".json")
+}
+
+// Get returns the cached listing for key and its age. Download links expire,
+// so they are dropped and fetched again when a file is downloaded. A missing
+// or expired entry is reported with ok false and no error.
+func (c ListCache) Get(key string) (files []DiskFile, age time.Duration, ok bool, err error) {
+	data, err := os.ReadFile(c.filename(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, 0, false, nil
+	} else if err != nil {
+		return nil, 0, false, err
+	}
+	var e cacheEntry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, 0, false, err
+	}
+	age = time.Since(e.Created)
+	if c.TTL > 0 && age > c.TTL {
+		return nil, age, false, nil
+	}
+	for i := range e.Files {
+		e.Files[i].File = ""
+	}
+	return e.Files, age, true, nil
+}
+
+// Put stores files under key, replacing an older entry.
+func (c ListCache) Put(key string, files []DiskFile) error {
+	if err := os.MkdirAll(c.Dir, 0755); err != nil {
+		return err
+	}
+	data, err := json.Marshal(cacheEntry{Created: time.Now(), Files: files})
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(c.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), c.filename(key))
+}
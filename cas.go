@@ -0,0 +1,168 @@
+package yadloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// CASTree is how the download folder of a content-addressable download
+// shows the listing, see Config.CASObjects.
+type CASTree int
+
+const (
+	// CASSymlinks makes every file a relative symbolic link to its object.
+	CASSymlinks CASTree = iota
+	// CASHardlinks makes every file a hard link of its object, which must
+	// be on the same filesystem.
+	CASHardlinks
+	// CASIndex writes CASIndexFile instead, with a line "object  path"
+	// for every file, the object being its path in the object folder.
+	CASIndex
+)
+
+// CASIndexFile is the index CASIndex writes into the download folder.
+const CASIndexFile = "INDEX"
+
+var casTreeNames = map[CASTree]string{
+	CASSymlinks:  "symlink",
+	CASHardlinks: "hardlink",
+	CASIndex:     "index",
+}
+
+func (t CASTree) String() string {
+	if s, ok := casTreeNames[t]; ok {
+		return s
+	}
+	return fmt.Sprintf("CASTree(%d)", int(t))
+}
+
+func ParseCASTree(s string) (CASTree, error) {
+	for t, name := range casTreeNames {
+		if strings.EqualFold(s, name) {
+			return t, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown CAS tree %q", s)
+}
+
+// casObject is the path of the object of file inside the object folder:
+// ab/cdef… by SHA256, or unhashed/ and its path for files listed without
+// one.
+func casObject(file DiskFile) string {
+	sum := strings.ToLower(file.SHA256)
+	if len(sum) < 3 {
+		return path.Join("unhashed", path.Clean("/"+file.Path))
+	}
+	return sum[:2] + "/" + sum[2:]
+}
+
+// downloadCAS downloads every distinct body of files once into the object
+// folder, leaving those already there from earlier runs alone, and then
+// lays out dest as Config.CASTree says. Files whose body is that of an
+// earlier file are reported as skipped.
+func (c *YaDiskClient) downloadCAS(ctx context.Context, files []DiskFile, dest string) (Report, error) {
+	var objects, copies []DiskFile
+	seen := make(map[string]bool, len(files))
+	for _, f := range files {
+		if o := casObject(f); seen[o] {
+			copies = append(copies, f)
+		} else {
+			seen[o] = true
+			objects = append(objects, f)
+		}
+	}
+	store := c.with([]Option{
+		WithOverwrite(OverwriteSkip),
+		WithLayout(casObject),
+		func(cfg *Config) { cfg.CASObjects = "" },
+	})
+	report, err := store.DownloadFiles(ctx, objects, c.config.CASObjects)
+	if lerr := c.casTree(dest, files, &report); err == nil {
+		err = lerr
+	}
+
+	report.Files = len(files)
+	for _, f := range copies {
+		if _, err := os.Stat(longPath(c.objectPath(f))); err != nil {
+			continue
+		}
+		report.Skipped++
+		if c.config.Progress != nil {
+			c.config.Progress(Event{Type: EventSkipped, File: f, Dest: LocalPath(dest, f) + c.config.fileExt()})
+		}
+	}
+	return report, err
+}
+
+// objectPath is where the object of file is stored.
+func (c *YaDiskClient) objectPath(file DiskFile) string {
+	return filepath.Join(c.config.CASObjects, filepath.FromSlash(casObject(file))) + c.config.fileExt()
+}
+
+// casTree links or indexes the files whose objects are stored.
+func (c *YaDiskClient) casTree(dest string, files []DiskFile, report *Report) error {
+	files = slices.SortedFunc(slices.Values(files), func(a, b DiskFile) int { return strings.Compare(a.Path, b.Path) })
+	var (
+		index []string
+		errs  []error
+	)
+	for _, f := range files {
+		object := c.objectPath(f)
+		if _, err := os.Stat(longPath(object)); err != nil {
+			// Failed files have no object to show.
+			continue
+		}
+		name := strings.TrimPrefix(path.Clean("/"+f.Path), "/") + c.config.fileExt()
+		if c.config.CASTree == CASIndex {
+			index = append(index, checksumLine(casObject(f)+c.config.fileExt(), name))
+			report.Linked++
+			continue
+		}
+		if err := c.casLink(object, filepath.Join(dest, filepath.FromSlash(name))); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", f.Path, err))
+			continue
+		}
+		report.Linked++
+	}
+	if c.config.CASTree == CASIndex {
+		if err := os.MkdirAll(dest, c.config.dirMode()); err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dest, CASIndexFile), []byte(strings.Join(index, "")), c.config.fileMode()); err != nil {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// casLink replaces whatever is at name with a link to object.
+func (c *YaDiskClient) casLink(object, name string) error {
+	if err := os.MkdirAll(longPath(filepath.Dir(name)), c.config.dirMode()); err != nil {
+		return err
+	}
+	if err := os.Remove(longPath(name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if c.config.CASTree == CASHardlinks {
+		return os.Link(longPath(object), longPath(name))
+	}
+	abs, err := filepath.Abs(name)
+	if err != nil {
+		return err
+	}
+	if object, err = filepath.Abs(object); err != nil {
+		return err
+	}
+	target, err := filepath.Rel(filepath.Dir(abs), object)
+	if err != nil {
+		target = object
+	}
+	return os.Symlink(target, longPath(name))
+}
@@ -0,0 +1,90 @@
+package yadloader
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ChecksumType selects the hash of checksum files written by
+// WriteChecksums.
+type ChecksumType int
+
+const (
+	ChecksumMD5 ChecksumType = iota
+	ChecksumSHA256
+)
+
+// FileName is the conventional name of the checksum file, MD5SUMS or
+// SHA256SUMS.
+func (t ChecksumType) FileName() string {
+	if t == ChecksumSHA256 {
+		return "SHA256SUMS"
+	}
+	return "MD5SUMS"
+}
+
+func (t ChecksumType) sum(file DiskFile) string {
+	if t == ChecksumSHA256 {
+		return file.SHA256
+	}
+	return file.MD5
+}
+
+func ParseChecksumType(s string) (ChecksumType, error) {
+	switch strings.ToLower(s) {
+	case "md5":
+		return ChecksumMD5, nil
+	case "sha256":
+		return ChecksumSHA256, nil
+	}
+	return 0, fmt.Errorf("unknown checksum type %q", s)
+}
+
+// WriteChecksums writes the API-provided hashes of files, downloaded into
+// dest, in the md5sum/sha256sum format so they can be checked with
+// "sha256sum -c". With perDir every folder gets its own file listing the
+// files in it, otherwise one file in dest lists them all by relative path.
+// Files the API has no hash for are left out. It returns the files written.
+func WriteChecksums(dest string, files []DiskFile, t ChecksumType, perDir bool) ([]string, error) {
+	files = slices.Clone(files)
+	slices.SortFunc(files, func(a, b DiskFile) int { return strings.Compare(a.Path, b.Path) })
+
+	lines := make(map[string][]string)
+	for _, file := range files {
+		sum := t.sum(file)
+		if sum == "" {
+			continue
+		}
+		name := strings.TrimPrefix(path.Clean("/"+file.Path), "/")
+		dir := "."
+		if perDir {
+			dir, name = path.Split(name)
+		}
+		lines[dir] = append(lines[dir], checksumLine(sum, name))
+	}
+
+	var written []string
+	for dir, l := range lines {
+		filename := filepath.Join(dest, filepath.FromSlash(dir), t.FileName())
+		if err := os.WriteFile(longPath(filename), []byte(strings.Join(l, "")), 0644); err != nil {
+			return written, err
+		}
+		written = append(written, filename)
+	}
+	slices.Sort(written)
+	return written, nil
+}
+
+// checksumLine formats a line the way coreutils does, escaping names that
+// contain a backslash or newline.
+func checksumLine(sum, name string) string {
+	if strings.ContainsAny(name, "\\\n") {
+		name = strings.NewReplacer("\\", "\\\\", "\n", "\\n").Replace(name)
+		return "\\" + sum + "  " + name + "\n"
+	}
+	return sum + "  " + name + "\n"
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strings"
+
+	"github.com/brandquad/yadloader-go"
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+)
+
+// keyringService names the tokens of auth login in the system keyring:
+// the Keychain on macOS, the Secret Service on Linux and the Credential
+// Manager on Windows. Each --profile has its own token.
+const keyringService = "yadownload"
+
+func keyringUser(profile string) string {
+	return cmp.Or(profile, "default")
+}
+
+// keyringEntry is what auth login stores: the token and the app it was
+// granted to.
+type keyringEntry struct {
+	yadloader.OAuthToken
+	ClientID     string `json:"client_id,omitempty"`
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// loadEntry reads the entry of profile.
+func loadEntry(profile string) (*keyringEntry, error) {
+	secret, err := keyring.Get(keyringService, keyringUser(profile))
+	if err != nil {
+		return nil, err
+	}
+	e := &keyringEntry{}
+	if err := json.Unmarshal([]byte(secret), e); err != nil {
+		return nil, fmt.Errorf("token of profile %s: %w", keyringUser(profile), err)
+	}
+	return e, nil
+}
+
+func storeEntry(profile string, e *keyringEntry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return keyring.Set(keyringService, keyringUser(profile), string(b))
+}
+
+// keyringToken returns what auth login stored for profile, or nil if there
+// is nothing or no keyring to ask.
+func keyringToken(profile string) *keyringEntry {
+	e, err := loadEntry(profile)
+	if err != nil {
+		return nil
+	}
+	return e
+}
+
+// keyringRefresh returns the option refreshing the token of auth login
+// when it expires, storing the new one, or nil if the token in use is not
+// one auth login got with a refresh token.
+func keyringRefresh(params *Args) yadloader.Option {
+	e := params.Login
+	if e == nil || e.RefreshToken == "" || e.ClientID == "" {
+		return nil
+	}
+	app := &yadloader.OAuthApp{ClientID: e.ClientID, ClientSecret: e.ClientSecret}
+	return yadloader.WithRefreshToken(app, e.OAuthToken, func(t yadloader.OAuthToken) {
+		e.OAuthToken = t
+		if err := storeEntry(params.Profile, e); err != nil {
+			log.Printf(tr("Warning: could not store the refreshed token: %v"), err)
+		}
+	})
+}
+
+// auth runs auth login, logout or status for the --profile of params.
+// login stores --token or $YADISK_TOKEN, signs in to the --client-id app,
+// or asks for the token.
+func auth(params *Args, args []string) int {
+	user := keyringUser(params.Profile)
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, tr("Error: auth takes one of login, logout, status"))
+		return 1
+	}
+	switch args[0] {
+	case "login":
+		e := &keyringEntry{OAuthToken: yadloader.OAuthToken{AccessToken: params.Token}}
+		var err error
+		switch {
+		case params.Token != "":
+		case params.ClientID != "":
+			e, err = oauthLogin(params)
+		default:
+			e.AccessToken, err = readToken()
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, tr("Error: %v\n"), err)
+			return 1
+		}
+		if err := storeEntry(params.Profile, e); err != nil {
+			fmt.Fprintf(os.Stderr, tr("Error: keyring: %v\n"), err)
+			return 1
+		}
+		log.Printf(tr("Stored the token of profile %s in the keyring"), user)
+	case "logout":
+		err := keyring.Delete(keyringService, user)
+		switch {
+		case errors.Is(err, keyring.ErrNotFound):
+			log.Printf(tr("No token of profile %s in the keyring"), user)
+		case err != nil:
+			fmt.Fprintf(os.Stderr, tr("Error: keyring: %v\n"), err)
+			return 1
+		default:
+			log.Printf(tr("Removed the token of profile %s from the keyring"), user)
+		}
+	case "status":
+		e, err := loadEntry(params.Profile)
+		switch {
+		case errors.Is(err, keyring.ErrNotFound):
+			log.Printf(tr("No token of profile %s in the keyring"), user)
+			return 1
+		case err != nil:
+			fmt.Fprintf(os.Stderr, tr("Error: keyring: %v\n"), err)
+			return 1
+		}
+		log.Printf(tr("The keyring holds a token of profile %s"), user)
+		if !e.Expiry.IsZero() {
+			log.Printf(tr("It expires on %s"), e.Expiry.Local().Format("2006-01-02 15:04"))
+		}
+	default:
+		fmt.Fprintln(os.Stderr, tr("Error: auth takes one of login, logout, status"))
+		return 1
+	}
+	return 0
+}
+
+// oauthLogin gets a token for the --client-id app with the device flow: the
+// user enters the code shown in the browser, which is opened if possible.
+func oauthLogin(params *Args) (*keyringEntry, error) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	app := &yadloader.OAuthApp{ClientID: params.ClientID, ClientSecret: params.ClientSecret}
+	dc, err := app.RequestDeviceCode(ctx)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, tr("Open %s and enter the code %s\n"), dc.VerificationURL, dc.UserCode)
+	_ = openBrowser(dc.VerificationURL)
+	token, err := app.PollToken(ctx, dc)
+	if err != nil {
+		return nil, err
+	}
+	return &keyringEntry{OAuthToken: *token, ClientID: app.ClientID, ClientSecret: app.ClientSecret}, nil
+}
+
+// openBrowser opens url with the desktop's browser.
+func openBrowser(url string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		if os.Getenv("DISPLAY") == "" && os.Getenv("WAYLAND_DISPLAY") == "" {
+			return errors.New("no display")
+		}
+		cmd = exec.Command("xdg-open", url)
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go cmd.Wait()
+	return nil
+}
+
+// readToken asks for the token without echoing it, or reads the first
+// line of stdin when it is not a terminal.
+func readToken() (string, error) {
+	var token string
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		fmt.Fprint(os.Stderr, tr("OAuth token: "))
+		b, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", err
+		}
+		token = string(b)
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("reading the token: %w", err)
+		}
+		token = line
+	}
+	if token = strings.TrimSpace(token); token == "" {
+		return "", errors.New("empty token")
+	}
+	return token, nil
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+// batchLink is a public link of --links-file and the folder of --output it
+// is downloaded into.
+type batchLink struct {
+	raw    string
+	key    string
+	paths  repeatValue
+	folder string
+}
+
+var (
+	linksFolderField = regexp.MustCompile(`\{([^{}]*)\}`)
+	// unsafeFolderChars are not allowed in folder names on some systems.
+	unsafeFolderChars = regexp.MustCompile(`[/\\:*?"<>|\x00-\x1f]+`)
+)
+
+// readLinks reads one public link per line, leaving out blank lines and
+// lines starting with #.
+func readLinks(r io.Reader) ([]string, error) {
+	var links []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			links = append(links, line)
+		}
+	}
+	return links, scanner.Err()
+}
+
+// batchLinks parses the links of --links-file, joining --path with the path
+// in each link.
+func batchLinks(links []string, paths repeatValue) ([]batchLink, error) {
+	list := make([]batchLink, 0, len(links))
+	for _, raw := range links {
+		key, sub, err := yadloader.ParsePublicLink(raw)
+		if err != nil {
+			return nil, err
+		}
+		b := batchLink{raw: raw, key: key, paths: paths}
+		if sub != "" && len(paths) == 0 {
+			b.paths = repeatValue{sub}
+		} else if sub != "" {
+			b.paths = make(repeatValue, len(paths))
+			for i, p := range paths {
+				b.paths[i] = path.Join(sub, p)
+			}
+		}
+		list = append(list, b)
+	}
+	return list, nil
+}
+
+// linksFolder fills in the fields of a --links-folder template: {name} of
+// the shared folder, {key} of the link and {n}, its position in the file.
+// Without a name, {name} is the key.
+func linksFolder(tmpl string, n int, key, name string) (string, error) {
+	// Keys of /d/ and /i/ links end in a short ID; others are hashes.
+	if strings.Contains(key, "://") {
+		key = path.Base(key)
+	}
+	if name == "" {
+		name = key
+	}
+	var err error
+	folder := linksFolderField.ReplaceAllStringFunc(tmpl, func(field string) string {
+		switch field {
+		case "{name}":
+			return folderName(name)
+		case "{key}":
+			return folderName(key)
+		case "{n}":
+			return strconv.Itoa(n)
+		}
+		err = fmt.Errorf("unknown field %s, use {name}, {key} or {n}", field)
+		return ""
+	})
+	if err != nil {
+		return "", err
+	}
+	folder = filepath.Clean(filepath.FromSlash(folder))
+	if !filepath.IsLocal(folder) {
+		return "", fmt.Errorf("%q is not a folder inside --output", folder)
+	}
+	return folder, nil
+}
+
+// folderName makes s usable as a folder name.
+func folderName(s string) string {
+	s = strings.Trim(unsafeFolderChars.ReplaceAllString(s, "_"), " .")
+	if r := []rune(s); len(r) > 100 {
+		s = strings.TrimRight(string(r[:100]), " .")
+	}
+	if s == "" {
+		return "_"
+	}
+	return s
+}
+
+// nameFolders names the folders of links from the --links-folder template,
+// looking up the names of the shared folders if it needs them. Repeated
+// names get "-2", "-3" and so on.
+func (r *runner) nameFolders(ctx context.Context, links []batchLink, tmpl string) error {
+	used := make(map[string]bool)
+	for i := range links {
+		b := &links[i]
+		var name string
+		if strings.Contains(tmpl, "{name}") {
+			var p string
+			if len(b.paths) == 1 {
+				p = b.paths[0]
+			}
+			meta, err := r.client.GetMeta(ctx, b.key, p)
+			switch {
+			case ctx.Err() != nil:
+				return ctx.Err()
+			case err != nil:
+				log.Printf(tr("Warning: cannot name the folder of %s after the share: %v"), b.raw, err)
+			case meta.Name != "":
+				name = meta.Name
+			}
+		}
+		folder, err := linksFolder(tmpl, i+1, b.key, name)
+		if err != nil {
+			return err
+		}
+		b.folder = folder
+		for n := 2; used[strings.ToLower(b.folder)]; n++ {
+			b.folder = fmt.Sprintf("%s-%d", folder, n)
+		}
+		used[strings.ToLower(b.folder)] = true
+	}
+	return nil
+}
+
+// batch downloads every link of --links-file into its own folder of
+// --output, up to --jobs at a time. When every link failed the same way it
+// returns their exit code, otherwise exitPartial if any link failed.
+func (r *runner) batch(ctx context.Context, params *Args) int {
+	in := os.Stdin
+	if params.LinksFile != "-" {
+		f, err := os.Open(params.LinksFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, tr("Error: --links-file: %v\n"), err)
+			return 1
+		}
+		defer f.Close()
+		in = f
+	}
+	raw, err := readLinks(in)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, tr("Error: --links-file: %v\n"), err)
+		return 1
+	}
+	links, err := batchLinks(raw, params.Paths)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, tr("Error: --links-file: %v\n"), err)
+		return exitLink
+	}
+	if len(links) == 0 {
+		log.Printf(tr("No links in %s"), params.LinksFile)
+		return 0
+	}
+	if err := r.nameFolders(ctx, links, params.LinksFolder); err != nil {
+		if ctx.Err() != nil {
+			return exitInterrupted
+		}
+		fmt.Fprintf(os.Stderr, tr("Error: --links-folder: %v\n"), err)
+		return 1
+	}
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		failed []string
+		code   int
+		slots  = make(chan struct{}, params.Jobs)
+	)
+	for i, b := range links {
+		select {
+		case slots <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		p := *params
+		p.Link, p.Paths = b.key, b.paths
+		p.Path = p.Paths.String()
+		p.Folder = filepath.Join(params.Folder, b.folder)
+		p.RetryFile = filepath.Join(p.Folder, retryFileName)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer func() { <-slots }()
+			log.Printf(tr("Link %d of %d: %s into %s"), i+1, len(links), b.raw, p.Folder)
+			if c := r.run(ctx, &p); c != exitOK {
+				mu.Lock()
+				failed = append(failed, b.raw)
+				if code != exitOK && code != c {
+					c = exitPartial
+				}
+				code = c
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	switch {
+	case ctx.Err() != nil:
+		return exitInterrupted
+	case len(failed) > 0:
+		log.Printf(tr("%d of %d links failed: %s"), len(failed), len(links), strings.Join(failed, ", "))
+		if len(failed) < len(links) {
+			return exitPartial
+		}
+		return code
+	}
+	log.Printf(tr("Downloaded %d links into %s"), len(links), params.Folder)
+	return 0
+}
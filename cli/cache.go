@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+// cachedTree lists the whole tree with client, going through the listing
+// cache, and applies filter to the result. Listings of the own disk are
+// kept apart by the token they were made with.
+func cachedTree(ctx context.Context, client *yadloader.YaDiskClient, params *Args, treeOpts []yadloader.TreeOption, filter yadloader.FilterOptions) ([]yadloader.DiskFile, error) {
+	dir, err := yadloader.DefaultCacheDir()
+	if err != nil {
+		return nil, err
+	}
+	cache := yadloader.ListCache{Dir: dir, TTL: params.CacheTTL}
+	var account string
+	if params.Link == "" {
+		account = params.Token
+	}
+	key := yadloader.CacheKey(params.source(), account, params.Path, params.Sort, strconv.Itoa(params.MaxDepth))
+
+	var (
+		files []yadloader.DiskFile
+		ok    bool
+	)
+	if !params.Refresh {
+		var age time.Duration
+		if files, age, ok, err = cache.Get(key); err != nil {
+			log.Printf(tr("Warning: listing cache: %v"), err)
+		} else if ok {
+			log.Printf(tr("Using listing of %d files cached %s ago, --refresh lists again"), len(files), age.Round(time.Second))
+		}
+	}
+	if !ok {
+		if files, err = listPaths(ctx, client, params, append(treeOpts, yadloader.Unfiltered())); err != nil {
+			return nil, err
+		}
+		if err := cache.Put(key, files); err != nil {
+			log.Printf(tr("Warning: listing cache: %v"), err)
+		}
+	}
+
+	kept := files[:0]
+	for _, f := range files {
+		if filter.Match(f) {
+			kept = append(kept, f)
+		}
+	}
+	return kept, nil
+}
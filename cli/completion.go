@@ -0,0 +1,158 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// commands are the subcommands parseFlags knows.
+var commands = []string{"watch", "serve", "stat", "duplicates", "diff", "diff-local", "upload", "copy", "move", "delete", "trash", "save", "quota", "auth", "completion", "version"}
+
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// flagChoices are the values offered for flags taking one of a few words.
+var flagChoices = map[string][]string{
+	"if-exists":   {"overwrite", "skip", "rename", "error"},
+	"over-limit":  {"stop", "skip"},
+	"infected":    {"warn", "skip", "allow"},
+	"exec-errors": {"warn", "fail"},
+	"checksums":   {"md5", "sha256"},
+	"compress":    {"none", "gzip", "zstd"},
+	"cas-tree":    {"symlink", "hardlink", "index"},
+	"lang":        {"en", "ru"},
+	"by-date":     {"taken", "modified", "created"},
+	"sort":        {"name", "path", "size", "created", "modified", "-name", "-path", "-size", "-created", "-modified"},
+	"media-type": {
+		"audio", "backup", "book", "compressed", "data", "development", "diskimage", "document", "encoded",
+		"executable", "flash", "font", "image", "settings", "spreadsheet", "text", "unknown", "video", "web",
+	},
+}
+
+// fileFlags take local files or folders.
+var fileFlags = []string{"output", "o", "links-file", "encrypt-to", "cas-objects", "config", "queue-db", "report-file", "retry-file", "retry-from", "ca-cert"}
+
+type completionFlag struct {
+	name    string
+	usage   string
+	boolean bool
+}
+
+// completionFlags lists the flags of the command line as parseFlags
+// defines them, so the scripts follow new flags.
+func completionFlags() []completionFlag {
+	var list []completionFlag
+	flag.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		list = append(list, completionFlag{name: f.Name, usage: f.Usage, boolean: ok && b.IsBoolFlag()})
+	})
+	return list
+}
+
+// writeCompletion writes the completion script of shell for the program
+// named prog.
+func writeCompletion(w io.Writer, shell, prog string) error {
+	flags := completionFlags()
+	switch shell {
+	case "bash":
+		writeBashCompletion(w, prog, flags)
+	case "zsh":
+		writeZshCompletion(w, prog, flags)
+	case "fish":
+		writeFishCompletion(w, prog, flags)
+	default:
+		return fmt.Errorf("unknown shell %q, use %s", shell, strings.Join(completionShells, ", "))
+	}
+	return nil
+}
+
+func writeBashCompletion(w io.Writer, prog string, flags []completionFlag) {
+	fn := "_" + strings.NewReplacer("-", "_", ".", "_").Replace(prog)
+	var names, values []string
+	for _, f := range flags {
+		names = append(names, "--"+f.name)
+		if !f.boolean && flagChoices[f.name] == nil {
+			values = append(values, "--"+f.name, "-"+f.name)
+		}
+	}
+
+	fmt.Fprintf(w, "# bash completion for %s, load with: source <(%s completion bash)\n", prog, prog)
+	fmt.Fprintf(w, "%s() {\n", fn)
+	fmt.Fprintln(w, `	local cur="${COMP_WORDS[COMP_CWORD]}" prev="${COMP_WORDS[COMP_CWORD-1]}"`)
+	fmt.Fprintln(w, `	case "$prev" in`)
+	for _, name := range sortedChoices() {
+		fmt.Fprintf(w, "\t--%s|-%s) COMPREPLY=($(compgen -W %q -- \"$cur\")); return ;;\n", name, name, strings.Join(flagChoices[name], " "))
+	}
+	fmt.Fprintf(w, "\t%s) return ;;\n", strings.Join(values, "|"))
+	fmt.Fprintln(w, "\tesac")
+	fmt.Fprintln(w, `	if [[ ${COMP_WORDS[1]} == completion ]]; then`)
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\")); return\n", strings.Join(completionShells, " "))
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, `	if [[ $COMP_CWORD -eq 1 && $cur != -* ]]; then`)
+	fmt.Fprintf(w, "\t\tCOMPREPLY=($(compgen -W %q -- \"$cur\")); return\n", strings.Join(commands, " "))
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintf(w, "\tCOMPREPLY=($(compgen -W %q -- \"$cur\"))\n", strings.Join(names, " "))
+	fmt.Fprintln(w, "}")
+	fmt.Fprintf(w, "complete -o default -F %s %s\n", fn, prog)
+}
+
+func writeZshCompletion(w io.Writer, prog string, flags []completionFlag) {
+	escape := strings.NewReplacer(`'`, `'\''`, "[", `\[`, "]", `\]`, ":", `\:`)
+	fmt.Fprintf(w, "#compdef %s\n", prog)
+	fmt.Fprintf(w, "# zsh completion for %s, load with: source <(%s completion zsh)\n", prog, prog)
+	fmt.Fprintf(w, "_%s() {\n", strings.ReplaceAll(prog, "-", "_"))
+	fmt.Fprintln(w, "\tif [[ $words[2] == completion ]]; then")
+	fmt.Fprintf(w, "\t\t_arguments '2:shell:(%s)'\n", strings.Join(completionShells, " "))
+	fmt.Fprintln(w, "\t\treturn")
+	fmt.Fprintln(w, "\tfi")
+	fmt.Fprintln(w, "\t_arguments -s \\")
+	fmt.Fprintf(w, "\t\t'1::command:(%s)' \\\n", strings.Join(commands, " "))
+	for _, f := range flags {
+		spec := fmt.Sprintf("--%s[%s]", f.name, escape.Replace(f.usage))
+		switch {
+		case f.boolean:
+		case flagChoices[f.name] != nil:
+			spec += fmt.Sprintf(":%s:(%s)", f.name, strings.Join(flagChoices[f.name], " "))
+		case slices.Contains(fileFlags, f.name):
+			spec += fmt.Sprintf(":%s:_files", f.name)
+		default:
+			spec += fmt.Sprintf(":%s: ", f.name)
+		}
+		fmt.Fprintf(w, "\t\t'%s' \\\n", spec)
+	}
+	fmt.Fprintln(w, "\t\t'*:file:_files'")
+	fmt.Fprintln(w, "}")
+	fmt.Fprintf(w, "compdef _%s %s\n", strings.ReplaceAll(prog, "-", "_"), prog)
+}
+
+func writeFishCompletion(w io.Writer, prog string, flags []completionFlag) {
+	quote := func(s string) string { return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'" }
+	fmt.Fprintf(w, "# fish completion for %s, load with: %s completion fish | source\n", prog, prog)
+	fmt.Fprintf(w, "complete -c %s -f\n", prog)
+	fmt.Fprintf(w, "complete -c %s -n __fish_use_subcommand -a %s\n", prog, quote(strings.Join(commands, " ")))
+	fmt.Fprintf(w, "complete -c %s -n '__fish_seen_subcommand_from completion' -a %s\n", prog, quote(strings.Join(completionShells, " ")))
+	for _, f := range flags {
+		line := fmt.Sprintf("complete -c %s -l %s -d %s", prog, f.name, quote(f.usage))
+		switch {
+		case f.boolean:
+		case flagChoices[f.name] != nil:
+			line += " -x -a " + quote(strings.Join(flagChoices[f.name], " "))
+		case slices.Contains(fileFlags, f.name):
+			line += " -r -F"
+		default:
+			line += " -x"
+		}
+		fmt.Fprintln(w, line)
+	}
+}
+
+func sortedChoices() []string {
+	names := make([]string, 0, len(flagChoices))
+	for name := range flagChoices {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
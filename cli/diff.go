@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"sync"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+type diffFile struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	MD5  string `json:"md5,omitempty"`
+}
+
+type diffChange struct {
+	Path string   `json:"path"`
+	Old  diffFile `json:"old"`
+	New  diffFile `json:"new"`
+}
+
+// diffResult is what diff prints with --json.
+type diffResult struct {
+	Added   []diffFile   `json:"added"`
+	Removed []diffFile   `json:"removed"`
+	Changed []diffChange `json:"changed"`
+}
+
+func newDiffFile(f yadloader.DiskFile) diffFile {
+	return diffFile{Path: f.Path, Size: f.Size, MD5: f.MD5}
+}
+
+func newDiffResult(old []yadloader.DiskFile, c yadloader.Changes) diffResult {
+	before := make(map[string]yadloader.DiskFile, len(old))
+	for _, f := range old {
+		before[f.Path] = f
+	}
+	r := diffResult{Added: []diffFile{}, Removed: []diffFile{}, Changed: []diffChange{}}
+	for _, f := range c.Added {
+		r.Added = append(r.Added, newDiffFile(f))
+	}
+	for _, f := range c.Removed {
+		r.Removed = append(r.Removed, newDiffFile(f))
+	}
+	for _, f := range c.Changed {
+		r.Changed = append(r.Changed, diffChange{Path: f.Path, Old: newDiffFile(before[f.Path]), New: newDiffFile(f)})
+	}
+	return r
+}
+
+// printDiff writes r as text or JSON and returns the exit code of diff(1):
+// 0 without differences, 1 with.
+func printDiff(params *Args, r diffResult) int {
+	if params.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(r); err != nil {
+			log.Printf(tr("Error: %v"), err)
+			return 2
+		}
+	} else {
+		for _, f := range r.Added {
+			fmt.Printf("+ %s  (%s)\n", f.Path, formatSize(f.Size))
+		}
+		for _, f := range r.Removed {
+			fmt.Printf("- %s  (%s)\n", f.Path, formatSize(f.Size))
+		}
+		for _, c := range r.Changed {
+			fmt.Printf("~ %s  (%s -> %s)\n", c.Path, formatSize(c.Old.Size), formatSize(c.New.Size))
+		}
+		fmt.Printf(tr("%d added, %d removed, %d changed\n"), len(r.Added), len(r.Removed), len(r.Changed))
+	}
+	if len(r.Added)+len(r.Removed)+len(r.Changed) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// diff lists --link and --link2 and reports the files added, removed and
+// changed in the second one, comparing paths within the listed folders and
+// contents by hash. Like diff(1) it exits with 0 when the trees match, 1
+// when they differ and 2 on errors.
+func diff(ctx context.Context, client *yadloader.YaDiskClient, params *Args) int {
+	sides := [2]struct {
+		link, path string
+		files      []yadloader.DiskFile
+		err        error
+	}{{link: params.Link, path: params.Path}, {link: params.Link2, path: params.Path2}}
+
+	var wg sync.WaitGroup
+	for i := range sides {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := &sides[i]
+			s.files, s.err = client.GetTree(ctx, s.link, s.path, treeOptions(params, func(int64, int64) {})...)
+			s.files = relativeTo(s.path, s.files)
+		}()
+	}
+	wg.Wait()
+	for _, s := range sides {
+		if s.err != nil {
+			if ctx.Err() != nil {
+				log.Print(tr("Interrupted while listing"))
+				return exitInterrupted
+			}
+			log.Printf(tr("Error: %s: %v"), s.link, s.err)
+			return 2
+		}
+	}
+
+	old := sides[0].files
+	return printDiff(params, newDiffResult(old, yadloader.DiffFunc(old, sides[1].files, yadloader.SameHash)))
+}
+
+// diffLocal lists the share and reports what a download into --output would
+// transfer: files missing there or differing in size or MD5, and the local
+// files the share does not have. Exit codes are those of diff.
+func diffLocal(ctx context.Context, client *yadloader.YaDiskClient, params *Args) int {
+	files, err := listPaths(ctx, client, params, treeOptions(params, func(int64, int64) {}))
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Print(tr("Interrupted while listing"))
+			return exitInterrupted
+		}
+		log.Printf(tr("Error: %v"), err)
+		return 2
+	}
+	c, err := yadloader.CompareLocal(params.Folder, files, !params.SizeOnly)
+	if err != nil {
+		log.Printf(tr("Error: %v"), err)
+		return 2
+	}
+	// Files the tool writes itself are not part of the share.
+	own := []string{retryFileName, yadloader.ChecksumMD5.FileName(), yadloader.ChecksumSHA256.FileName()}
+	c.Removed = slices.DeleteFunc(c.Removed, func(f yadloader.DiskFile) bool { return slices.Contains(own, f.Name) })
+
+	var local []yadloader.DiskFile
+	for _, f := range c.Changed {
+		if info, err := os.Stat(yadloader.LocalPath(params.Folder, f)); err == nil {
+			local = append(local, yadloader.DiskFile{Path: f.Path, Size: info.Size()})
+		}
+	}
+	return printDiff(params, newDiffResult(local, c))
+}
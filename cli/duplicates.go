@@ -0,0 +1,68 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+type duplicateGroup struct {
+	MD5    string   `json:"md5"`
+	Size   int64    `json:"size"`
+	Wasted int64    `json:"wasted"`
+	Paths  []string `json:"paths"`
+}
+
+type duplicateReport struct {
+	Files     int              `json:"files"`
+	Redundant int              `json:"redundant_files"`
+	Wasted    int64            `json:"wasted"`
+	Groups    []duplicateGroup `json:"groups"`
+}
+
+// duplicates lists the share and reports files with the same contents and
+// the space the extra copies take, as text or with --json as JSON.
+func duplicates(ctx context.Context, client *yadloader.YaDiskClient, params *Args) int {
+	files, err := listPaths(ctx, client, params, treeOptions(params, func(int64, int64) {}))
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Print(tr("Interrupted while listing"))
+			return exitInterrupted
+		}
+		log.Printf(tr("Error: %v"), err)
+		return 1
+	}
+
+	r := duplicateReport{Files: len(files), Groups: []duplicateGroup{}}
+	for _, g := range yadloader.Duplicates(files) {
+		dg := duplicateGroup{MD5: g[0].MD5, Size: g[0].Size, Wasted: g[0].Size * int64(len(g)-1)}
+		for _, f := range g {
+			dg.Paths = append(dg.Paths, f.Path)
+		}
+		r.Groups = append(r.Groups, dg)
+		r.Redundant += len(g) - 1
+		r.Wasted += dg.Wasted
+	}
+
+	if params.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(r); err != nil {
+			log.Printf(tr("Error: %v"), err)
+			return 1
+		}
+		return 0
+	}
+	for _, g := range r.Groups {
+		fmt.Printf(tr("%d copies of %s (md5 %s), %s wasted\n"), len(g.Paths), formatSize(g.Size), g.MD5, formatSize(g.Wasted))
+		for _, p := range g.Paths {
+			fmt.Printf("  %s\n", p)
+		}
+	}
+	fmt.Printf(tr("%d of %d files are extra copies in %d groups, wasting %s\n"), r.Redundant, r.Files, len(r.Groups), formatSize(r.Wasted))
+	return 0
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+// summary describes a finished run. Files and TotalSize cover the whole
+// tree, including files finished by earlier runs of a resumed download.
+type summary struct {
+	Files     int
+	TotalSize int64
+	Report    yadloader.Report
+}
+
+// reporter receives progress of a run. Implementations must be safe for
+// concurrent use because download workers report independently.
+type reporter interface {
+	Listing(count, totalSize int64)
+	Discovered(file yadloader.DiskFile)
+	Planned(files int, totalSize int64)
+	Started(file yadloader.DiskFile, dest string)
+	Skipped(file yadloader.DiskFile, dest string)
+	Finished(file yadloader.DiskFile, dest string, elapsed time.Duration)
+	Failed(file yadloader.DiskFile, dest string, err error)
+	// Progress receives EventProgress of a running download; eta is
+	// negative when unknown.
+	Progress(e yadloader.Event, eta time.Duration)
+	Summary(s summary)
+}
+
+type textReporter struct {
+	out io.Writer
+	// interval is that of progress events, which arrive for all running
+	// downloads at once; one line is logged per interval.
+	interval time.Duration
+
+	mu           sync.Mutex
+	lastProgress time.Time
+}
+
+func (r *textReporter) Listing(count, totalSize int64) {
+	log.Printf(tr("Files: %d, Size: %d"), count, totalSize)
+}
+
+func (r *textReporter) Discovered(file yadloader.DiskFile) {
+	fmt.Fprintln(r.out, file.Path, file.File)
+}
+
+func (r *textReporter) Planned(files int, totalSize int64) {
+	fmt.Fprintf(r.out, tr("Total files %d, total size %d\n"), files, totalSize)
+}
+
+func (r *textReporter) Started(yadloader.DiskFile, string) {}
+
+func (r *textReporter) Skipped(file yadloader.DiskFile, dest string) {
+	log.Print(skippedLine(file, dest))
+}
+
+// skippedLine tells why file was skipped: it exists, or the antivirus
+// flagged it.
+func skippedLine(file yadloader.DiskFile, dest string) string {
+	if _, err := os.Stat(dest); err != nil && file.Infected() {
+		return fmt.Sprintf(tr("Skipped %s, reported infected"), file.Path)
+	}
+	return fmt.Sprintf(tr("Skipped %s, %s exists"), file.Path, dest)
+}
+
+func (r *textReporter) Finished(file yadloader.DiskFile, _ string, elapsed time.Duration) {
+	log.Printf(tr("Downloaded %s (%d bytes) in %s"), file.Path, file.Size, elapsed.Round(time.Millisecond))
+}
+
+func (r *textReporter) Failed(file yadloader.DiskFile, _ string, err error) {
+	log.Printf(tr("Failed %s: %v"), file.Path, err)
+}
+
+func (r *textReporter) Progress(e yadloader.Event, eta time.Duration) {
+	r.mu.Lock()
+	due := time.Since(r.lastProgress) >= r.interval/2
+	if due {
+		r.lastProgress = time.Now()
+	}
+	r.mu.Unlock()
+	if !due {
+		return
+	}
+	line := fmt.Sprintf(tr("Progress: %s at %s/s"), formatSize(e.TotalBytes), formatSize(int64(e.Total.Average)))
+	if eta >= 0 {
+		line += fmt.Sprintf(tr(", ETA %s"), eta.Round(time.Second))
+	}
+	log.Print(line)
+}
+
+func (r *textReporter) Summary(s summary) {
+	rep := s.Report
+	fmt.Fprintf(r.out, tr("Downloaded %d of %d files, skipped %d, failed %d, retried %d: %s in %s (%s/s)\n"),
+		rep.Downloaded, s.Files, rep.Skipped, rep.Failed, rep.Retried,
+		formatSize(rep.Bytes), rep.Duration.Round(time.Millisecond), formatSize(int64(rep.Throughput())))
+}
+
+type event struct {
+	Event      string    `json:"event"`
+	Time       time.Time `json:"time"`
+	Path       string    `json:"path,omitempty"`
+	Size       *int64    `json:"size,omitempty"`
+	URL        string    `json:"url,omitempty"`
+	Dest       string    `json:"dest,omitempty"`
+	DurationMs *int64    `json:"duration_ms,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	Files      *int      `json:"files,omitempty"`
+	TotalSize  *int64    `json:"total_size,omitempty"`
+	Downloaded *int      `json:"downloaded,omitempty"`
+	Uploaded   *int      `json:"uploaded,omitempty"`
+	Skipped    *int      `json:"skipped,omitempty"`
+	Failed     *int      `json:"failed,omitempty"`
+	Retried    *int      `json:"retried,omitempty"`
+	Bytes      *int64    `json:"bytes,omitempty"`
+	Throughput *float64  `json:"bytes_per_second,omitempty"`
+	AvgSpeed   *float64  `json:"avg_bytes_per_second,omitempty"`
+	TotalSpeed *float64  `json:"total_bytes_per_second,omitempty"`
+	TotalBytes *int64    `json:"total_bytes,omitempty"`
+	ETASeconds *int64    `json:"eta_seconds,omitempty"`
+}
+
+// jsonReporter writes one JSON event per line.
+type jsonReporter struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newJSONReporter(out io.Writer) *jsonReporter {
+	return &jsonReporter{enc: json.NewEncoder(out)}
+}
+
+func (r *jsonReporter) emit(e event) {
+	e.Time = time.Now().UTC()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.enc.Encode(e)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func (r *jsonReporter) Listing(int64, int64) {}
+
+func (r *jsonReporter) Discovered(file yadloader.DiskFile) {
+	r.emit(event{Event: "file_discovered", Path: file.Path, Size: ptr(file.Size), URL: file.File})
+}
+
+func (r *jsonReporter) Planned(int, int64) {}
+
+func (r *jsonReporter) Started(file yadloader.DiskFile, dest string) {
+	r.emit(event{Event: "download_started", Path: file.Path, Size: ptr(file.Size), Dest: dest})
+}
+
+func (r *jsonReporter) Skipped(file yadloader.DiskFile, dest string) {
+	r.emit(event{Event: "download_skipped", Path: file.Path, Size: ptr(file.Size), Dest: dest})
+}
+
+func (r *jsonReporter) Finished(file yadloader.DiskFile, dest string, elapsed time.Duration) {
+	r.emit(event{Event: "download_finished", Path: file.Path, Size: ptr(file.Size), Dest: dest, DurationMs: ptr(elapsed.Milliseconds())})
+}
+
+func (r *jsonReporter) Failed(file yadloader.DiskFile, dest string, err error) {
+	r.emit(event{Event: "download_failed", Path: file.Path, Dest: dest, Error: err.Error()})
+}
+
+func (r *jsonReporter) Progress(e yadloader.Event, eta time.Duration) {
+	ev := event{
+		Event:      "download_progress",
+		Path:       e.File.Path,
+		Size:       ptr(e.File.Size),
+		Dest:       e.Dest,
+		Bytes:      ptr(e.Bytes),
+		Throughput: ptr(e.Speed.Current),
+		AvgSpeed:   ptr(e.Speed.Average),
+		TotalSpeed: ptr(e.Total.Average),
+		TotalBytes: ptr(e.TotalBytes),
+	}
+	if eta >= 0 {
+		ev.ETASeconds = ptr(int64(eta.Seconds()))
+	}
+	r.emit(ev)
+}
+
+func (r *jsonReporter) Summary(s summary) {
+	r.emit(event{
+		Event:      "summary",
+		Files:      ptr(s.Files),
+		TotalSize:  ptr(s.TotalSize),
+		Downloaded: ptr(s.Report.Downloaded),
+		Skipped:    ptr(s.Report.Skipped),
+		Failed:     ptr(s.Report.Failed),
+		Retried:    ptr(s.Report.Retried),
+		Bytes:      ptr(s.Report.Bytes),
+		Throughput: ptr(s.Report.Throughput()),
+		DurationMs: ptr(s.Report.Duration.Milliseconds()),
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+// executor runs the --exec command for downloaded files, at most jobs at a
+// time.
+type executor struct {
+	command string
+	fail    bool
+	out     io.Writer
+	slots   chan struct{}
+}
+
+func newExecutor(command string, jobs int, fail bool, out io.Writer) *executor {
+	if !strings.Contains(command, "{}") {
+		command += " {}"
+	}
+	return &executor{command: command, fail: fail, out: out, slots: make(chan struct{}, jobs)}
+}
+
+// afterFile is used as yadloader.Hooks.AfterFile. Download workers wait
+// while all jobs are busy.
+func (x *executor) afterFile(ctx context.Context, e yadloader.Event) error {
+	if e.Type != yadloader.EventFinished {
+		return nil
+	}
+	select {
+	case x.slots <- struct{}{}:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	defer func() { <-x.slots }()
+
+	err := x.run(ctx, e.Dest)
+	if err == nil {
+		return nil
+	}
+	err = fmt.Errorf("--exec: %w", err)
+	if x.fail {
+		return err
+	}
+	log.Printf(tr("Warning: %s: %v"), e.Dest, err)
+	return nil
+}
+
+func (x *executor) run(ctx context.Context, filename string) error {
+	cmd := shellCommand(ctx, strings.ReplaceAll(x.command, "{}", shellQuote(filename)))
+	cmd.Stdout = x.out
+	cmd.Stderr = x.out
+	return cmd.Run()
+}
@@ -0,0 +1,17 @@
+//go:build !windows
+
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+func shellCommand(ctx context.Context, line string) *exec.Cmd {
+	return exec.CommandContext(ctx, "sh", "-c", line)
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
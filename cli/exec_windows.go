@@ -0,0 +1,25 @@
+package main
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"syscall"
+)
+
+// shellCommand runs line with cmd.exe. The command line is passed as is:
+// the argument escaping of os/exec would turn the quotes of shellQuote
+// into \", which cmd.exe does not understand. With /S cmd.exe strips the
+// outer quotes and runs the rest unchanged.
+func shellCommand(ctx context.Context, line string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "cmd")
+	cmd.SysProcAttr = &syscall.SysProcAttr{CmdLine: `cmd /S /C "` + line + `"`}
+	return cmd
+}
+
+// shellQuote quotes s for cmd.exe, which cannot hold " in file names. A %
+// would expand variables even inside quotes, so it is escaped with ^
+// outside of them.
+func shellQuote(s string) string {
+	return `"` + strings.ReplaceAll(s, "%", `"^%"`) + `"`
+}
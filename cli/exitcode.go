@@ -0,0 +1,110 @@
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+// Exit codes of a download, so scripts can tell failures apart without
+// reading the log. exitInterrupted is the other one.
+const (
+	exitOK = 0
+	// exitError is for bad flags and failures of no other class.
+	exitError = 1
+	// exitPartial is for runs where some files failed.
+	exitPartial = 2
+	// exitLink is for links that are malformed, gone or blocked, and for
+	// disks that need a token.
+	exitLink = 3
+	// exitGaveUp is for runs stopped by rate limits, --max-duration,
+	// --operation-timeout or the retry budget.
+	exitGaveUp = 4
+	// exitDest is for output folders that cannot be written.
+	exitDest = 5
+	// exitLocked is for output folders another run is downloading into.
+	exitLocked = 6
+)
+
+// exitCodes is the help of the exit codes, at the end of the usage.
+const exitCodes = `
+Exit codes:
+  0    everything was downloaded
+  1    bad flags or other errors
+  2    some files failed
+  3    the link is malformed, not found or blocked, or needs --token
+  4    rate limited, or stopped by --max-duration, --operation-timeout or --retry-budget
+  5    the output folder cannot be written to, e.g. it is full
+  6    another run holds the lock of the output folder, see --wait-lock
+  130  interrupted`
+
+// errorClass returns the exit code for err, or code if it has no class.
+func errorClass(err error, code int) int {
+	var (
+		apiErr  *yadloader.APIError
+		pathErr *fs.PathError
+		linkErr *os.LinkError
+	)
+	switch {
+	case errors.Is(err, yadloader.ErrLocked):
+		return exitLocked
+	case errors.Is(err, yadloader.ErrInsufficientSpace),
+		errors.Is(err, yadloader.ErrFileExists),
+		errors.As(err, &pathErr),
+		errors.As(err, &linkErr):
+		return exitDest
+	case errors.Is(err, yadloader.ErrRateLimited),
+		errors.Is(err, yadloader.ErrOperationTimeout),
+		errors.Is(err, yadloader.ErrRetryBudget):
+		return exitGaveUp
+	case errors.Is(err, yadloader.ErrNotFound),
+		errors.Is(err, yadloader.ErrResourceBlocked),
+		errors.Is(err, yadloader.ErrTokenRequired):
+		return exitLink
+	case errors.As(err, &apiErr) && (apiErr.StatusCode == http.StatusUnauthorized || apiErr.StatusCode == http.StatusForbidden):
+		return exitLink
+	}
+	return code
+}
+
+// setupError logs err, a failure to prepare --output or its state, and
+// returns its exit code.
+func setupError(err error) int {
+	log.Printf(tr("Error: %v"), err)
+	return errorClass(err, exitDest)
+}
+
+// exitCode returns the exit code of a download that ended with err.
+// Failed files give exitPartial, unless a file could not be written, every
+// failure was rate limited, or nothing was downloaded because the link is
+// blocked.
+func exitCode(err error, report yadloader.Report) int {
+	if err == nil {
+		return exitOK
+	}
+	if len(report.Failures) == 0 {
+		// --fail-fast counts the failed file without collecting it.
+		if report.Failed > 0 {
+			return errorClass(err, exitPartial)
+		}
+		return errorClass(err, exitError)
+	}
+	code := errorClass(report.Failures[0].Err, exitPartial)
+	for _, f := range report.Failures {
+		c := errorClass(f.Err, exitPartial)
+		if c == exitDest {
+			return exitDest
+		}
+		if c != code {
+			code = exitPartial
+		}
+	}
+	if code == exitLink && report.Downloaded+report.Skipped > 0 {
+		return exitPartial
+	}
+	return code
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+// fileNode is a file or folder of a listing shown as a tree. Folders count
+// the files below them, and for the picker how many of those are marked.
+type fileNode struct {
+	name     string
+	depth    int
+	parent   *fileNode
+	children []*fileNode
+	file     *yadloader.DiskFile
+	open     bool
+
+	size       int64
+	total      int
+	marked     int
+	markedSize int64
+}
+
+func (n *fileNode) dir() bool { return n.file == nil }
+
+// buildFileTree arranges files by folder, folders first, then by name.
+func buildFileTree(files []yadloader.DiskFile) *fileNode {
+	root := &fileNode{open: true, depth: -1}
+	dirs := map[string]*fileNode{"": root}
+	var folder func(p string) *fileNode
+	folder = func(p string) *fileNode {
+		if d, ok := dirs[p]; ok {
+			return d
+		}
+		dir, name := path.Split(p)
+		parent := folder(strings.TrimSuffix(dir, "/"))
+		d := &fileNode{name: name, depth: parent.depth + 1, parent: parent}
+		parent.children = append(parent.children, d)
+		dirs[p] = d
+		return d
+	}
+	for i := range files {
+		f := &files[i]
+		dir, name := path.Split(strings.TrimPrefix(path.Clean("/"+f.Path), "/"))
+		parent := folder(strings.TrimSuffix(dir, "/"))
+		parent.children = append(parent.children, &fileNode{name: name, depth: parent.depth + 1, parent: parent, file: f, total: 1, size: f.Size})
+		for d := parent; d != nil; d = d.parent {
+			d.total++
+			d.size += f.Size
+		}
+	}
+	var sortTree func(n *fileNode)
+	sortTree = func(n *fileNode) {
+		slices.SortFunc(n.children, func(a, b *fileNode) int {
+			if a.dir() != b.dir() {
+				if a.dir() {
+					return -1
+				}
+				return 1
+			}
+			return strings.Compare(a.name, b.name)
+		})
+		for _, c := range n.children {
+			sortTree(c)
+		}
+	}
+	sortTree(root)
+	return root
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+// errOverLimit stops a pipelined run that reached --max-files or
+// --max-total-size.
+var errOverLimit = errors.New("download limit reached")
+
+func (a *Args) limited() bool {
+	return a.MaxFiles > 0 || a.MaxTotalSize > 0
+}
+
+// capFiles applies --max-files and --max-total-size to the files about to be
+// downloaded. With --over-limit stop an oversized download is an error,
+// with skip the files after the limit are left out.
+func capFiles(params *Args, files []yadloader.DiskFile) ([]yadloader.DiskFile, error) {
+	if !params.limited() {
+		return files, nil
+	}
+	var size int64
+	for i, f := range files {
+		if params.MaxFiles > 0 && i >= params.MaxFiles || params.MaxTotalSize > 0 && size+f.Size > int64(params.MaxTotalSize) {
+			if params.OverLimit == "stop" {
+				var total int64
+				for _, f := range files {
+					total += f.Size
+				}
+				return nil, fmt.Errorf("%d files of %s exceed --max-files or --max-total-size, use --over-limit skip to download only the first ones",
+					len(files), formatSize(total))
+			}
+			return files[:i], nil
+		}
+		size += f.Size
+	}
+	return files, nil
+}
+
+// pipelineCaps applies the limits to a pipelined run as files arrive, as
+// its BeforeFile hook. Reaching them with --over-limit stop cancels the run
+// through stop; with skip the files left out are counted in skipped.
+type pipelineCaps struct {
+	params *Args
+	stop   context.CancelCauseFunc
+
+	mu      sync.Mutex
+	files   int
+	size    int64
+	skipped int
+}
+
+func (c *pipelineCaps) beforeFile(_ context.Context, file yadloader.DiskFile, _ string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.params.MaxFiles > 0 && c.files >= c.params.MaxFiles || c.params.MaxTotalSize > 0 && c.size+file.Size > int64(c.params.MaxTotalSize) {
+		if c.params.OverLimit == "stop" {
+			c.stop(errOverLimit)
+			return errOverLimit
+		}
+		c.skipped++
+		return yadloader.ErrSkipFile
+	}
+	c.files++
+	c.size += file.Size
+	return nil
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+// lockOutput locks --output against other runs, waiting up to --wait-lock
+// for it. With --force a run holding the lock is only warned about. The
+// returned func releases the lock.
+func lockOutput(ctx context.Context, params *Args) (func(), error) {
+	return lockFolder(ctx, params, params.Folder)
+}
+
+// lockFolder is lockOutput for the folder dir, such as the output of a
+// serve job.
+func lockFolder(ctx context.Context, params *Args, dir string) (func(), error) {
+	wait := params.WaitLock > 0
+	if wait {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, params.WaitLock)
+		defer cancel()
+	}
+	lock, err := yadloader.LockOutput(ctx, dir, wait)
+	switch {
+	case errors.Is(err, errors.ErrUnsupported):
+		return func() {}, nil
+	case errors.Is(err, yadloader.ErrLocked) && params.Force:
+		log.Printf(tr("Warning: %v, starting anyway"), err)
+		return func() {}, nil
+	case err != nil:
+		return nil, err
+	}
+	return func() {
+		if err := lock.Unlock(); err != nil {
+			log.Printf(tr("Warning: %v"), err)
+		}
+	}, nil
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// locales are the message catalogs of --lang by language. They translate
+// the English messages the code is written with, and messages missing from
+// a catalog stay in English.
+var locales = map[string]map[string]string{
+	"en": nil,
+	"ru": ruMessages,
+}
+
+// messages is the catalog of the --lang locale.
+var messages map[string]string
+
+// setLocale selects the catalog of lang for tr.
+func setLocale(lang string) error {
+	m, ok := locales[strings.ToLower(lang)]
+	if !ok {
+		return fmt.Errorf("unknown language %q, use %s", lang, strings.Join(slices.Sorted(maps.Keys(locales)), ", "))
+	}
+	messages = m
+	return nil
+}
+
+// tr returns msg in the language of --lang. Messages are looked up by
+// their English text, format verbs included.
+func tr(msg string) string {
+	if s, ok := messages[msg]; ok {
+		return s
+	}
+	return msg
+}
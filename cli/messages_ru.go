@@ -0,0 +1,224 @@
+package main
+
+// ruMessages is the Russian catalog of --lang ru.
+var ruMessages = map[string]string{
+	// Downloads
+	"Files: %d, Size: %d":                  "Файлов: %d, размер: %d",
+	"Total files %d, total size %d\n":      "Всего файлов: %d, общий размер: %d\n",
+	"Link is a single file: %s (%d bytes)": "Ссылка ведёт на один файл: %s (%d байт)",
+	"Downloaded %s (%d bytes) in %s":       "Загружен %s (%d байт) за %s",
+	"Downloaded %s (%s) in %s":             "Загружен %s (%s) за %s",
+	"Failed %s: %v":                        "Ошибка загрузки %s: %v",
+	"Skipped %s, %s exists":                "Пропущен %s, %s уже есть",
+	"Skipped %s, reported infected":        "Пропущен %s, помечен как заражённый",
+	"Progress: %s at %s/s":                 "Загружено %s, %s/с",
+	", ETA %s":                             ", осталось %s",
+	"Downloaded %d of %d files, skipped %d, failed %d, retried %d: %s in %s (%s/s)\n": "Загружено файлов: %d из %d, пропущено: %d, с ошибкой: %d, повторено: %d; %s за %s (%s/с)\n",
+	"Retrying %d files from %s":                                        "Повтор загрузки файлов: %d из %s",
+	"%d files can be retried with --retry-from %s":                     "Файлов можно загрузить повторно с --retry-from %[2]s: %[1]d",
+	"Removed %d stale %s files":                                        "Удалено старых файлов %[2]s: %[1]d",
+	"Skipped %d files over --max-files or --max-total-size":            "Пропущено файлов сверх --max-files или --max-total-size: %d",
+	"Skipping the last %d files, over --max-files or --max-total-size": "Пропуск последних файлов сверх --max-files или --max-total-size: %d",
+	"Skipping %d files without a preview":                              "Пропуск файлов без превью: %d",
+	"Nothing to resume in %s, starting a new run":                      "В %s нечего продолжать, начинается новая загрузка",
+	"No earlier complete run in %s, downloading everything":            "В %s нет завершённой загрузки, загружается всё",
+	"%d of %d files changed since the last run at %s":                  "С прошлой загрузки в %[3]s изменилось файлов: %[1]d из %[2]d",
+	"Using listing of %d files cached %s ago, --refresh lists again":   "Используется список из %d файлов, сохранённый %s назад; --refresh получит его заново",
+	"Nothing picked":                                  "Ничего не выбрано",
+	"Created %d empty folders":                        "Создано пустых папок: %d",
+	"Wrote checksums of %d files to %d %s files":      "Контрольные суммы файлов (%d) записаны в файлы %[3]s (%[2]d)",
+	"Use --force to start anyway":                     "Используйте --force, чтобы начать всё равно",
+	"Interrupted":                                     "Прервано",
+	"Interrupted while listing":                       "Прервано при получении списка файлов",
+	"Interrupted, progress saved to %s":               "Прервано, прогресс сохранён в %s",
+	"Stopped by --max-duration":                       "Остановлено по --max-duration",
+	"Stopped by --max-duration, progress saved to %s": "Остановлено по --max-duration, прогресс сохранён в %s",
+	"Stopped at --max-files or --max-total-size, use --over-limit skip to download only the first files": "Остановлено на --max-files или --max-total-size; используйте --over-limit skip, чтобы загрузить только первые файлы",
+
+	// Batches, watch and serve
+	"No links in %s":                                         "В %s нет ссылок",
+	"Link %d of %d: %s into %s":                              "Ссылка %d из %d: %s в %s",
+	"%d of %d links failed: %s":                              "Ссылок с ошибкой: %d из %d: %s",
+	"Downloaded %d links into %s":                            "Загружено ссылок в %[2]s: %[1]d",
+	"Stopped watching, progress saved to %s":                 "Наблюдение остановлено, прогресс сохранён в %s",
+	"Stopped watching after --max-duration":                  "Наблюдение остановлено по --max-duration",
+	"Next check at %s":                                       "Следующая проверка в %s",
+	"%d files added, %d changed, %d removed, %d to download": "Файлов добавлено: %d, изменено: %d, удалено: %d, к загрузке: %d",
+	"Serving jobs on %s, downloading into %s":                "Приём заданий на %s, загрузка в %s",
+	"Serving progress on %s":                                 "Прогресс доступен на %s",
+	"Serving jobs on %s":                                     "Приём заданий на %s",
+	"Serving jobs on %s, %d running, %d queued":              "Приём заданий на %s, выполняется: %d, в очереди: %d",
+	"Watching":                  "Наблюдение",
+	"Checking for changes":      "Проверка изменений",
+	"Last check failed: %v. %s": "Последняя проверка не удалась: %v. %s",
+	"Stopped serving":           "Приём заданий остановлен",
+	"Job %s %s":                 "Задание %s: %s",
+	"Job %s: ":                  "Задание %s: ",
+	"download of %s%s into %s %s: %d downloaded, %d skipped, %d failed, %s in %s": "загрузка %s%s в %s: %s; загружено: %d, пропущено: %d, с ошибкой: %d; %s за %s",
+	"\nError: %s": "\nОшибка: %s",
+
+	// Failures and warnings
+	"Failed to save state: %v":                                  "Не удалось сохранить состояние: %v",
+	"Failed to write report: %v":                                "Не удалось записать отчёт: %v",
+	"Failed to write checksums: %v":                             "Не удалось записать контрольные суммы: %v",
+	"Failed to write retry file: %v":                            "Не удалось записать файл повтора: %v",
+	"Failed to create empty folders: %v":                        "Не удалось создать пустые папки: %v",
+	"Failed to set folder times: %v":                            "Не удалось установить время папок: %v",
+	"Failed to notify %s: %v":                                   "Не удалось отправить уведомление %s: %v",
+	"Warning: %v, starting anyway":                              "Предупреждение: %v, запуск всё равно",
+	"Warning: %v":                                               "Предупреждение: %v",
+	"Warning: %s: %v":                                           "Предупреждение: %s: %v",
+	"Warning: listing cache: %v":                                "Предупреждение: кэш списка файлов: %v",
+	"Warning: cannot name the folder of %s after the share: %v": "Предупреждение: не удалось назвать папку %s по имени публичной папки: %v",
+	"Warning: %s looks like %s, not %s":                         "Предупреждение: %s похож на %s, а не на %s",
+	"Warning: %s is reported infected by the Yandex.Disk antivirus, use --infected skip to leave such files out": "Предупреждение: антивирус Яндекс.Диска считает %s заражённым, используйте --infected skip, чтобы пропускать такие файлы",
+	"Warning: --%s %d exceeds the maximum, using %d":                                                             "Предупреждение: --%s %d больше максимума, используется %d",
+	"Warning: --%s %d may trigger Yandex.Disk API rate limits (HTTP 429)":                                        "Предупреждение: --%s %d может упереться в ограничения API Яндекс.Диска (HTTP 429)",
+	"Warning: --cache-ttl is ignored with --preview-size":                                                        "Предупреждение: --cache-ttl не действует вместе с --preview-size",
+	"Warning: TLS certificate verification is disabled":                                                          "Предупреждение: проверка TLS-сертификатов отключена",
+
+	// upload
+	"Uploaded %s to %s (%d bytes) in %s":                                "Загружен %s в %s (%d байт) за %s",
+	"Skipped %s, %s exists on the disk":                                 "Пропущен %s, %s уже есть на диске",
+	"Uploaded %d of %d files, skipped %d, failed %d: %s in %s\n":        "Загружено %d из %d файлов, пропущено %d, с ошибками %d: %s за %s\n",
+	"Error: %s requires --token, $YADISK_TOKEN or auth login\n":         "Ошибка: для %s нужен --token, $YADISK_TOKEN или auth login\n",
+	"Error: upload takes a local file or folder and a path on the disk": "Ошибка: upload принимает локальный файл или папку и путь на диске",
+
+	// copy, move, delete
+	"Copied %s to %s":       "%s скопирован в %s",
+	"Moved %s to %s":        "%s перемещён в %s",
+	"Deleted %s":            "Удалён %s",
+	"Moved %s to the Trash": "%s перемещён в Корзину",
+	"Error: %s takes the path to take and the path to put it on the disk\n": "Ошибка: %s принимает исходный путь и путь назначения на диске\n",
+	"Error: delete takes the paths on the disk to delete":                   "Ошибка: delete принимает пути на диске, которые нужно удалить",
+
+	// trash
+	"The Trash is empty":              "Корзина пуста",
+	"folder":                          "папка",
+	"%s  %10s  %s, deleted from %s\n": "%s  %10s  %s, удалён из %s\n",
+	"Emptied the Trash":               "Корзина очищена",
+	"Restored %s from the Trash":      "%s восстановлен из Корзины",
+	"Deleted %s from the Trash":       "%s удалён из Корзины",
+	"Skipped %s, where it was deleted from exists on the disk":     "Пропущен %s, на месте, откуда он удалён, уже есть ресурс",
+	"Error: trash takes one of list, restore, empty":               "Ошибка: trash принимает одно из list, restore, empty",
+	"Error: trash list takes at most one path in the Trash":        "Ошибка: trash list принимает не больше одного пути в Корзине",
+	"Error: trash restore takes the paths in the Trash to restore": "Ошибка: trash restore принимает пути в Корзине, которые нужно восстановить",
+
+	"Saved the share to %s on your disk": "Ресурс сохранён на ваш диск в %s",
+	"Error: save requires --link":        "Ошибка: для save нужен --link",
+
+	// quota
+	"Disk of %s\n":                    "Диск %s\n",
+	"  Total:          %s\n":          "  Всего:          %s\n",
+	"  Used:           %s (%.1f%%)\n": "  Занято:         %s (%.1f%%)\n",
+	"  In the Trash:   %s\n":          "  В Корзине:      %s\n",
+	"  Free:           %s\n":          "  Свободно:       %s\n",
+	"  Max file size:  %s\n":          "  Макс. файл:     %s\n",
+
+	// auth
+	"Stored the token of profile %s in the keyring":    "Токен профиля %s сохранён в связке ключей",
+	"Removed the token of profile %s from the keyring": "Токен профиля %s удалён из связки ключей",
+	"No token of profile %s in the keyring":            "В связке ключей нет токена профиля %s",
+	"The keyring holds a token of profile %s":          "В связке ключей есть токен профиля %s",
+	"OAuth token: ": "OAuth-токен: ",
+	"Error: auth takes one of login, logout, status":   "Ошибка: auth принимает одно из: login, logout, status",
+	"Error: keyring: %v\n":                             "Ошибка: связка ключей: %v\n",
+	"Open %s and enter the code %s\n":                  "Откройте %s и введите код %s\n",
+	"It expires on %s":                                 "Он истекает %s",
+	"Warning: could not store the refreshed token: %v": "Предупреждение: не удалось сохранить обновлённый токен: %v",
+
+	// Errors
+	"Error: %v":               "Ошибка: %v",
+	"Error: %v\n":             "Ошибка: %v\n",
+	"Error: %s: %v":           "Ошибка: %s: %v",
+	"Error: link is required": "Ошибка: нужна ссылка",
+	"Error: files of your own disk need --token":                            "Ошибка: для файлов своего диска нужен --token",
+	"Error: %s belongs to a run of %s %s\n":                                 "Ошибка: %s относится к загрузке %s %s\n",
+	"Error: --max-duration reached while listing":                           "Ошибка: --max-duration истекло при получении списка файлов",
+	"Error: --%s must be at least 1\n":                                      "Ошибка: --%s должно быть не меньше 1\n",
+	"Error: --jobs must be at least 1":                                      "Ошибка: --jobs должно быть не меньше 1",
+	"Error: --exec-jobs must be at least 1":                                 "Ошибка: --exec-jobs должно быть не меньше 1",
+	"Error: --interval must be positive":                                    "Ошибка: --interval должно быть больше нуля",
+	"Error: --cache-ttl must not be negative":                               "Ошибка: --cache-ttl не может быть отрицательным",
+	"Error: --max-depth must not be negative":                               "Ошибка: --max-depth не может быть отрицательным",
+	"Error: --max-files must not be negative":                               "Ошибка: --max-files не может быть отрицательным",
+	"Error: --progress-interval must not be negative":                       "Ошибка: --progress-interval не может быть отрицательным",
+	"Error: --rate-limit must not be negative":                              "Ошибка: --rate-limit не может быть отрицательным",
+	"Error: --retry-passes must not be negative":                            "Ошибка: --retry-passes не может быть отрицательным",
+	"Error: --strip-components must not be negative":                        "Ошибка: --strip-components не может быть отрицательным",
+	"Error: --breaker-failures and --breaker-cooldown must not be negative": "Ошибка: --breaker-failures и --breaker-cooldown не могут быть отрицательными",
+	"Error: --min-size is greater than --max-size":                          "Ошибка: --min-size больше --max-size",
+	"Error: --newer-than must be earlier than --older-than":                 "Ошибка: --newer-than должно быть раньше --older-than",
+	"Error: --by-date: unknown value %q\n":                                  "Ошибка: --by-date: неизвестное значение %q\n",
+	"Error: --exec-errors: unknown value %q\n":                              "Ошибка: --exec-errors: неизвестное значение %q\n",
+	"Error: --over-limit: unknown value %q\n":                               "Ошибка: --over-limit: неизвестное значение %q\n",
+	"Error: --notify-url: %q is not an http(s) URL\n":                       "Ошибка: --notify-url: %q не является адресом http(s)\n",
+	"Error: --ca-cert: %v\n":                                                "Ошибка: --ca-cert: %v\n",
+	"Error: --cas-tree: %v\n":                                               "Ошибка: --cas-tree: %v\n",
+	"Error: --checksums: %v\n":                                              "Ошибка: --checksums: %v\n",
+	"Error: --compress: %v\n":                                               "Ошибка: --compress: %v\n",
+	"Error: --encrypt-to: %v\n":                                             "Ошибка: --encrypt-to: %v\n",
+	"Error: --if-exists: %v\n":                                              "Ошибка: --if-exists: %v\n",
+	"Error: --infected: %v\n":                                               "Ошибка: --infected: %v\n",
+	"Error: --link2: %v\n":                                                  "Ошибка: --link2: %v\n",
+	"Error: --links-file: %v\n":                                             "Ошибка: --links-file: %v\n",
+	"Error: --links-folder: %v\n":                                           "Ошибка: --links-folder: %v\n",
+	"Error: --name-template: %v\n":                                          "Ошибка: --name-template: %v\n",
+	"Error: --retry-from: %v\n":                                             "Ошибка: --retry-from: %v\n",
+	"Error: --sort: %v\n":                                                   "Ошибка: --sort: %v\n",
+	"Error: completion: %v\n":                                               "Ошибка: completion: %v\n",
+	"Error: completion takes one of %s\n":                                   "Ошибка: completion принимает одно из: %s\n",
+	"Error: %s cannot be used with --cache-ttl or --retry-from\n":           "Ошибка: %s нельзя использовать с --cache-ttl или --retry-from\n",
+	"Error: %s cannot be used with --flatten, --by-date, --strip-components or --name-template\n":                                          "Ошибка: %s нельзя использовать с --flatten, --by-date, --strip-components или --name-template\n",
+	"Error: %s cannot be used with --links-file\n":                                                                                         "Ошибка: %s нельзя использовать с --links-file\n",
+	"Error: --cas-objects cannot be used with --flatten, --by-date, --strip-components, --name-template, --preview-size or --served-names": "Ошибка: --cas-objects нельзя использовать с --flatten, --by-date, --strip-components, --name-template, --preview-size или --served-names",
+	"Error: --checksums cannot be used with --preview-size":                                                                                "Ошибка: --checksums нельзя использовать с --preview-size",
+	"Error: --links-file cannot be used with --link, --retry-from or --pick":                                                               "Ошибка: --links-file нельзя использовать с --link, --retry-from или --pick",
+	"Error: --links-file cannot be used with --queue-db, --report-file or --retry-file":                                                    "Ошибка: --links-file нельзя использовать с --queue-db, --report-file или --retry-file",
+	"Error: --links-file requires --output":                                                                                                "Ошибка: для --links-file нужен --output",
+	"Error: --pick cannot be used with --resume or --retry-from":                                                                           "Ошибка: --pick нельзя использовать с --resume или --retry-from",
+	"Error: --pipeline, diff and save take a single --path":                                                                                "Ошибка: --pipeline, diff и save принимают один --path",
+	"Error: --pipeline cannot be used with --flatten, --by-date, --name-template or --pick":                                                "Ошибка: --pipeline нельзя использовать с --flatten, --by-date, --name-template или --pick",
+	"Error: --pipeline cannot be used with --resume, --queue-db, --retry-from, --checksums, --sort, --preview-size or --max-depth":         "Ошибка: --pipeline нельзя использовать с --resume, --queue-db, --retry-from, --checksums, --sort, --preview-size или --max-depth",
+	"Error: --pipeline requires --output":                                                                                                  "Ошибка: для --pipeline нужен --output",
+	"Error: --resume, --queue-db and --retry-from require --output":                                                                        "Ошибка: для --resume, --queue-db и --retry-from нужен --output",
+	"Error: --since last cannot be used with --pipeline":                                                                                   "Ошибка: --since last нельзя использовать с --pipeline",
+	"Error: --since last requires --output":                                                                                                "Ошибка: для --since last нужен --output",
+	"Error: --slack-channel requires --slack-token or $SLACK_TOKEN":                                                                        "Ошибка: для --slack-channel нужен --slack-token или $SLACK_TOKEN",
+	"Error: --telegram-chat requires --telegram-token or $TELEGRAM_BOT_TOKEN":                                                              "Ошибка: для --telegram-chat нужен --telegram-token или $TELEGRAM_BOT_TOKEN",
+	"Error: --tui cannot be used with --json or serve":                                                                                     "Ошибка: --tui нельзя использовать с --json или serve",
+	"Error: --tui needs a terminal":                                                                                                        "Ошибка: для --tui нужен терминал",
+	"Error: --tui shows one link at a time, use it without --jobs":                                                                         "Ошибка: --tui показывает одну ссылку за раз, используйте его без --jobs",
+	"Error: diff requires --link2":                                                                                                         "Ошибка: для diff нужен --link2",
+	"Error: diff-local requires --output":                                                                                                  "Ошибка: для diff-local нужен --output",
+	"Error: serve cannot be used with --resume, --retry-from, --pipeline, --checksums, --cache-ttl, --since last or --pick":                "Ошибка: serve нельзя использовать с --resume, --retry-from, --pipeline, --checksums, --cache-ttl, --since last или --pick",
+	"Error: serve shows progress with GET /jobs, not --status-addr":                                                                        "Ошибка: serve показывает прогресс через GET /jobs, а не --status-addr",
+	"Error: --status-addr: %v\n":                                                                                                           "Ошибка: --status-addr: %v\n",
+	"Error: serve requires --api-secret or $YADOWNLOAD_API_SECRET":                                                                         "Ошибка: для serve нужен --api-secret или $YADOWNLOAD_API_SECRET",
+	"Error: serve requires --output":                                                                                                       "Ошибка: для serve нужен --output",
+	"Error: serve takes links and paths with each job, not --link and --path":                                                              "Ошибка: serve получает ссылки и пути с каждым заданием, а не через --link и --path",
+	"Error: use only one of --flatten, --by-date, --strip-components and --name-template":                                                  "Ошибка: используйте только один из --flatten, --by-date, --strip-components и --name-template",
+	"Error: watch cannot be used with --flatten, --by-date or --name-template":                                                             "Ошибка: watch нельзя использовать с --flatten, --by-date или --name-template",
+	"Error: watch cannot be used with --pick":                                                                                              "Ошибка: watch нельзя использовать с --pick",
+	"Error: watch cannot be used with --resume, --retry-from, --pipeline, --checksums, --cache-ttl or --since last":                        "Ошибка: watch нельзя использовать с --resume, --retry-from, --pipeline, --checksums, --cache-ttl или --since last",
+	"Error: watch requires --output":                                                                                                       "Ошибка: для watch нужен --output",
+
+	// stat, duplicates, diff, --pick and --tui
+	"%s  (%s, %d files)\n":                  "%s  (%s, файлов: %d)\n",
+	"%s%s%s/  (%s, %d files)\n":             "%s%s%s/  (%s, файлов: %d)\n",
+	"  %-*s  %10s  %5.1f%%  %d files\n":     "  %-*s  %10s  %5.1f%%  файлов: %d\n",
+	"By folder":                             "По папкам",
+	"By extension":                          "По расширениям",
+	"\nLargest files\n":                     "\nСамые большие файлы\n",
+	"%d copies of %s (md5 %s), %s wasted\n": "Копий: %d по %s (md5 %s), лишние %s\n",
+	"%d of %d files are extra copies in %d groups, wasting %s\n":     "Лишних копий: %d из %d файлов в группах: %d, занимают %s\n",
+	"%d added, %d removed, %d changed\n":                             "Добавлено: %d, удалено: %d, изменено: %d\n",
+	"%d of %d files marked, %s":                                      "Отмечено файлов: %d из %d, %s",
+	"space mark  a all  enter open  arrows move  d download  q quit": "пробел отметить  a все  enter открыть  стрелки перейти  d загрузить  q выйти",
+	"yadownload - %s, running %s":                                    "yadownload - %s, идёт %s",
+	"%s downloaded at %s/s":                                          "загружено %s, %s/с",
+	"Files: %d done, %d skipped, %d failed, %d running, %d queued":   "Файлы: готово %d, пропущено %d, с ошибкой %d, загружается %d, в очереди %d",
+	"Transfers":     "Загрузки",
+	"  and %d more": "  и ещё %d",
+	"Log":           "Журнал",
+}
@@ -0,0 +1,188 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+// notifyTimeout bounds sending one notification everywhere.
+const notifyTimeout = 30 * time.Second
+
+// notification is POSTed as JSON to --notify-url when a download finishes,
+// and sent to Telegram and Slack as text.
+type notification struct {
+	Event  string           `json:"event"`
+	Status string           `json:"status"`
+	Job    string           `json:"job,omitempty"`
+	Link   string           `json:"link,omitempty"`
+	Path   string           `json:"path"`
+	Output string           `json:"output"`
+	Error  string           `json:"error,omitempty"`
+	Report yadloader.Report `json:"report"`
+}
+
+// runStatus is the notification status of a run ending with err.
+func runStatus(ctx context.Context, err error) string {
+	switch {
+	case ctx.Err() != nil:
+		return "interrupted"
+	case err != nil:
+		return "failed"
+	}
+	return "done"
+}
+
+func newNotification(params *Args, status string, report yadloader.Report, err error) notification {
+	n := notification{
+		Event:  "run_finished",
+		Status: status,
+		Link:   params.Link,
+		Path:   params.Path,
+		Output: params.Folder,
+		Report: report,
+	}
+	if err != nil {
+		n.Error = err.Error()
+	}
+	return n
+}
+
+// text renders n as a chat message.
+func (n notification) text() string {
+	var b strings.Builder
+	if n.Job != "" {
+		fmt.Fprintf(&b, tr("Job %s: "), n.Job)
+	}
+	source := n.Link
+	if source == "" {
+		source = "disk:"
+	}
+	r := n.Report
+	fmt.Fprintf(&b, tr("download of %s%s into %s %s: %d downloaded, %d skipped, %d failed, %s in %s"),
+		source, n.Path, n.Output, n.Status, r.Downloaded, r.Skipped, r.Failed, formatSize(r.Bytes), r.Duration.Round(time.Second))
+	if n.Error != "" {
+		fmt.Fprintf(&b, tr("\nError: %s"), shorten(n.Error, 500))
+	}
+	return b.String()
+}
+
+func shorten(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// notifier delivers a notification to one destination.
+type notifier struct {
+	name string
+	send func(ctx context.Context, n notification) error
+}
+
+func notifiers(params *Args) []notifier {
+	var list []notifier
+	if params.NotifyURL != "" {
+		list = append(list, notifier{params.NotifyURL, func(ctx context.Context, n notification) error {
+			return postJSON(ctx, params.NotifyURL, "", n, nil)
+		}})
+	}
+	if params.TelegramChat != "" {
+		list = append(list, notifier{"Telegram", func(ctx context.Context, n notification) error {
+			return sendTelegram(ctx, params.TelegramToken, params.TelegramChat, n.text())
+		}})
+	}
+	if params.SlackChannel != "" {
+		list = append(list, notifier{"Slack", func(ctx context.Context, n notification) error {
+			return sendSlack(ctx, params.SlackToken, params.SlackChannel, n.text())
+		}})
+	}
+	return list
+}
+
+// notify sends n to --notify-url, Telegram and Slack, as set. Failures are
+// only logged: the download itself is over by now.
+func notify(params *Args, n notification) {
+	// The run context may be cancelled already, the notification is still due.
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	for _, nt := range notifiers(params) {
+		if err := nt.send(ctx, n); err != nil {
+			log.Printf(tr("Failed to notify %s: %v"), nt.name, err)
+		}
+	}
+}
+
+// sendTelegram posts text to chat as the bot with token.
+func sendTelegram(ctx context.Context, token, chat, text string) error {
+	var resp struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	body := map[string]any{"chat_id": chat, "text": text, "disable_web_page_preview": true}
+	err := postJSON(ctx, "https://api.telegram.org/bot"+token+"/sendMessage", "", body, &resp)
+	if err == nil && !resp.OK {
+		err = fmt.Errorf("telegram: %s", resp.Description)
+	}
+	return err
+}
+
+// sendSlack posts text to channel with a bot token.
+func sendSlack(ctx context.Context, token, channel, text string) error {
+	var resp struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+	}
+	body := map[string]any{"channel": channel, "text": text}
+	err := postJSON(ctx, "https://slack.com/api/chat.postMessage", "Bearer "+token, body, &resp)
+	if err == nil && !resp.OK {
+		err = fmt.Errorf("slack: %s", resp.Error)
+	}
+	return err
+}
+
+// postJSON posts v to url and decodes the answer into out, if not nil. The
+// URL is left out of errors, it may hold a token.
+func postJSON(ctx context.Context, url, auth string, v, out any) error {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "yadloader")
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		var uerr *neturl.Error
+		if errors.As(err, &uerr) {
+			err = uerr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	if out != nil {
+		if derr := json.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(out); derr == nil {
+			return nil
+		}
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("POST: %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/brandquad/yadloader-go"
+	"golang.org/x/term"
+)
+
+var errPickCancelled = errors.New("picking cancelled")
+
+// setMarked marks or unmarks n and every file below it.
+func (n *fileNode) setMarked(marked bool) {
+	if n.dir() {
+		for _, c := range n.children {
+			c.setMarked(marked)
+		}
+		return
+	}
+	if (n.marked == 1) == marked {
+		return
+	}
+	delta, size := 1, n.size
+	if !marked {
+		delta, size = -1, -size
+	}
+	for m := n; m != nil; m = m.parent {
+		m.marked += delta
+		m.markedSize += size
+	}
+}
+
+func (n *fileNode) visible(rows []*fileNode) []*fileNode {
+	for _, c := range n.children {
+		rows = append(rows, c)
+		if c.dir() && c.open {
+			rows = c.visible(rows)
+		}
+	}
+	return rows
+}
+
+func (n *fileNode) selection(paths map[string]bool) {
+	if !n.dir() {
+		if n.marked == 1 {
+			paths[n.file.Path] = true
+		}
+		return
+	}
+	for _, c := range n.children {
+		c.selection(paths)
+	}
+}
+
+// picker lets the user mark files in the listing on the terminal.
+type picker struct {
+	root   *fileNode
+	rows   []*fileNode
+	cursor int
+	top    int
+	status string
+	out    *bufio.Writer
+}
+
+// pick shows files as a tree on the terminal and returns the marked ones,
+// in listing order.
+func pick(files []yadloader.DiskFile) ([]yadloader.DiskFile, error) {
+	in, outFile := int(os.Stdin.Fd()), int(os.Stderr.Fd())
+	if !term.IsTerminal(in) || !term.IsTerminal(outFile) {
+		return nil, errors.New("--pick needs a terminal")
+	}
+	state, err := term.MakeRaw(in)
+	if err != nil {
+		return nil, err
+	}
+	defer term.Restore(in, state)
+
+	p := &picker{root: buildFileTree(files), out: bufio.NewWriter(os.Stderr)}
+	// A share with a single top folder starts with it open.
+	if top := p.root.children; len(top) == 1 && top[0].dir() {
+		top[0].open = true
+	}
+	p.rows = p.root.visible(nil)
+	// Alternate screen, hidden cursor; both undone on return.
+	fmt.Fprint(p.out, "\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Fprint(p.out, "\x1b[?25h\x1b[?1049l")
+		p.out.Flush()
+	}()
+
+	keys := bufio.NewReader(os.Stdin)
+	for {
+		width, height, err := term.GetSize(outFile)
+		if err != nil {
+			width, height = 80, 24
+		}
+		p.draw(width, height)
+		key, err := readKey(keys)
+		if err != nil {
+			return nil, err
+		}
+		done, err := p.handle(key, height)
+		if err != nil {
+			return nil, err
+		}
+		if done {
+			marked := make(map[string]bool, p.root.marked)
+			p.root.selection(marked)
+			return slices.DeleteFunc(slices.Clone(files), func(f yadloader.DiskFile) bool { return !marked[f.Path] }), nil
+		}
+	}
+}
+
+// handle applies a key and reports whether picking is done.
+func (p *picker) handle(key string, height int) (bool, error) {
+	p.status = ""
+	page := max(height-3, 1)
+	var cur *fileNode
+	if len(p.rows) > 0 {
+		cur = p.rows[p.cursor]
+	}
+	switch key {
+	case "up", "k":
+		p.cursor--
+	case "down", "j":
+		p.cursor++
+	case "pgup":
+		p.cursor -= page
+	case "pgdown":
+		p.cursor += page
+	case "home", "g":
+		p.cursor = 0
+	case "end", "G":
+		p.cursor = len(p.rows) - 1
+	case "right", "l", "enter":
+		if cur != nil && cur.dir() {
+			cur.open = key == "right" || key == "l" || !cur.open
+		}
+	case "left", "h":
+		switch {
+		case cur == nil:
+		case cur.dir() && cur.open:
+			cur.open = false
+		case cur.parent != p.root:
+			cur.parent.open = false
+			cur = cur.parent
+		}
+	case " ":
+		if cur != nil {
+			cur.setMarked(cur.marked < cur.total)
+			p.cursor++
+		}
+	case "a":
+		p.root.setMarked(p.root.marked < p.root.total)
+	case "d":
+		if p.root.marked == 0 {
+			p.status = "Mark files with space first"
+			return false, nil
+		}
+		return true, nil
+	case "q", "esc", "ctrl-c":
+		return false, errPickCancelled
+	}
+
+	p.rows = p.root.visible(nil)
+	if cur != nil && (key == "left" || key == "h") {
+		p.cursor = slices.Index(p.rows, cur)
+	}
+	p.cursor = max(min(p.cursor, len(p.rows)-1), 0)
+	return false, nil
+}
+
+func (p *picker) draw(width, height int) {
+	list := max(height-2, 1)
+	switch {
+	case p.cursor < p.top:
+		p.top = p.cursor
+	case p.cursor >= p.top+list:
+		p.top = p.cursor - list + 1
+	}
+
+	fmt.Fprint(p.out, "\x1b[H\x1b[2J")
+	header := fmt.Sprintf(tr("%d of %d files marked, %s"), p.root.marked, p.root.total, formatSize(p.root.markedSize))
+	if p.status != "" {
+		header += " - " + p.status
+	}
+	fmt.Fprint(p.out, fit(header, width), "\r\n")
+	for i := p.top; i < min(p.top+list, len(p.rows)); i++ {
+		n := p.rows[i]
+		mark := "[ ]"
+		switch {
+		case n.marked == n.total:
+			mark = "[x]"
+		case n.marked > 0:
+			mark = "[-]"
+		}
+		name := n.name
+		if n.dir() {
+			arrow := "+ "
+			if n.open {
+				arrow = "- "
+			}
+			name = arrow + name + "/"
+		} else {
+			name = "  " + name
+		}
+		row := fit(fmt.Sprintf("%s%s %s  %s", strings.Repeat("  ", n.depth), mark, name, formatSize(n.size)), width)
+		if i == p.cursor {
+			row = "\x1b[7m" + row + "\x1b[0m"
+		}
+		fmt.Fprint(p.out, row, "\r\n")
+	}
+	fmt.Fprintf(p.out, "\x1b[%d;1H", height)
+	fmt.Fprint(p.out, fit(tr("space mark  a all  enter open  arrows move  d download  q quit"), width))
+	p.out.Flush()
+}
+
+// fit cuts s to width columns.
+func fit(s string, width int) string {
+	if r := []rune(s); len(r) > width {
+		return string(r[:max(width-1, 0)]) + "…"
+	}
+	return s
+}
+
+// readKey reads one key press, naming the special keys the picker uses.
+func readKey(r *bufio.Reader) (string, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return "", err
+	}
+	switch b {
+	case 3:
+		return "ctrl-c", nil
+	case '\r', '\n':
+		return "enter", nil
+	case 0x1b:
+		if r.Buffered() == 0 {
+			return "esc", nil
+		}
+		seq := []byte{}
+		for r.Buffered() > 0 {
+			c, _ := r.ReadByte()
+			seq = append(seq, c)
+			if len(seq) > 1 && (c >= 'A' && c <= 'Z' || c == '~') {
+				break
+			}
+		}
+		switch string(seq) {
+		case "[A", "OA":
+			return "up", nil
+		case "[B", "OB":
+			return "down", nil
+		case "[C", "OC":
+			return "right", nil
+		case "[D", "OD":
+			return "left", nil
+		case "[5~":
+			return "pgup", nil
+		case "[6~":
+			return "pgdown", nil
+		case "[H", "[1~", "OH":
+			return "home", nil
+		case "[F", "[4~", "OF":
+			return "end", nil
+		}
+		return "", nil
+	}
+	return string(rune(b)), nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+// runPipeline downloads files while they are being listed. Without the full
+// list up front there is no state to resume from, only the retry file.
+func runPipeline(ctx context.Context, client *yadloader.YaDiskClient, params *Args, events reporter, pastDeadline func() bool) int {
+	if err := makeFolder(params.Folder, 0755); err != nil {
+		return setupError(err)
+	}
+	unlock, err := lockOutput(ctx, params)
+	if err != nil {
+		if ctx.Err() != nil {
+			return exitInterrupted
+		}
+		log.Printf(tr("Error: %v"), err)
+		return errorClass(err, exitError)
+	}
+	defer unlock()
+
+	var totalSize int64
+	progress := func(e yadloader.Event) {
+		switch e.Type {
+		case yadloader.EventStarted:
+			warnInfected(params, e.File)
+			totalSize += e.File.Size
+			if params.JSON {
+				events.Discovered(e.File)
+			}
+			events.Started(e.File, e.Dest)
+		case yadloader.EventSkipped:
+			totalSize += e.File.Size
+			events.Skipped(e.File, e.Dest)
+		case yadloader.EventProgress:
+			// The size of the share is not known until listing ends.
+			events.Progress(e, -1)
+		case yadloader.EventFinished:
+			events.Finished(e.File, e.Dest, e.Elapsed)
+		case yadloader.EventFailed:
+			if !errors.Is(e.Err, context.Canceled) && !errors.Is(e.Err, errOverLimit) {
+				events.Failed(e.File, e.Dest, e.Err)
+			}
+		}
+	}
+
+	var hooks yadloader.Hooks
+	ctx, stop := context.WithCancelCause(ctx)
+	defer stop(nil)
+	caps := &pipelineCaps{params: params, stop: stop}
+	if params.limited() {
+		// The files are not known up front, so the limits apply as they
+		// come.
+		hooks.BeforeFile = caps.beforeFile
+	}
+
+	opts := append(downloadOptions(params, hooks), yadloader.WithPipeline(true), yadloader.WithProgress(progress))
+	report, err := client.DownloadTree(ctx, params.Link, params.Path, params.Folder, opts...)
+	warnMismatches(report)
+	overLimit := errors.Is(context.Cause(ctx), errOverLimit)
+	// A run that skipped files over the limits has not downloaded
+	// everything. Pipelined runs never record a last run for --since last,
+	// so the skips are only reported.
+	if caps.skipped > 0 {
+		log.Printf(tr("Skipped %d files over --max-files or --max-total-size"), caps.skipped)
+	}
+	events.Summary(summary{Files: report.Files, TotalSize: totalSize, Report: report})
+	if params.ReportFile != "" {
+		if rerr := writeReport(params.ReportFile, report); rerr != nil {
+			log.Printf(tr("Failed to write report: %v"), rerr)
+		}
+	}
+	if ctx.Err() == nil {
+		saveRetryFile(params, report)
+	}
+	status := runStatus(ctx, err)
+	if overLimit {
+		status = "failed"
+	}
+	notify(params, newNotification(params, status, report, err))
+
+	switch {
+	case overLimit:
+		log.Print(tr("Stopped at --max-files or --max-total-size, use --over-limit skip to download only the first files"))
+		return 1
+	case ctx.Err() != nil:
+		log.Print(tr("Interrupted"))
+		return exitInterrupted
+	case err != nil && pastDeadline():
+		log.Print(tr("Stopped by --max-duration"))
+		return exitGaveUp
+	case err != nil && len(report.Failures) == 0:
+		log.Printf(tr("Error: %v"), err)
+	}
+	return exitCode(err, report)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// profileSetting is a flag value of the config file, from its line.
+type profileSetting struct {
+	name  string
+	value string
+	line  int
+}
+
+// defaultConfigFile is the config file read without --config.
+func defaultConfigFile() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "yadownload", "config")
+}
+
+// readProfile reads the settings of the profile name from a config file.
+// Lines are flag = value, or a bare flag name for boolean flags, with
+// [name] starting the settings of a profile. Settings before the first
+// profile apply to all of them unless the profile sets the flag itself.
+// Blank lines and lines starting with # are left out.
+func readProfile(r io.Reader, name string) ([]profileSetting, error) {
+	var (
+		common, named []profileSetting
+		section       string
+		found         = name == ""
+	)
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+			continue
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: unterminated profile name", n)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			found = found || section == name
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			value = "true"
+		}
+		s := profileSetting{name: strings.TrimPrefix(strings.TrimSpace(key), "--"), value: strings.TrimSpace(value), line: n}
+		switch section {
+		case "":
+			common = append(common, s)
+		case name:
+			named = append(named, s)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("no profile [%s]", name)
+	}
+
+	own := make(map[string]bool)
+	for _, s := range named {
+		own[s.name] = true
+	}
+	settings := named[:0:0]
+	for _, s := range common {
+		if !own[s.name] {
+			settings = append(settings, s)
+		}
+	}
+	return append(settings, named...), nil
+}
+
+// applyProfile sets the flags of the profile name in filename that the
+// command line left alone. A missing config file is only an error when it
+// was asked for by --config or a profile is selected.
+func applyProfile(filename, name string, explicit bool) error {
+	f, err := os.Open(filename)
+	if errors.Is(err, os.ErrNotExist) && !explicit && name == "" {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	settings, err := readProfile(f, name)
+	if err != nil {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
+	// Shorthands such as -o share the value of their flag.
+	given := make(map[flag.Value]bool)
+	flag.Visit(func(f *flag.Flag) { given[f.Value] = true })
+	for _, s := range settings {
+		fl := flag.Lookup(s.name)
+		switch {
+		case fl == nil || s.name == "config" || s.name == "profile":
+			return fmt.Errorf("%s:%d: unknown flag %q", filename, s.line, s.name)
+		case given[fl.Value]:
+			continue
+		}
+		if err := fl.Value.Set(s.value); err != nil {
+			return fmt.Errorf("%s:%d: --%s: %w", filename, s.line, s.name, err)
+		}
+	}
+	return nil
+}
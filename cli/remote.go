@@ -0,0 +1,180 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+// copyOrMove runs copy or move of the from and to paths of the disk of
+// --token. Only --if-exists overwrite replaces what is at to, and skip
+// leaves it alone without failing.
+func copyOrMove(ctx context.Context, client *yadloader.YaDiskClient, params *Args, op, from, to string) int {
+	run := client.Copy
+	done := tr("Copied %s to %s")
+	if op == "move" {
+		run = client.Move
+		done = tr("Moved %s to %s")
+	}
+	err := run(ctx, from, to, params.IfExists == "overwrite")
+	var apiErr *yadloader.APIError
+	switch {
+	case errors.As(err, &apiErr) && apiErr.Code == "DiskResourceAlreadyExistsError" && params.IfExists == "skip":
+		log.Printf(tr("Skipped %s, %s exists on the disk"), from, to)
+	case err != nil:
+		return remoteError(ctx, err)
+	default:
+		log.Printf(done, from, to)
+	}
+	return exitOK
+}
+
+// remove runs delete of paths of the disk of --token, moving them to the
+// Trash unless --permanent is set. It goes on after a failed path.
+func remove(ctx context.Context, client *yadloader.YaDiskClient, params *Args, paths []string) int {
+	code := exitOK
+	for _, p := range paths {
+		if err := client.Delete(ctx, p, params.Permanent); err != nil {
+			if code = remoteError(ctx, err); code == exitInterrupted {
+				return code
+			}
+			continue
+		}
+		if params.Permanent {
+			log.Printf(tr("Deleted %s"), p)
+		} else {
+			log.Printf(tr("Moved %s to the Trash"), p)
+		}
+	}
+	return code
+}
+
+func remoteError(ctx context.Context, err error) int {
+	if ctx.Err() != nil {
+		log.Print(tr("Interrupted"))
+		return exitInterrupted
+	}
+	log.Printf(tr("Error: %v"), err)
+	return errorClass(err, exitError)
+}
+
+// save copies --link, or its --path, into --save-path of the disk of
+// --token and returns the path of the copy, which --output downloads.
+func save(ctx context.Context, client *yadloader.YaDiskClient, params *Args) (string, int) {
+	saved, err := client.SaveToDisk(ctx, params.Link, params.Path, params.SavePath)
+	if err != nil {
+		return "", remoteError(ctx, err)
+	}
+	log.Printf(tr("Saved the share to %s on your disk"), saved)
+	return saved, exitOK
+}
+
+// trash runs trash list, restore or empty on the Trash of the disk of
+// --token. Restore puts paths back where they were deleted from, with
+// --if-exists deciding about what is there now, as for copy.
+func trash(ctx context.Context, client *yadloader.YaDiskClient, params *Args, action string, paths []string) int {
+	switch action {
+	case "list":
+		items, err := client.ListTrash(ctx, cmp.Or(paths...))
+		if err != nil {
+			return remoteError(ctx, err)
+		}
+		if params.JSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(items); err != nil {
+				log.Printf(tr("Error: %v"), err)
+				return exitError
+			}
+			return exitOK
+		}
+		if len(items) == 0 {
+			log.Print(tr("The Trash is empty"))
+		}
+		for _, i := range items {
+			size := formatSize(i.Size)
+			if i.IsDir() {
+				size = tr("folder")
+			}
+			// Only the top of the Trash knows where it came from.
+			if i.OriginPath == "" {
+				fmt.Printf("%19s  %10s  %s\n", "", size, i.Path)
+				continue
+			}
+			fmt.Printf(tr("%s  %10s  %s, deleted from %s\n"), i.Deleted.Local().Format(time.DateTime), size, i.Path, i.OriginPath)
+		}
+		return exitOK
+	case "empty":
+		if len(paths) == 0 {
+			if err := client.EmptyTrash(ctx, ""); err != nil {
+				return remoteError(ctx, err)
+			}
+			log.Print(tr("Emptied the Trash"))
+			return exitOK
+		}
+	}
+
+	code := exitOK
+	for _, p := range paths {
+		var err error
+		if action == "restore" {
+			err = client.RestoreTrash(ctx, p, "", params.IfExists == "overwrite")
+		} else {
+			err = client.EmptyTrash(ctx, p)
+		}
+		var apiErr *yadloader.APIError
+		switch {
+		case errors.As(err, &apiErr) && apiErr.Code == "DiskResourceAlreadyExistsError" && params.IfExists == "skip":
+			log.Printf(tr("Skipped %s, where it was deleted from exists on the disk"), p)
+		case err != nil:
+			if code = remoteError(ctx, err); code == exitInterrupted {
+				return code
+			}
+		case action == "restore":
+			log.Printf(tr("Restored %s from the Trash"), p)
+		default:
+			log.Printf(tr("Deleted %s from the Trash"), p)
+		}
+	}
+	return code
+}
+
+// quota prints the space of the disk of --token, or with --json the
+// DiskInfo.
+func quota(ctx context.Context, client *yadloader.YaDiskClient, params *Args) int {
+	info, err := client.GetDiskInfo(ctx)
+	if err != nil {
+		return remoteError(ctx, err)
+	}
+	if params.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(info); err != nil {
+			log.Printf(tr("Error: %v"), err)
+			return exitError
+		}
+		return exitOK
+	}
+	percent := 0.0
+	if info.TotalSpace > 0 {
+		percent = 100 * float64(info.UsedSpace) / float64(info.TotalSpace)
+	}
+	if user := cmp.Or(info.User.DisplayName, info.User.Login); user != "" {
+		fmt.Printf(tr("Disk of %s\n"), user)
+	}
+	fmt.Printf(tr("  Total:          %s\n"), formatSize(info.TotalSpace))
+	fmt.Printf(tr("  Used:           %s (%.1f%%)\n"), formatSize(info.UsedSpace), percent)
+	fmt.Printf(tr("  In the Trash:   %s\n"), formatSize(info.TrashSize))
+	fmt.Printf(tr("  Free:           %s\n"), formatSize(info.FreeSpace()))
+	if info.MaxFileSize > 0 {
+		fmt.Printf(tr("  Max file size:  %s\n"), formatSize(info.MaxFileSize))
+	}
+	return exitOK
+}
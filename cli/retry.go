@@ -0,0 +1,66 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"os"
+	"time"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+// retryFileName is where files still failing at the end of a run are listed
+// unless --retry-file says otherwise.
+const retryFileName = ".yadloader-retry.json"
+
+type retryFile struct {
+	Link    string               `json:"link"`
+	Path    string               `json:"path"`
+	Created time.Time            `json:"created"`
+	Files   []yadloader.DiskFile `json:"files"`
+}
+
+func loadRetryFile(filename string) (*retryFile, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var r retryFile
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
+}
+
+// writeRetryFile lists files for a later --retry-from run. Download links
+// expire, so they are dropped and requested again on retry. Without files a
+// leftover retry file is removed.
+func writeRetryFile(filename, link, path string, files []yadloader.DiskFile) error {
+	if len(files) == 0 {
+		if err := os.Remove(filename); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	r := retryFile{Link: link, Path: path, Created: time.Now(), Files: make([]yadloader.DiskFile, len(files))}
+	for i, f := range files {
+		f.File = ""
+		r.Files[i] = f
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+func saveRetryFile(params *Args, report yadloader.Report) {
+	retry := report.Retryable()
+	if err := writeRetryFile(params.RetryFile, params.Link, params.Path, retry); err != nil {
+		log.Printf(tr("Failed to write retry file: %v"), err)
+	} else if len(retry) > 0 {
+		log.Printf(tr("%d files can be retried with --retry-from %s"), len(retry), params.RetryFile)
+	}
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+// Job states reported by the serve API.
+const (
+	jobQueued    = "queued"
+	jobListing   = "listing"
+	jobRunning   = "running"
+	jobDone      = "done"
+	jobFailed    = "failed"
+	jobCancelled = "cancelled"
+)
+
+// job is a download submitted to serve. Its exported fields are the JSON
+// the API answers with and are guarded by mu.
+type job struct {
+	mu     sync.Mutex
+	cancel context.CancelFunc
+
+	ID         string            `json:"id"`
+	Link       string            `json:"link,omitempty"`
+	Path       string            `json:"path"`
+	Output     string            `json:"output"`
+	State      string            `json:"state"`
+	Created    time.Time         `json:"created"`
+	Finished   *time.Time        `json:"finished,omitempty"`
+	Files      int64             `json:"files"`
+	TotalSize  int64             `json:"total_size"`
+	Downloaded int               `json:"downloaded"`
+	Skipped    int               `json:"skipped"`
+	Failed     int               `json:"failed"`
+	Bytes      int64             `json:"bytes"`
+	Speed      float64           `json:"bytes_per_second"`
+	Error      string            `json:"error,omitempty"`
+	Report     *yadloader.Report `json:"report,omitempty"`
+}
+
+func (j *job) MarshalJSON() ([]byte, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	type plain job
+	return json.Marshal((*plain)(j))
+}
+
+func (j *job) update(fn func(j *job)) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	fn(j)
+}
+
+func (j *job) finished() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.Finished != nil
+}
+
+func (j *job) progress(e yadloader.Event) {
+	j.update(func(j *job) {
+		switch e.Type {
+		case yadloader.EventProgress:
+			j.Speed = e.Total.Average
+		case yadloader.EventSkipped:
+			j.Skipped++
+		case yadloader.EventFinished:
+			j.Downloaded++
+			j.Bytes += e.File.Size
+		case yadloader.EventFailed:
+			if !errors.Is(e.Err, context.Canceled) {
+				j.Failed++
+			}
+		}
+	})
+}
+
+// jobRequest is the body of POST /jobs. Output is a folder inside --output,
+// the job ID by default.
+type jobRequest struct {
+	Link   string `json:"link"`
+	Path   string `json:"path"`
+	Output string `json:"output"`
+}
+
+// server runs the jobs of serve, up to --jobs at a time, with one client so
+// they share its rate limit. Jobs are kept in memory until exit.
+type server struct {
+	ctx    context.Context
+	client *yadloader.YaDiskClient
+	params *Args
+	slots  chan struct{}
+	wg     sync.WaitGroup
+	sd     *systemd
+
+	mu   sync.Mutex
+	jobs []*job
+	next int
+}
+
+// serve answers the job API on --listen until ctx is done, then cancels the
+// running jobs and waits for them. Every request needs the header
+// "Authorization: Bearer" with --api-secret. Jobs without a link download
+// the --token owner's own disk and are refused without --own-disk-jobs;
+// each job locks its output folder like --output of a download.
+//
+//	POST   /jobs       submit {"link", "path", "output"}
+//	GET    /jobs       list jobs, ?state=done for finished ones
+//	GET    /jobs/{id}  query a job
+//	DELETE /jobs/{id}  cancel a job
+//
+// Under systemd it reports readiness once listening and the number of
+// running jobs, and sends the watchdog pings.
+func serve(ctx context.Context, client *yadloader.YaDiskClient, params *Args) int {
+	if err := makeFolder(params.Folder, 0755); err != nil {
+		return setupError(err)
+	}
+	s := &server{ctx: ctx, client: client, params: params, slots: make(chan struct{}, params.Jobs), sd: newSystemd()}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /jobs", s.handleSubmit)
+	mux.HandleFunc("GET /jobs", s.handleList)
+	mux.HandleFunc("GET /jobs/{id}", s.handleGet)
+	mux.HandleFunc("DELETE /jobs/{id}", s.handleCancel)
+	srv := &http.Server{Handler: s.authorize(mux), ReadHeaderTimeout: 10 * time.Second}
+	ln, err := net.Listen("tcp", params.Listen)
+	if err != nil {
+		log.Printf(tr("Error: %v"), err)
+		return 1
+	}
+
+	errc := make(chan error, 1)
+	go func() { errc <- srv.Serve(ln) }()
+	log.Printf(tr("Serving jobs on %s, downloading into %s"), params.Listen, params.Folder)
+	s.sd.ping(ctx, func() bool { return true })
+	s.sd.ready(fmt.Sprintf(tr("Serving jobs on %s"), params.Listen))
+	defer s.sd.stopping()
+
+	select {
+	case err := <-errc:
+		log.Printf(tr("Error: %v"), err)
+		return 1
+	case <-ctx.Done():
+	}
+	shutdown, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = srv.Shutdown(shutdown)
+	s.wg.Wait()
+	log.Print(tr("Stopped serving"))
+	return 0
+}
+
+// authorize answers 401 to requests without the bearer --api-secret.
+func (s *server) authorize(next http.Handler) http.Handler {
+	want := []byte("Bearer " + s.params.APISecret)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), want) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="yadownload"`)
+			writeAPIError(w, http.StatusUnauthorized, errors.New("missing or wrong bearer secret"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	var req jobRequest
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16)).Decode(&req); err != nil {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("invalid body: %w", err))
+		return
+	}
+	if req.Link == "" && !s.params.OwnDiskJobs {
+		writeAPIError(w, http.StatusForbidden, errors.New("link is required when serve has no --own-disk-jobs"))
+		return
+	}
+	if req.Link != "" {
+		key, sub, err := yadloader.ParsePublicLink(req.Link)
+		if err != nil {
+			writeAPIError(w, http.StatusBadRequest, err)
+			return
+		}
+		req.Link, req.Path = key, path.Join(sub, req.Path)
+	}
+	if req.Output != "" && !filepath.IsLocal(req.Output) {
+		writeAPIError(w, http.StatusBadRequest, fmt.Errorf("output %q is not inside the serve folder", req.Output))
+		return
+	}
+
+	s.mu.Lock()
+	s.next++
+	id := strconv.Itoa(s.next)
+	if req.Output == "" {
+		req.Output = id
+	}
+	ctx, cancel := context.WithCancel(s.ctx)
+	j := &job{
+		cancel:  cancel,
+		ID:      id,
+		Link:    req.Link,
+		Path:    req.Path,
+		Output:  req.Output,
+		State:   jobQueued,
+		Created: time.Now().UTC(),
+	}
+	s.jobs = append(s.jobs, j)
+	s.wg.Add(1)
+	s.mu.Unlock()
+	s.reportStatus()
+
+	go s.run(ctx, j)
+	writeAPIJSON(w, http.StatusCreated, j)
+}
+
+func (s *server) handleList(w http.ResponseWriter, r *http.Request) {
+	state := r.URL.Query().Get("state")
+	s.mu.Lock()
+	jobs := slices.Clone(s.jobs)
+	s.mu.Unlock()
+
+	list := []*job{}
+	for _, j := range jobs {
+		j.mu.Lock()
+		keep := state == "" || j.State == state
+		j.mu.Unlock()
+		if keep {
+			list = append(list, j)
+		}
+	}
+	writeAPIJSON(w, http.StatusOK, list)
+}
+
+func (s *server) handleGet(w http.ResponseWriter, r *http.Request) {
+	j := s.find(r.PathValue("id"))
+	if j == nil {
+		writeAPIError(w, http.StatusNotFound, errors.New("no such job"))
+		return
+	}
+	writeAPIJSON(w, http.StatusOK, j)
+}
+
+func (s *server) handleCancel(w http.ResponseWriter, r *http.Request) {
+	j := s.find(r.PathValue("id"))
+	if j == nil {
+		writeAPIError(w, http.StatusNotFound, errors.New("no such job"))
+		return
+	}
+	if j.finished() {
+		writeAPIError(w, http.StatusConflict, errors.New("job has finished"))
+		return
+	}
+	j.cancel()
+	writeAPIJSON(w, http.StatusAccepted, j)
+}
+
+// reportStatus tells systemd how many jobs are running and queued.
+func (s *server) reportStatus() {
+	var running, queued int
+	s.mu.Lock()
+	for _, j := range s.jobs {
+		j.mu.Lock()
+		switch j.State {
+		case jobQueued:
+			queued++
+		case jobListing, jobRunning:
+			running++
+		}
+		j.mu.Unlock()
+	}
+	s.mu.Unlock()
+	s.sd.status(fmt.Sprintf(tr("Serving jobs on %s, %d running, %d queued"), s.params.Listen, running, queued))
+}
+
+func (s *server) find(id string) *job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range s.jobs {
+		if j.ID == id {
+			return j
+		}
+	}
+	return nil
+}
+
+// run waits for a free slot, then lists and downloads the job.
+func (s *server) run(ctx context.Context, j *job) {
+	defer s.wg.Done()
+	defer j.cancel()
+
+	report, err := s.download(ctx, j)
+	var state string
+	j.update(func(j *job) {
+		now := time.Now().UTC()
+		j.Finished = &now
+		j.Report = report
+		switch {
+		case ctx.Err() != nil:
+			j.State = jobCancelled
+		case err != nil:
+			j.State = jobFailed
+			j.Error = err.Error()
+		default:
+			j.State = jobDone
+		}
+		state = j.State
+	})
+	log.Printf(tr("Job %s %s"), j.ID, state)
+	s.reportStatus()
+
+	n := newNotification(s.params, state, yadloader.Report{}, err)
+	n.Event = "job_finished"
+	n.Job, n.Link, n.Path, n.Output = j.ID, j.Link, j.Path, filepath.Join(s.params.Folder, j.Output)
+	if report != nil {
+		n.Report = *report
+	}
+	notify(s.params, n)
+}
+
+func (s *server) download(ctx context.Context, j *job) (*yadloader.Report, error) {
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	j.update(func(j *job) { j.State = jobListing })
+	s.reportStatus()
+	listing := func(count, totalSize int64) {
+		j.update(func(j *job) { j.Files, j.TotalSize = count, totalSize })
+	}
+	files, err := s.client.GetTree(ctx, j.Link, j.Path, treeOptions(s.params, listing)...)
+	if err != nil {
+		return nil, err
+	}
+	if files, err = capFiles(s.params, files); err != nil {
+		return nil, err
+	}
+	var totalSize int64
+	for _, f := range files {
+		totalSize += f.Size
+	}
+	j.update(func(j *job) {
+		j.State = jobRunning
+		j.Files, j.TotalSize = int64(len(files)), totalSize
+	})
+
+	dest := filepath.Join(s.params.Folder, j.Output)
+	if err := makeFolder(dest, 0755); err != nil {
+		return nil, err
+	}
+	unlock, err := lockFolder(ctx, s.params, dest)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	opts := append(downloadOptions(s.params, yadloader.Hooks{}), yadloader.WithProgress(j.progress))
+	if s.params.Flatten || s.params.ByDate != "" || s.params.NameTemplate != "" {
+		opts = append(opts, yadloader.WithLayout(listingLayout(s.params, files)))
+	}
+	report, err := s.client.DownloadFiles(ctx, files, dest, opts...)
+	if err != nil && len(report.Failures) > 0 {
+		err = fmt.Errorf("%d files failed", len(report.Failures))
+	}
+	return &report, err
+}
+
+func writeAPIJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeAPIError(w http.ResponseWriter, status int, err error) {
+	writeAPIJSON(w, status, map[string]string{"error": err.Error()})
+}
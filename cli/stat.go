@@ -0,0 +1,176 @@
+package main
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+// statLargest is how many of the largest files stat lists.
+const statLargest = 20
+
+// usage is the space taken by the files of a folder or an extension.
+type usage struct {
+	Name  string `json:"name"`
+	Files int    `json:"files"`
+	Size  int64  `json:"size"`
+}
+
+type fileSize struct {
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+}
+
+// stats is what stat reports about a listing, biggest first.
+type stats struct {
+	Files      int        `json:"files"`
+	Size       int64      `json:"size"`
+	Folders    []usage    `json:"folders"`
+	Extensions []usage    `json:"extensions"`
+	Largest    []fileSize `json:"largest"`
+}
+
+// newStats adds up files, which are relative to the listed folder.
+func newStats(files []yadloader.DiskFile) stats {
+	folders := make(map[string]*usage)
+	exts := make(map[string]*usage)
+	add := func(m map[string]*usage, name string, size int64) {
+		u, ok := m[name]
+		if !ok {
+			u = &usage{Name: name}
+			m[name] = u
+		}
+		u.Files++
+		u.Size += size
+	}
+
+	s := stats{Files: len(files)}
+	for _, f := range files {
+		s.Size += f.Size
+		top, _, nested := strings.Cut(strings.TrimPrefix(f.Path, "/"), "/")
+		if !nested {
+			top = "(files at the top)"
+		} else {
+			top += "/"
+		}
+		add(folders, top, f.Size)
+		ext := strings.ToLower(path.Ext(f.Path))
+		if ext == "" {
+			ext = "(none)"
+		}
+		add(exts, ext, f.Size)
+	}
+
+	s.Folders, s.Extensions = sortedUsage(folders), sortedUsage(exts)
+	bySize := slices.SortedFunc(slices.Values(files), func(a, b yadloader.DiskFile) int {
+		return cmp.Or(cmp.Compare(b.Size, a.Size), cmp.Compare(a.Path, b.Path))
+	})
+	for _, f := range bySize[:min(statLargest, len(bySize))] {
+		s.Largest = append(s.Largest, fileSize{Path: f.Path, Size: f.Size})
+	}
+	return s
+}
+
+func sortedUsage(m map[string]*usage) []usage {
+	list := make([]usage, 0, len(m))
+	for _, u := range m {
+		list = append(list, *u)
+	}
+	slices.SortFunc(list, func(a, b usage) int {
+		return cmp.Or(cmp.Compare(b.Size, a.Size), cmp.Compare(a.Name, b.Name))
+	})
+	return list
+}
+
+// relativeTo returns files with paths relative to the listed folder root.
+func relativeTo(root string, files []yadloader.DiskFile) []yadloader.DiskFile {
+	root = strings.TrimSuffix(path.Clean("/"+root), "/")
+	rel := slices.Clone(files)
+	for i := range rel {
+		rel[i].Path = strings.TrimPrefix(path.Clean("/"+rel[i].Path), root)
+	}
+	return rel
+}
+
+// stat lists the share and prints it as a tree with a breakdown of sizes
+// by top-level folder and extension and the largest files, or the
+// breakdown as JSON with --json.
+func stat(ctx context.Context, client *yadloader.YaDiskClient, params *Args) int {
+	files, err := listPaths(ctx, client, params, treeOptions(params, func(int64, int64) {}))
+	if err != nil {
+		if ctx.Err() != nil {
+			log.Print(tr("Interrupted while listing"))
+			return exitInterrupted
+		}
+		log.Printf(tr("Error: %v"), err)
+		return 1
+	}
+	// Several paths are shown from the root of the share.
+	root := params.Path
+	if len(params.Paths) > 1 {
+		root = ""
+	}
+	files = relativeTo(root, files)
+	s := newStats(files)
+
+	if params.JSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(s); err != nil {
+			log.Printf(tr("Error: %v"), err)
+			return 1
+		}
+		return 0
+	}
+
+	tree := buildFileTree(files)
+	fmt.Printf(tr("%s  (%s, %d files)\n"), path.Clean("/"+root), formatSize(tree.size), tree.total)
+	writeTree(os.Stdout, tree, "")
+
+	writeUsage(os.Stdout, tr("By folder"), s.Folders, s.Size)
+	writeUsage(os.Stdout, tr("By extension"), s.Extensions, s.Size)
+	fmt.Print(tr("\nLargest files\n"))
+	for _, f := range s.Largest {
+		fmt.Printf("  %10s  %s\n", formatSize(f.Size), f.Path)
+	}
+	return 0
+}
+
+func writeTree(w io.Writer, n *fileNode, indent string) {
+	for i, c := range n.children {
+		branch, next := "├── ", "│   "
+		if i == len(n.children)-1 {
+			branch, next = "└── ", "    "
+		}
+		if c.dir() {
+			fmt.Fprintf(w, tr("%s%s%s/  (%s, %d files)\n"), indent, branch, c.name, formatSize(c.size), c.total)
+			writeTree(w, c, indent+next)
+		} else {
+			fmt.Fprintf(w, "%s%s%s  (%s)\n", indent, branch, c.name, formatSize(c.size))
+		}
+	}
+}
+
+func writeUsage(w io.Writer, title string, list []usage, total int64) {
+	fmt.Fprintf(w, "\n%s\n", title)
+	width := 0
+	for _, u := range list {
+		width = max(width, len(u.Name))
+	}
+	for _, u := range list {
+		share := 0.0
+		if total > 0 {
+			share = 100 * float64(u.Size) / float64(total)
+		}
+		fmt.Fprintf(w, tr("  %-*s  %10s  %5.1f%%  %d files\n"), width, u.Name, formatSize(u.Size), share, u.Files)
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/brandquad/yadloader-go"
+	"github.com/brandquad/yadloader-go/sqlitequeue"
+)
+
+// stateStore keeps the file list of a run and per-file progress, so the run
+// can be resumed. Implementations must be safe for concurrent use.
+type stateStore interface {
+	// Source returns the link and path of the stored run, empty if none.
+	Source() (link, path string)
+	Init(link, path string, files []yadloader.DiskFile) error
+	Files() ([]yadloader.DiskFile, error)
+	Pending() ([]yadloader.DiskFile, error)
+	Mark(file yadloader.DiskFile, status yadloader.FileStatus, cause error) error
+	// LastRun is when the last run that downloaded everything of the
+	// source started; Init keeps it for the same link and path.
+	LastRun() (time.Time, error)
+	SetLastRun(t time.Time) error
+	Flush() error
+	Close() error
+	Location() string
+}
+
+// journalStore keeps the state in a JSON journal, rewritten at most every
+// journalInterval while downloading.
+type journalStore struct {
+	filename string
+
+	mu      sync.Mutex
+	journal *yadloader.Journal
+	saved   time.Time
+}
+
+func openJournalStore(filename string) (*journalStore, error) {
+	s := &journalStore{filename: filename}
+	journal, err := yadloader.LoadJournal(filename)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	s.journal = journal
+	return s, nil
+}
+
+func (s *journalStore) Source() (string, string) {
+	if s.journal == nil {
+		return "", ""
+	}
+	return s.journal.Link, s.journal.Path
+}
+
+func (s *journalStore) Init(link, path string, files []yadloader.DiskFile) error {
+	var last time.Time
+	if j := s.journal; j != nil && j.Link == link && j.Path == path {
+		last = j.LastRun
+	}
+	s.journal = yadloader.NewJournal(link, path, files)
+	s.journal.LastRun = last
+	return s.Flush()
+}
+
+func (s *journalStore) LastRun() (time.Time, error) {
+	if s.journal == nil {
+		return time.Time{}, nil
+	}
+	return s.journal.LastRun, nil
+}
+
+func (s *journalStore) SetLastRun(t time.Time) error {
+	s.journal.LastRun = t.UTC()
+	return s.Flush()
+}
+
+func (s *journalStore) Files() ([]yadloader.DiskFile, error) {
+	if s.journal == nil {
+		return nil, nil
+	}
+	return s.journal.Tree, nil
+}
+
+func (s *journalStore) Pending() ([]yadloader.DiskFile, error) {
+	if s.journal == nil {
+		return nil, nil
+	}
+	return s.journal.Pending(), nil
+}
+
+func (s *journalStore) Mark(file yadloader.DiskFile, status yadloader.FileStatus, _ error) error {
+	s.journal.Mark(file.Path, status)
+
+	s.mu.Lock()
+	due := time.Since(s.saved) > journalInterval
+	s.mu.Unlock()
+	if !due {
+		return nil
+	}
+	return s.Flush()
+}
+
+func (s *journalStore) Flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.saved = time.Now()
+	return s.journal.Save(s.filename)
+}
+
+func (s *journalStore) Close() error {
+	return nil
+}
+
+func (s *journalStore) Location() string {
+	return s.filename
+}
+
+// queueStore keeps the state in SQLite; every update is committed at once.
+type queueStore struct {
+	filename string
+	queue    *sqlitequeue.Queue
+	link     string
+	path     string
+}
+
+func openQueueStore(filename string) (*queueStore, error) {
+	queue, err := sqlitequeue.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	link, path, err := queue.Source()
+	if err != nil {
+		queue.Close()
+		return nil, err
+	}
+	return &queueStore{filename: filename, queue: queue, link: link, path: path}, nil
+}
+
+func (s *queueStore) Source() (string, string) {
+	return s.link, s.path
+}
+
+func (s *queueStore) Init(link, path string, files []yadloader.DiskFile) error {
+	s.link, s.path = link, path
+	return s.queue.Init(link, path, files)
+}
+
+func (s *queueStore) Files() ([]yadloader.DiskFile, error) {
+	return s.queue.Files()
+}
+
+func (s *queueStore) Pending() ([]yadloader.DiskFile, error) {
+	return s.queue.Pending()
+}
+
+func (s *queueStore) Mark(file yadloader.DiskFile, status yadloader.FileStatus, cause error) error {
+	return s.queue.Mark(file.Path, status, cause)
+}
+
+func (s *queueStore) LastRun() (time.Time, error) {
+	return s.queue.LastRun()
+}
+
+func (s *queueStore) SetLastRun(t time.Time) error {
+	return s.queue.SetLastRun(t)
+}
+
+func (s *queueStore) Flush() error {
+	return nil
+}
+
+func (s *queueStore) Close() error {
+	return s.queue.Close()
+}
+
+func (s *queueStore) Location() string {
+	return s.filename
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+// statusFailures is how many of the latest failures --status-addr shows.
+const statusFailures = 20
+
+type statusFailure struct {
+	Path  string    `json:"path"`
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+type statusTransfer struct {
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	Bytes int64  `json:"bytes"`
+}
+
+// progressStatus is the JSON page of --status-addr. Counts add up every
+// link of --links-file and every check of watch since the start.
+type progressStatus struct {
+	Started    time.Time        `json:"started"`
+	Uptime     float64          `json:"uptime_seconds"`
+	Listed     int64            `json:"listed"`
+	Files      int              `json:"files"`
+	TotalSize  int64            `json:"total_size"`
+	Downloaded int              `json:"downloaded"`
+	Skipped    int              `json:"skipped"`
+	Failed     int              `json:"failed"`
+	Bytes      int64            `json:"bytes"`
+	Speed      float64          `json:"bytes_per_second"`
+	ETASeconds *int64           `json:"eta_seconds,omitempty"`
+	Running    []statusTransfer `json:"running"`
+	Failures   []statusFailure  `json:"failures"`
+	Finished   bool             `json:"finished"`
+}
+
+// statusReporter keeps the progressStatus of a run for --status-addr and
+// passes every event on to the reporter it wraps.
+type statusReporter struct {
+	reporter
+
+	mu      sync.Mutex
+	status  progressStatus
+	running []*statusTransfer
+	eta     time.Duration
+}
+
+func newStatusReporter(events reporter) *statusReporter {
+	return &statusReporter{reporter: events, status: progressStatus{Started: time.Now()}, eta: -1}
+}
+
+func (r *statusReporter) update(fn func(s *progressStatus)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	fn(&r.status)
+}
+
+func (r *statusReporter) end(file yadloader.DiskFile) {
+	r.running = slices.DeleteFunc(r.running, func(t *statusTransfer) bool { return t.Path == file.Path })
+}
+
+func (r *statusReporter) Listing(count, totalSize int64) {
+	r.update(func(s *progressStatus) { s.Listed = count })
+	r.reporter.Listing(count, totalSize)
+}
+
+func (r *statusReporter) Planned(files int, totalSize int64) {
+	r.update(func(s *progressStatus) {
+		s.Files += files
+		s.TotalSize += totalSize
+		s.Finished = false
+	})
+	r.reporter.Planned(files, totalSize)
+}
+
+func (r *statusReporter) Started(file yadloader.DiskFile, dest string) {
+	r.update(func(*progressStatus) {
+		r.running = append(r.running, &statusTransfer{Path: file.Path, Size: file.Size})
+	})
+	r.reporter.Started(file, dest)
+}
+
+func (r *statusReporter) Skipped(file yadloader.DiskFile, dest string) {
+	r.update(func(s *progressStatus) { s.Skipped++ })
+	r.reporter.Skipped(file, dest)
+}
+
+func (r *statusReporter) Finished(file yadloader.DiskFile, dest string, elapsed time.Duration) {
+	r.update(func(s *progressStatus) {
+		r.end(file)
+		s.Downloaded++
+		s.Bytes += file.Size
+	})
+	r.reporter.Finished(file, dest, elapsed)
+}
+
+func (r *statusReporter) Failed(file yadloader.DiskFile, dest string, err error) {
+	r.update(func(s *progressStatus) {
+		r.end(file)
+		s.Failed++
+		s.Failures = append(s.Failures, statusFailure{Path: file.Path, Error: err.Error(), Time: time.Now()})
+		if len(s.Failures) > statusFailures {
+			s.Failures = slices.Delete(s.Failures, 0, len(s.Failures)-statusFailures)
+		}
+	})
+	r.reporter.Failed(file, dest, err)
+}
+
+func (r *statusReporter) Progress(e yadloader.Event, eta time.Duration) {
+	r.update(func(s *progressStatus) {
+		for _, t := range r.running {
+			if t.Path == e.File.Path {
+				t.Bytes = e.Bytes
+			}
+		}
+		s.Speed, r.eta = e.Total.Average, eta
+	})
+	r.reporter.Progress(e, eta)
+}
+
+func (r *statusReporter) Summary(sum summary) {
+	r.update(func(s *progressStatus) {
+		r.running, r.eta = nil, -1
+		s.Speed = 0
+		s.Finished = true
+	})
+	r.reporter.Summary(sum)
+}
+
+// snapshot returns the status with the bytes of running downloads.
+func (r *statusReporter) snapshot() progressStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	s := r.status
+	s.Uptime = time.Since(s.Started).Seconds()
+	s.Failures = slices.Clone(s.Failures)
+	s.Running = make([]statusTransfer, len(r.running))
+	for i, t := range r.running {
+		s.Running[i] = *t
+		s.Bytes += t.Bytes
+	}
+	if r.eta >= 0 {
+		s.ETASeconds = ptr(int64(r.eta.Seconds()))
+	}
+	return s
+}
+
+func (r *statusReporter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(r.snapshot())
+}
+
+// serveStatus answers GET / on addr with the status of r until the process
+// exits.
+func serveStatus(addr string, r *statusReporter) error {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /{$}", r)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go srv.Serve(ln)
+	return nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"net"
+	"os"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+// systemd tells the service manager about watch and serve, as sd_notify(3)
+// does: readiness, a status line and the watchdog pings of WatchdogSec.
+// Outside a systemd service NOTIFY_SOCKET is unset and it does nothing.
+type systemd struct {
+	addr     *net.UnixAddr
+	watchdog time.Duration
+}
+
+func newSystemd() *systemd {
+	sd := &systemd{}
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return sd
+	}
+	if socket[0] == '@' {
+		// An abstract socket.
+		socket = "\x00" + socket[1:]
+	}
+	sd.addr = &net.UnixAddr{Name: socket, Net: "unixgram"}
+	pid := os.Getenv("WATCHDOG_PID")
+	if usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64); err == nil && usec > 0 && (pid == "" || pid == strconv.Itoa(os.Getpid())) {
+		sd.watchdog = time.Duration(usec) * time.Microsecond
+	}
+	return sd
+}
+
+// notify sends state, such as "READY=1", ignoring errors like sd_notify.
+func (sd *systemd) notify(state string) {
+	if sd.addr == nil {
+		return
+	}
+	conn, err := net.DialUnix("unixgram", nil, sd.addr)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	_, _ = conn.Write([]byte(state))
+}
+
+func (sd *systemd) ready(status string) {
+	sd.notify("READY=1\nSTATUS=" + status)
+}
+
+func (sd *systemd) status(status string) {
+	sd.notify("STATUS=" + status)
+}
+
+func (sd *systemd) stopping() {
+	sd.notify("STOPPING=1")
+}
+
+// ping sends watchdog pings at half of WatchdogSec until ctx is done, as
+// long as alive reports the process is making progress. Without pings
+// systemd restarts the service, so WatchdogSec should be longer than
+// --progress-interval.
+func (sd *systemd) ping(ctx context.Context, alive func() bool) {
+	if sd.addr == nil || sd.watchdog <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(sd.watchdog / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if alive() {
+				sd.notify("WATCHDOG=1")
+			}
+		}
+	}()
+}
+
+// activity is when watch last heard from a check, or zero while it waits
+// for the next one.
+type activity struct {
+	last atomic.Int64
+}
+
+func (a *activity) beat() {
+	a.last.Store(time.Now().UnixNano())
+}
+
+func (a *activity) idle() {
+	a.last.Store(0)
+}
+
+// within reports whether watch is waiting or a check made progress in the
+// last d.
+func (a *activity) within(d time.Duration) bool {
+	last := a.last.Load()
+	return last == 0 || time.Since(time.Unix(0, last)) < d
+}
+
+// liveReporter beats activity on every event of a check.
+type liveReporter struct {
+	reporter
+	activity *activity
+}
+
+func (r liveReporter) Listing(count, totalSize int64) {
+	r.activity.beat()
+	r.reporter.Listing(count, totalSize)
+}
+
+func (r liveReporter) Started(file yadloader.DiskFile, dest string) {
+	r.activity.beat()
+	r.reporter.Started(file, dest)
+}
+
+func (r liveReporter) Skipped(file yadloader.DiskFile, dest string) {
+	r.activity.beat()
+	r.reporter.Skipped(file, dest)
+}
+
+func (r liveReporter) Finished(file yadloader.DiskFile, dest string, elapsed time.Duration) {
+	r.activity.beat()
+	r.reporter.Finished(file, dest, elapsed)
+}
+
+func (r liveReporter) Failed(file yadloader.DiskFile, dest string, err error) {
+	r.activity.beat()
+	r.reporter.Failed(file, dest, err)
+}
+
+func (r liveReporter) Progress(e yadloader.Event, eta time.Duration) {
+	r.activity.beat()
+	r.reporter.Progress(e, eta)
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brandquad/yadloader-go"
+	"golang.org/x/term"
+)
+
+const (
+	tuiRefresh = 500 * time.Millisecond
+	tuiLogSize = 200
+)
+
+// transfer is a running download on the dashboard.
+type transfer struct {
+	file  yadloader.DiskFile
+	bytes int64
+	speed float64
+}
+
+// tuiReporter draws a full-screen dashboard of running downloads, counts,
+// speeds and recent log lines while files are downloaded. Before and after
+// that it reports like textReporter, so the listing and the summary stay in
+// the terminal.
+type tuiReporter struct {
+	*textReporter
+	out *os.File
+
+	mu       sync.Mutex
+	on       bool
+	stop     chan struct{}
+	done     chan struct{}
+	started  time.Time
+	files    int
+	finished int
+	skipped  int
+	failed   int
+	bytes    int64
+	active   []*transfer
+	speed    float64
+	eta      time.Duration
+	logs     []string
+	partial  []byte
+	// failures are the log lines of failed files.
+	failures []string
+}
+
+func newTUIReporter(out *os.File, text *textReporter) *tuiReporter {
+	return &tuiReporter{textReporter: text, out: out, eta: -1}
+}
+
+// Write takes the log output while the dashboard is shown.
+func (r *tuiReporter) Write(p []byte) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.partial = append(r.partial, p...)
+	for {
+		line, rest, ok := bytes.Cut(r.partial, []byte("\n"))
+		if !ok {
+			break
+		}
+		r.addLog(string(line))
+		r.partial = rest
+	}
+	return len(p), nil
+}
+
+func (r *tuiReporter) addLog(line string) {
+	r.logs = append(r.logs, line)
+	if len(r.logs) > tuiLogSize {
+		r.logs = slices.Delete(r.logs, 0, len(r.logs)-tuiLogSize)
+	}
+}
+
+// logf adds a timestamped line to the log pane.
+func (r *tuiReporter) logf(format string, args ...any) {
+	r.addLog(time.Now().Format("2006/01/02 15:04:05 ") + fmt.Sprintf(format, args...))
+}
+
+// show switches to the dashboard on the first download event of a run.
+// It is called with mu held.
+func (r *tuiReporter) show() {
+	if r.on {
+		return
+	}
+	r.on = true
+	r.started = time.Now()
+	r.finished, r.skipped, r.failed, r.bytes = 0, 0, 0, 0
+	r.active, r.speed, r.eta = nil, 0, -1
+	r.stop, r.done = make(chan struct{}), make(chan struct{})
+	log.SetOutput(r)
+	// Alternate screen and hidden cursor, undone by hide.
+	fmt.Fprint(r.out, "\x1b[?1049h\x1b[?25l")
+	go r.refresh(r.stop, r.done)
+}
+
+func (r *tuiReporter) hide() {
+	r.mu.Lock()
+	if !r.on {
+		r.mu.Unlock()
+		return
+	}
+	r.on = false
+	close(r.stop)
+	r.mu.Unlock()
+	<-r.done
+
+	fmt.Fprint(r.out, "\x1b[?25h\x1b[?1049l")
+	log.SetOutput(os.Stderr)
+	// Failures scroll out of the pane; repeat them on the normal screen.
+	r.mu.Lock()
+	failures := r.failures
+	r.logs, r.partial, r.failures = nil, nil, nil
+	r.mu.Unlock()
+	for _, l := range failures {
+		fmt.Fprintln(os.Stderr, l)
+	}
+}
+
+func (r *tuiReporter) refresh(stop, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(tuiRefresh)
+	defer ticker.Stop()
+	for {
+		r.draw()
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *tuiReporter) Planned(files int, totalSize int64) {
+	r.mu.Lock()
+	r.files = files
+	r.mu.Unlock()
+	r.textReporter.Planned(files, totalSize)
+}
+
+func (r *tuiReporter) Started(file yadloader.DiskFile, _ string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.show()
+	r.active = append(r.active, &transfer{file: file})
+}
+
+func (r *tuiReporter) end(file yadloader.DiskFile) {
+	r.active = slices.DeleteFunc(r.active, func(t *transfer) bool { return t.file.Path == file.Path })
+}
+
+func (r *tuiReporter) Skipped(file yadloader.DiskFile, dest string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.show()
+	r.skipped++
+	r.logf("%s", skippedLine(file, dest))
+}
+
+func (r *tuiReporter) Finished(file yadloader.DiskFile, _ string, elapsed time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.end(file)
+	r.finished++
+	r.bytes += file.Size
+	r.logf(tr("Downloaded %s (%s) in %s"), file.Path, formatSize(file.Size), elapsed.Round(time.Millisecond))
+}
+
+func (r *tuiReporter) Failed(file yadloader.DiskFile, _ string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.show()
+	r.end(file)
+	r.failed++
+	r.logf(tr("Failed %s: %v"), file.Path, err)
+	r.failures = append(r.failures, r.logs[len(r.logs)-1])
+}
+
+func (r *tuiReporter) Progress(e yadloader.Event, eta time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, t := range r.active {
+		if t.file.Path == e.File.Path {
+			t.bytes, t.speed = e.Bytes, e.Speed.Average
+		}
+	}
+	r.speed, r.eta = e.Total.Average, eta
+}
+
+func (r *tuiReporter) Summary(s summary) {
+	r.hide()
+	r.textReporter.Summary(s)
+}
+
+func (r *tuiReporter) draw() {
+	width, height, err := term.GetSize(int(r.out.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+
+	r.mu.Lock()
+	var b strings.Builder
+	line := func(format string, args ...any) {
+		b.WriteString(fit(fmt.Sprintf(format, args...), width))
+		b.WriteString("\x1b[K\r\n")
+	}
+
+	var running int64
+	for _, t := range r.active {
+		running += t.bytes
+	}
+	queued := max(r.files-r.finished-r.skipped-r.failed-len(r.active), 0)
+	status := fmt.Sprintf(tr("%s downloaded at %s/s"), formatSize(r.bytes+running), formatSize(int64(r.speed)))
+	if r.eta >= 0 {
+		status += fmt.Sprintf(tr(", ETA %s"), r.eta.Round(time.Second))
+	}
+	line(tr("yadownload - %s, running %s"), status, time.Since(r.started).Round(time.Second))
+	line(tr("Files: %d done, %d skipped, %d failed, %d running, %d queued"), r.finished, r.skipped, r.failed, len(r.active), queued)
+	line("")
+
+	// Transfers get up to half the screen, the log pane the rest.
+	rows := min(len(r.active), max(height/2-3, 1))
+	line("%s", tr("Transfers"))
+	for _, t := range r.active[:rows] {
+		done := 0.0
+		if t.file.Size > 0 {
+			done = float64(t.bytes) / float64(t.file.Size)
+		}
+		info := fmt.Sprintf(" %3.0f%% %9s/s  ", 100*done, formatSize(int64(t.speed)))
+		name := width - len(info) - 22
+		line("%s %s%s", progressBar(done, 20), info, fitLeft(t.file.Path, max(name, 10)))
+	}
+	if hidden := len(r.active) - rows; hidden > 0 {
+		line(tr("  and %d more"), hidden)
+	}
+	line("")
+
+	line("%s", tr("Log"))
+	used := strings.Count(b.String(), "\n")
+	logs := r.logs[max(len(r.logs)-max(height-used, 0), 0):]
+	for i, l := range logs {
+		b.WriteString(fit(l, width))
+		b.WriteString("\x1b[K")
+		if i < len(logs)-1 {
+			b.WriteString("\r\n")
+		}
+	}
+	r.mu.Unlock()
+
+	fmt.Fprint(r.out, "\x1b[H", b.String(), "\x1b[J")
+}
+
+func progressBar(done float64, width int) string {
+	n := int(min(max(done, 0), 1) * float64(width))
+	return "[" + strings.Repeat("#", n) + strings.Repeat("-", width-n) + "]"
+}
+
+// fitLeft cuts s to width columns from the left, keeping the file name
+// of long paths.
+func fitLeft(s string, width int) string {
+	if r := []rune(s); len(r) > width {
+		return "…" + string(r[len(r)-max(width-1, 0):])
+	}
+	return s
+}
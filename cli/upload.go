@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+// upload copies localPath into remotePath of the disk of --token, logging
+// every file, or with --json emitting it as an event.
+func upload(ctx context.Context, client *yadloader.YaDiskClient, params *Args, localPath, remotePath string) int {
+	overwrite, _ := yadloader.ParseOverwritePolicy(params.IfExists)
+	errorPolicy := yadloader.Collect
+	if params.FailFast {
+		errorPolicy = yadloader.FailFast
+	}
+	var events *jsonReporter
+	if params.JSON {
+		events = newJSONReporter(os.Stdout)
+	}
+	progress := func(e yadloader.Event) {
+		if events != nil {
+			ev := event{Event: string(e.Type), Path: e.File.Path, Size: ptr(e.File.Size), Dest: e.Dest}
+			switch e.Type {
+			case yadloader.EventUploadFinished:
+				ev.DurationMs = ptr(e.Elapsed.Milliseconds())
+			case yadloader.EventUploadFailed:
+				ev.Error = e.Err.Error()
+			}
+			events.emit(ev)
+			return
+		}
+		switch e.Type {
+		case yadloader.EventUploadFinished:
+			log.Printf(tr("Uploaded %s to %s (%d bytes) in %s"), e.Dest, e.File.Path, e.File.Size, e.Elapsed.Round(time.Millisecond))
+		case yadloader.EventUploadSkipped:
+			log.Printf(tr("Skipped %s, %s exists on the disk"), e.Dest, e.File.Path)
+		case yadloader.EventUploadFailed:
+			log.Printf(tr("Failed %s: %v"), e.Dest, e.Err)
+		}
+	}
+
+	report, err := client.Upload(ctx, localPath, remotePath,
+		yadloader.WithOverwrite(overwrite),
+		yadloader.WithErrorPolicy(errorPolicy),
+		yadloader.WithProgress(progress),
+	)
+	if params.ReportFile != "" {
+		if rerr := writeReport(params.ReportFile, report); rerr != nil {
+			log.Printf(tr("Failed to write report: %v"), rerr)
+		}
+	}
+	if events != nil {
+		events.emit(event{
+			Event:      "upload_summary",
+			Files:      ptr(report.Files),
+			Uploaded:   ptr(report.Uploaded),
+			Skipped:    ptr(report.Skipped),
+			Failed:     ptr(report.Failed),
+			Bytes:      ptr(report.Bytes),
+			DurationMs: ptr(report.Duration.Milliseconds()),
+		})
+	} else if err == nil || report.Files > 0 {
+		fmt.Printf(tr("Uploaded %d of %d files, skipped %d, failed %d: %s in %s\n"),
+			report.Uploaded, report.Files, report.Skipped, report.Failed, formatSize(report.Bytes), report.Duration.Round(time.Millisecond))
+	}
+
+	switch {
+	case err == nil:
+		return exitOK
+	case ctx.Err() != nil:
+		log.Print(tr("Interrupted"))
+		return exitInterrupted
+	case report.Failed > 0 && report.Uploaded+report.Skipped > 0:
+		return exitPartial
+	case report.Failed == 0:
+		log.Printf(tr("Error: %v"), err)
+	}
+	return errorClass(err, exitError)
+}
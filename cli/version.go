@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"runtime/debug"
+)
+
+// Set by release builds with
+// -ldflags "-X main.version=v1.2.3 -X main.commit=abc1234 -X main.date=2024-01-02T15:04:05Z".
+// Otherwise they are taken from the build info Go records.
+var (
+	version = ""
+	commit  = ""
+	date    = ""
+)
+
+// buildVersion returns the version, commit and build date of the binary,
+// with "unknown" for what nobody recorded.
+func buildVersion() (v, c, d string, dirty bool) {
+	v, c, d = version, commit, date
+	if info, ok := debug.ReadBuildInfo(); ok {
+		if v == "" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+			v = info.Main.Version
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if c == "" {
+					c = s.Value
+				}
+			case "vcs.time":
+				if d == "" {
+					d = s.Value
+				}
+			case "vcs.modified":
+				dirty = s.Value == "true" && commit == ""
+			}
+		}
+	}
+	if v == "" {
+		v = "devel"
+	}
+	if len(c) > 12 {
+		c = c[:12]
+	}
+	if c == "" {
+		c = "unknown"
+	}
+	if d == "" {
+		d = "unknown"
+	}
+	return v, c, d, dirty
+}
+
+func writeVersion(w io.Writer, prog string) {
+	v, c, d, dirty := buildVersion()
+	if dirty {
+		c += "-dirty"
+	}
+	fmt.Fprintf(w, "%s %s\ncommit: %s\nbuilt: %s\ngo: %s %s/%s\n", prog, v, c, d, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
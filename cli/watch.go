@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+// watch lists the share every --interval and downloads files added or
+// changed since the previous listing. The listing and what of it is done
+// are kept in the state store, so a restarted watch carries on from there.
+// Files removed from the share are kept locally. Under systemd it reports
+// readiness and status, and stops the watchdog pings when a check makes no
+// progress.
+func watch(ctx context.Context, client *yadloader.YaDiskClient, params *Args, events reporter, deadline time.Time) int {
+	if err := makeFolder(params.Folder, 0755); err != nil {
+		return setupError(err)
+	}
+	unlock, err := lockOutput(ctx, params)
+	if err != nil {
+		if ctx.Err() != nil {
+			return exitInterrupted
+		}
+		log.Printf(tr("Error: %v"), err)
+		return errorClass(err, exitError)
+	}
+	defer unlock()
+	store, err := openStore(params)
+	if err != nil {
+		return setupError(err)
+	}
+	defer store.Close()
+	if link, path := store.Source(); link != "" && (link != params.source() || path != params.Path) {
+		fmt.Fprintf(os.Stderr, tr("Error: %s belongs to a run of %s %s\n"), store.Location(), link, path)
+		return 1
+	}
+
+	sd := newSystemd()
+	defer sd.stopping()
+	var act activity
+	events = liveReporter{reporter: events, activity: &act}
+	sd.ping(ctx, func() bool { return params.ProgressEvery == 0 || act.within(sd.watchdog) })
+	sd.ready(tr("Watching"))
+
+	for {
+		act.beat()
+		sd.status(tr("Checking for changes"))
+		err := watchOnce(ctx, client, store, params, events)
+		act.idle()
+		if ctx.Err() != nil {
+			log.Printf(tr("Stopped watching, progress saved to %s"), store.Location())
+			return exitInterrupted
+		}
+		if !deadline.IsZero() && !time.Now().Before(deadline) {
+			log.Print(tr("Stopped watching after --max-duration"))
+			return 0
+		}
+		if err != nil {
+			log.Printf(tr("Error: %v"), err)
+		}
+
+		wait := params.Interval
+		if !deadline.IsZero() {
+			wait = min(wait, time.Until(deadline))
+		}
+		next := fmt.Sprintf(tr("Next check at %s"), time.Now().Add(wait).Format(time.TimeOnly))
+		log.Print(next)
+		if err != nil {
+			sd.status(fmt.Sprintf(tr("Last check failed: %v. %s"), err, next))
+		} else {
+			sd.status(next)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+	}
+}
+
+// watchOnce lists the share, replaces the stored listing with it and
+// downloads what is new, changed or still pending from earlier checks.
+func watchOnce(ctx context.Context, client *yadloader.YaDiskClient, store stateStore, params *Args, events reporter) error {
+	files, err := listPaths(ctx, client, params, treeOptions(params, events.Listing))
+	if err != nil {
+		return fmt.Errorf("list: %w", err)
+	}
+	prev, err := store.Files()
+	if err != nil {
+		return err
+	}
+	left, err := store.Pending()
+	if err != nil {
+		return err
+	}
+	changes := yadloader.Diff(prev, files)
+
+	redo := make(map[string]bool)
+	for _, list := range [][]yadloader.DiskFile{changes.Added, changes.Changed, left} {
+		for _, f := range list {
+			redo[f.Path] = true
+		}
+	}
+	if err := store.Init(params.source(), params.Path, files); err != nil {
+		return err
+	}
+	// Files downloaded by earlier checks and unchanged since stay done.
+	for _, f := range files {
+		if redo[f.Path] {
+			continue
+		}
+		if err := store.Mark(f, yadloader.StatusDone, nil); err != nil {
+			return err
+		}
+	}
+	pending, err := store.Pending()
+	if err != nil {
+		return err
+	}
+	log.Printf(tr("%d files added, %d changed, %d removed, %d to download"),
+		len(changes.Added), len(changes.Changed), len(changes.Removed), len(pending))
+	if len(pending) == 0 {
+		return store.Flush()
+	}
+
+	if pending, err = capFiles(params, pending); err != nil {
+		return err
+	}
+
+	var totalSize int64
+	for _, f := range pending {
+		totalSize += f.Size
+		if params.JSON {
+			events.Discovered(f)
+		}
+	}
+	opts := downloadOptions(params, yadloader.Hooks{})
+	if err := client.CheckFreeSpace(params.Folder, pending, opts...); err != nil {
+		if !params.Force || !errors.Is(err, yadloader.ErrInsufficientSpace) {
+			return err
+		}
+		log.Printf(tr("Warning: %v"), err)
+	}
+
+	progress := trackProgress(store, events, params.Folder, totalSize, make(map[string]string))
+	report, err := client.DownloadFiles(ctx, pending, params.Folder,
+		append(opts, yadloader.WithProgress(progress))...)
+	events.Summary(summary{Files: len(pending), TotalSize: totalSize, Report: report})
+	if params.ReportFile != "" {
+		if rerr := writeReport(params.ReportFile, report); rerr != nil {
+			log.Printf(tr("Failed to write report: %v"), rerr)
+		}
+	}
+	if serr := store.Flush(); serr != nil {
+		log.Printf(tr("Failed to save state: %v"), serr)
+	}
+	notify(params, newNotification(params, runStatus(ctx, err), report, err))
+	if err != nil && len(report.Failures) > 0 {
+		// Failed files were reported one by one and stay pending.
+		return nil
+	}
+	return err
+}
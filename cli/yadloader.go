@@ -1,17 +1,42 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"net/url"
 	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"syscall"
+	"time"
 
+	"filippo.io/age"
 	"github.com/brandquad/yadloader-go"
+	"golang.org/x/term"
 )
 
+// Values above these are allowed but tend to trip Yandex API rate limits.
+const (
+	safeConcurrency     = 8
+	safeListConcurrency = 4
+)
+
+// exitInterrupted is returned when a run is stopped by SIGINT/SIGTERM.
+const exitInterrupted = 130
+
+// journalInterval throttles journal rewrites while downloading.
+const journalInterval = 2 * time.Second
+
 func makeFolder(folder string, perm os.FileMode) error {
 	if perm == 0 {
 		perm = 0755
@@ -23,112 +48,1546 @@ func makeFolder(folder string, perm os.FileMode) error {
 			return err
 		}
 	} else if err != nil {
-		return err // Другая ошибка при проверке
+		return err // Not a missing folder
 	}
 	return nil
 }
 
 type Args struct {
-	Link   string
-	Path   string
-	Folder string
+	Link            string
+	LinksFile       string
+	LinksFolder     string
+	Path            string
+	Paths           repeatValue
+	Link2           string
+	Path2           string
+	SizeOnly        bool
+	Folder          string
+	Concurrency     int
+	ListConcurrency int
+	JSON            bool
+	Resume          bool
+	QueueDB         string
+	MinSize         sizeValue
+	MaxSize         sizeValue
+	NewerThan       timeValue
+	OlderThan       timeValue
+	Since           sinceValue
+	MediaTypes      listValue
+	Extensions      listValue
+	BlockedExts     listValue
+	Sort            string
+	MaxDepth        int
+	MaxFiles        int
+	MaxTotalSize    sizeValue
+	OverLimit       string
+	PreviewSize     string
+	Token           string
+	ClientID        string
+	Permanent       bool
+	SavePath        string
+	ClientSecret    string
+	Force           bool
+	IfExists        string
+	Infected        string
+	ReportFile      string
+	NotifyURL       string
+	ProgressEvery   time.Duration
+	TelegramToken   string
+	TelegramChat    string
+	SlackToken      string
+	SlackChannel    string
+	FailFast        bool
+	RetryPasses     int
+	RetryFile       string
+	RetryFrom       string
+	Exec            string
+	ExecJobs        int
+	ExecErrors      string
+	Checksums       string
+	ChecksumsPerDir bool
+	Xattrs          bool
+	Digests         bool
+	ServedNames     bool
+	EmptyFolders    bool
+	FolderTimes     bool
+	Preallocate     bool
+	SyncWrites      bool
+	Compress        string
+	EncryptTo       string
+	CASObjects      string
+	CASTree         string
+	SniffTypes      bool
+	Lang            string
+	StatusAddr      string
+	WaitLock        time.Duration
+	ConfigFile      string
+	Profile         string
+	// Login is the auth login entry Token comes from, if any.
+	Login           *keyringEntry
+	Pipeline        bool
+	Flatten         bool
+	StripComponents int
+	NameTemplate    string
+	ByDate          string
+	Pick            bool
+	TUI             bool
+	Version         bool
+	CacheTTL        time.Duration
+	Refresh         bool
+	RateLimit       float64
+	BreakerFailures int
+	BreakerCooldown time.Duration
+	HTTPTimeout     time.Duration
+	FileTimeout     time.Duration
+	OpTimeout       time.Duration
+	RetryBudget     int
+	RetryTime       time.Duration
+	MaxDuration     time.Duration
+	CACert          string
+	Insecure        bool
+	DebugHTTP       bool
+	DebugHTTPBody   int
+	Command         string
+	Interval        time.Duration
+	Listen          string
+	Jobs            int
+	APISecret       string
+	OwnDiskJobs     bool
+}
+
+// source identifies what is downloaded in state stores: the public link, or
+// "disk:" for the user's own disk.
+func (a *Args) source() string {
+	if a.Link == "" {
+		return "disk:"
+	}
+	return a.Link
 }
 
 func parseFlags() *Args {
 	config := &Args{}
+	defaults := yadloader.NewDefaultConfig()
 
-	// Обязательный параметр
-	flag.StringVar(&config.Link, "link", "", "Yandex.Disk public link (required)")
-	flag.StringVar(&config.Link, "l", "", "Yandex.Disk public link (shorthand, required)")
+	// Required
+	flag.StringVar(&config.Link, "link", "", "Yandex.Disk public link (required unless --token is set)")
+	flag.StringVar(&config.Link, "l", "", "Yandex.Disk public link (shorthand, required unless --token is set)")
 
-	// Необязательный параметр
-	flag.StringVar(&config.Path, "path", "", "Path to download (optional)")
-	flag.StringVar(&config.Path, "p", "", "Path to download (shorthand, optional)")
+	flag.StringVar(&config.LinksFile, "links-file", "", "Download every public link in this file, one per line (- for stdin), each into its own folder of --output")
+	flag.StringVar(&config.LinksFolder, "links-folder", "{name}", "Folder of --output for each link of --links-file: {name} of the shared folder, {key} of the link, {n} for its position")
+
+	flag.StringVar(&config.Link2, "link2", "", "Public link diff compares --link with")
+	flag.StringVar(&config.Link2, "l2", "", "Public link diff compares --link with (shorthand)")
+	flag.StringVar(&config.Path2, "path2", "", "Path within --link2 for diff (optional)")
+
+	flag.BoolVar(&config.SizeOnly, "size-only", false, "Make diff-local compare sizes only, without reading local files for their MD5")
+
+	flag.StringVar(&config.Token, "token", "", "OAuth token; without --link your own disk is downloaded (default $YADISK_TOKEN, then the token of auth login)")
+
+	// Optional
+	flag.Var(&config.Paths, "path", "Path to download (optional, repeatable)")
+	flag.Var(&config.Paths, "p", "Path to download (shorthand, optional, repeatable)")
 
 	flag.StringVar(&config.Folder, "output", "", "Folder to download (optional)")
 	flag.StringVar(&config.Folder, "o", "", "Folder to download (shorthand, optional)")
 
+	flag.IntVar(&config.Concurrency, "concurrency", defaults.Concurrency, fmt.Sprintf("Parallel downloads (max %d)", yadloader.MaxConcurrency))
+	flag.IntVar(&config.Concurrency, "c", defaults.Concurrency, "Parallel downloads (shorthand)")
+
+	flag.IntVar(&config.ListConcurrency, "list-concurrency", defaults.ListConcurrency, fmt.Sprintf("Folders listed in parallel (max %d)", yadloader.MaxListConcurrency))
+
+	flag.Float64Var(&config.RateLimit, "rate-limit", defaults.RateLimit, "Maximum API calls per second, slowed down automatically on HTTP 429 (0 for no limit)")
+	flag.IntVar(&config.BreakerFailures, "breaker-failures", 0, "Pause all requests for --breaker-cooldown after this many consecutive server or network failures (0 to never pause)")
+	flag.DurationVar(&config.BreakerCooldown, "breaker-cooldown", time.Minute, "How long to pause once --breaker-failures is reached")
+	flag.DurationVar(&config.HTTPTimeout, "http-timeout", defaults.HTTPTimeout, "Give up on an API call or a download that has not started answering after this long (0 for never)")
+	flag.DurationVar(&config.FileTimeout, "file-timeout", 0, "Fail a file whose download takes longer than this, e.g. 30m (0 for no limit)")
+	flag.DurationVar(&config.MaxDuration, "max-duration", 0, "Stop the whole run after this long, e.g. 6h; unfinished files can be resumed (0 for no limit)")
+	flag.BoolVar(&config.Pipeline, "pipeline", false, "Start downloading while the listing is still running; such runs cannot be resumed")
+	flag.BoolVar(&config.Pick, "pick", false, "Choose the files to download from the listing on the terminal")
+	flag.BoolVar(&config.Flatten, "flatten", false, "Put all files directly into --output, adding \" (1)\" and so on to repeated names")
+	flag.IntVar(&config.StripComponents, "strip-components", 0, "Leave out this many leading folders of every path, like tar")
+	flag.StringVar(&config.ByDate, "by-date", "", "Sort files into YYYY/MM folders by their taken (EXIF, else modified), modified or created date")
+	flag.StringVar(&config.NameTemplate, "name-template", "", "Local path of every file from its metadata, e.g. '{dir}/{modified:2006-01-02}_{name}' or '{md5}{ext}'")
+	flag.DurationVar(&config.CacheTTL, "cache-ttl", 0, "Reuse listings of the same link and path made within this time, e.g. 1h (0 to always list)")
+	flag.BoolVar(&config.Refresh, "refresh", false, "List again even if --cache-ttl has a recent listing, and cache the result")
+	flag.BoolVar(&config.Resume, "resume", false, "Continue a previous run into the same output folder")
+	flag.Var(&config.MinSize, "min-size", "Skip files smaller than this size, e.g. 100K")
+	flag.Var(&config.MaxSize, "max-size", "Skip files larger than this size, e.g. 2G")
+	flag.Var(&config.NewerThan, "newer-than", "Only files modified after this time: 2024-05-01, RFC 3339 or an age like 7d")
+	flag.Var(&config.Since, "since", "Only files modified after this time like --newer-than, or \"last\" for since the last run into --output that downloaded everything")
+	flag.Var(&config.OlderThan, "older-than", "Only files modified before this time: 2024-05-01, RFC 3339 or an age like 7d")
+	flag.Var(&config.Extensions, "ext", "Only files with these extensions, such as jpg or tar.gz (repeatable, comma-separated)")
+	flag.Var(&config.BlockedExts, "exclude-ext", "Leave out files with these extensions, such as exe,scr (repeatable, comma-separated)")
+	flag.Var(&config.MediaTypes, "media-type", "Only files of these media types: image, video, audio, document... (repeatable, comma-separated)")
+	flag.IntVar(&config.MaxFiles, "max-files", 0, "Download at most this many files (0 for no limit), see --over-limit")
+	flag.Var(&config.MaxTotalSize, "max-total-size", "Download at most this much in total, e.g. 500G, see --over-limit")
+	flag.StringVar(&config.OverLimit, "over-limit", "stop", "When --max-files or --max-total-size is exceeded: stop, or skip the files past the limit")
+	flag.StringVar(&config.Sort, "sort", "", "Order files by name, path, size, created or modified; prefix with - to reverse")
+	flag.IntVar(&config.MaxDepth, "max-depth", 0, "Descend at most this many folder levels, 1 for only the files directly in the path (0 for no limit)")
+	flag.StringVar(&config.PreviewSize, "preview-size", "", "Download previews of this size (S, M, L, XL, XXL, XXXL or WIDTHxHEIGHT) instead of originals")
+	flag.StringVar(&config.QueueDB, "queue-db", "", "Keep the download queue in this SQLite database instead of the JSON journal (for very large shares)")
+	flag.StringVar(&config.IfExists, "if-exists", "overwrite", "What to do with files already in the output folder: overwrite, skip, rename or error")
+	flag.StringVar(&config.Infected, "infected", "warn", "What to do with files the Yandex.Disk antivirus reports infected: warn, skip, or allow without a warning")
+	flag.BoolVar(&config.Force, "force", false, "Start even if the output folder looks too small for the download or another run holds its lock")
+	flag.BoolVar(&config.FailFast, "fail-fast", false, "Stop at the first failed file instead of downloading the rest")
+	flag.IntVar(&config.RetryPasses, "retry-passes", 1, "Attempt files that failed again this many times after the main pass")
+	flag.StringVar(&config.RetryFile, "retry-file", "", "Where to list files still failing at the end (default OUTPUT/"+retryFileName+")")
+	flag.StringVar(&config.RetryFrom, "retry-from", "", "Download only the files listed in this retry file by an earlier run")
+	flag.StringVar(&config.Exec, "exec", "", "Run this shell command for every downloaded file, {} is replaced with its path")
+	flag.IntVar(&config.ExecJobs, "exec-jobs", 1, "Run up to this many --exec commands at a time")
+	flag.StringVar(&config.ExecErrors, "exec-errors", "warn", "When an --exec command fails: warn, or fail to count the file as failed")
+	flag.StringVar(&config.Checksums, "checksums", "", "Write md5 or sha256 checksums of the downloaded files to MD5SUMS or SHA256SUMS")
+	flag.BoolVar(&config.ChecksumsPerDir, "checksums-per-dir", false, "Write a checksum file into every folder instead of one in the output folder")
+	flag.BoolVar(&config.ServedNames, "served-names", false, "Save files under the name their download is sent with (Content-Disposition) where it differs from the listing")
+	flag.BoolVar(&config.EmptyFolders, "empty-folders", false, "Also create the folders of the share without any downloaded files, with their modification times (not when resuming)")
+	flag.BoolVar(&config.FolderTimes, "folder-times", false, "Give the downloaded folders the modification times of the share (not when resuming)")
+	flag.BoolVar(&config.Preallocate, "preallocate", false, "Reserve disk space for files of 1 MB and more before downloading them, against fragmentation (Linux and macOS)")
+	flag.BoolVar(&config.SyncWrites, "sync", false, "Flush every downloaded file and its folder to disk, so finished files survive a power failure")
+	flag.StringVar(&config.Compress, "compress", "none", "Compress downloaded files with gzip (name.gz) or zstd (name.zst), or none; --checksums and --digests keep the hashes of the originals")
+	flag.StringVar(&config.EncryptTo, "encrypt-to", "", "Encrypt downloaded files (name.age) to the age recipients listed in this file, one public key per line")
+	flag.StringVar(&config.CASObjects, "cas-objects", "", "Store every file once in this folder by its SHA256 and show the listing in --output as --cas-tree says; files already stored are not downloaded again")
+	flag.StringVar(&config.CASTree, "cas-tree", "symlink", "How --output shows a --cas-objects download: symlink, hardlink, or index for an "+yadloader.CASIndexFile+" file")
+	flag.BoolVar(&config.SniffTypes, "sniff-types", false, "Warn about downloaded files whose first bytes do not match their media type or extension, also listed in --report-file")
+	flag.StringVar(&config.ConfigFile, "config", "", "Config file of flag = value lines and [profile] sections (default "+cmp.Or(defaultConfigFile(), "none")+")")
+	flag.StringVar(&config.Profile, "profile", "", "Profile of the config file to take flags from (default $YADOWNLOAD_PROFILE)")
+	flag.StringVar(&config.SavePath, "save-path", "", "Folder of your disk save copies the share into (default your Downloads folder)")
+	flag.BoolVar(&config.Permanent, "permanent", false, "Make delete remove the paths for good instead of moving them to the Trash")
+	flag.StringVar(&config.ClientID, "client-id", "", "ID of your app at oauth.yandex.ru for auth login to get a token with (default $YADISK_CLIENT_ID)")
+	flag.StringVar(&config.ClientSecret, "client-secret", "", "Secret of the --client-id app (default $YADISK_CLIENT_SECRET)")
+	flag.DurationVar(&config.OpTimeout, "operation-timeout", 0, "Give up waiting for copy, move, delete or save of a folder done by the server after this long, e.g. 1h (0 for no limit)")
+	flag.IntVar(&config.RetryBudget, "retry-budget", 0, "Retry at most this many times over the whole run, requests, reconnects and retried files together, then fail what is left at once (0 for no limit)")
+	flag.DurationVar(&config.RetryTime, "retry-budget-time", 0, "Wait at most this long before retries over the whole run, e.g. 10m, then fail what is left at once (0 for no limit)")
+	flag.DurationVar(&config.WaitLock, "wait-lock", 0, "Wait this long for another run downloading into --output to finish, instead of failing at once")
+	flag.StringVar(&config.StatusAddr, "status-addr", "", "Serve the progress of the run as JSON on this address, e.g. localhost:8099")
+	flag.StringVar(&config.Lang, "lang", "", "Language of messages and output, en or ru (default $YADOWNLOAD_LANG, then en)")
+	flag.BoolVar(&config.Digests, "digests", false, "Hash downloaded files with MD5, SHA256 and CRC32 in one pass, failing those that do not match the listing; the hashes go to --report-file")
+	flag.BoolVar(&config.Xattrs, "xattrs", false, "Store the MD5, SHA256 and resource ID of downloaded files in "+yadloader.XattrPrefix+"* extended attributes, which diff-local reads instead of hashing")
+	flag.StringVar(&config.ReportFile, "report-file", "", "Write the run summary as JSON to this file")
+	flag.StringVar(&config.NotifyURL, "notify-url", "", "POST the run summary as JSON to this URL when a download finishes")
+	flag.StringVar(&config.TelegramToken, "telegram-token", "", "Telegram bot token for --telegram-chat (default $TELEGRAM_BOT_TOKEN)")
+	flag.StringVar(&config.TelegramChat, "telegram-chat", "", "Send a summary to this Telegram chat ID or @channel when a download finishes")
+	flag.StringVar(&config.SlackToken, "slack-token", "", "Slack bot token for --slack-channel (default $SLACK_TOKEN)")
+	flag.StringVar(&config.SlackChannel, "slack-channel", "", "Send a summary to this Slack channel when a download finishes")
+	flag.StringVar(&config.CACert, "ca-cert", "", "Also trust the certificates in this PEM file, e.g. of a TLS-intercepting proxy")
+	flag.BoolVar(&config.Insecure, "insecure", false, "Do not verify TLS certificates (debugging only)")
+	flag.BoolVar(&config.DebugHTTP, "debug-http", false, "Log every HTTP request with its status, timing and API response body")
+	flag.IntVar(&config.DebugHTTPBody, "debug-http-body", 2048, "Log at most this many bytes of each API response body with --debug-http (-1 for all)")
+	flag.BoolVar(&config.JSON, "json", false, "Emit one JSON event per line instead of human-readable logs")
+	flag.BoolVar(&config.Version, "version", false, "Print the version and build details and exit")
+	flag.BoolVar(&config.TUI, "tui", false, "Show a full-screen dashboard of transfers, speeds, failures and log lines while downloading")
+	flag.DurationVar(&config.ProgressEvery, "progress-interval", 10*time.Second, "Report transfer speed and time left this often while downloading (0 to turn off)")
+	flag.DurationVar(&config.Interval, "interval", 15*time.Minute, "How often watch lists the share again")
+	flag.StringVar(&config.Listen, "listen", "localhost:8080", "Address serve answers HTTP requests on")
+	flag.IntVar(&config.Jobs, "jobs", 1, "Jobs serve runs, or links of --links-file downloaded, at a time; later ones wait in the queue")
+	flag.StringVar(&config.APISecret, "api-secret", "", "Bearer secret serve requires with every request (default $YADOWNLOAD_API_SECRET)")
+	flag.BoolVar(&config.OwnDiskJobs, "own-disk-jobs", false, "Let serve run jobs without a link, which download the --token owner's own disk")
+
 	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options]\n\n", os.Args[0])
-		fmt.Fprintln(flag.CommandLine.Output(), "Options:")
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [watch|serve|stat|duplicates|diff|diff-local|save] [options]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s upload [options] local-path disk-path\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s copy|move [options] disk-path disk-path\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s delete [options] disk-path...\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s trash list [trash-path] | restore trash-path... | empty [trash-path...]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s quota [--json]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s auth login|logout|status [--profile name]\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s completion bash|zsh|fish\n", os.Args[0])
+		fmt.Fprintf(flag.CommandLine.Output(), "       %s version\n\n", os.Args[0])
+		fmt.Fprintln(flag.CommandLine.Output(), "Commands:")
+		fmt.Fprintln(flag.CommandLine.Output(), "  watch  List the share every --interval and download new and changed files")
+		fmt.Fprintln(flag.CommandLine.Output(), "  serve  Run download jobs submitted over an HTTP API on --listen")
+		fmt.Fprintln(flag.CommandLine.Output(), "  upload Copy a file or a folder with everything in it to your disk, --concurrency files at a time")
+		fmt.Fprintln(flag.CommandLine.Output(), "  copy   Copy a file or folder of your disk to another path; --if-exists overwrite replaces what is there")
+		fmt.Fprintln(flag.CommandLine.Output(), "  move   Move a file or folder of your disk to another path, as copy does")
+		fmt.Fprintln(flag.CommandLine.Output(), "  delete Move files and folders of your disk to the Trash, or remove them with --permanent")
+		fmt.Fprintln(flag.CommandLine.Output(), "  trash  List what is in the Trash of your disk, restore it where it was deleted from, or remove it for good")
+		fmt.Fprintln(flag.CommandLine.Output(), "  save   Copy the share into --save-path of your disk on the server, then download the copy with --output")
+		fmt.Fprintln(flag.CommandLine.Output(), "  quota  Show the total, used, Trash and free space of your disk")
+		fmt.Fprintln(flag.CommandLine.Output(), "  auth   Sign in with --client-id, or store --token, in the system keyring; remove it or check for it")
+		fmt.Fprintln(flag.CommandLine.Output(), "\nOptions:")
 
 		flag.PrintDefaults()
 		fmt.Fprintln(flag.CommandLine.Output(), "\nExamples:")
 		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload -l https://disk.yandex.ru/d/abc123")
 		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --path /documents")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123/documents --output download")
 		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --path /documents --output download")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --concurrency 8")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --min-size 50K --max-size 1G")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --newer-than 24h")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --since last")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output photos --media-type image")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output share --exclude-ext exe,scr,bat,cmd")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output import --sniff-types --report-file report.json")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --lang ru")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --status-addr localhost:8099")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --resume --wait-lock 30m")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --profile photos --link https://disk.yandex.ru/d/abc123")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload upload --if-exists skip backup /Backup/2026")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload move --if-exists error /Inbox/report.pdf /Archive/2026/report.pdf")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload delete --permanent /Backup/2025")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload trash restore --if-exists skip /report.pdf /Photos")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload trash empty")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload quota --json")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload copy --operation-timeout 2h /Photos /Backup/Photos")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload save --link https://disk.yandex.ru/d/abc123 --save-path /Shares --output download")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload auth login --profile photos")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload auth login --client-id 0123456789abcdef --client-secret fedcba9876543210")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --sort -size")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --max-total-size 100G --max-files 10000")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output gallery --preview-size 800x600")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --token $YADISK_TOKEN --path /Photos --output backup")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --path /Raw --path /Edited --output download")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --json")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --tui")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --debug-http 2> http.log")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --ca-cert proxy-ca.pem")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --resume")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --if-exists skip")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output mirror --infected skip")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output videos --exec 'ffmpeg -i {} {}.mp4' --exec-jobs 2")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --max-duration 6h --file-timeout 30m")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --breaker-failures 10 --breaker-cooldown 5m")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --retry-budget 200 --retry-budget-time 15m")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --pipeline")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --pick")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output photos --flatten")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --strip-components 1")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output photos --by-date taken")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output photos --name-template '{modified:2006-01-02}_{name}'")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --links-file links.txt --output download --jobs 2")
+		fmt.Fprintln(flag.CommandLine.Output(), "  cat links.txt | yadownload --links-file - --output download --links-folder '{n}-{name}'")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload watch --link https://disk.yandex.ru/d/abc123 --output download --interval 15m")
+		fmt.Fprintln(flag.CommandLine.Output(), "  YADOWNLOAD_API_SECRET=s3cret yadownload serve --listen :8080 --output /srv/downloads --jobs 2")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload serve --api-secret s3cret --output /srv/downloads --token $YADISK_TOKEN --own-disk-jobs")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload stat --link https://disk.yandex.ru/d/abc123")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload duplicates --link https://disk.yandex.ru/d/abc123 --json")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload diff --link https://disk.yandex.ru/d/abc123 --link2 https://disk.yandex.ru/d/def456")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload diff-local --link https://disk.yandex.ru/d/abc123 --output download --json")
+		fmt.Fprintln(flag.CommandLine.Output(), "  source <(yadownload completion bash)")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --cache-ttl 1h --media-type video")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --checksums sha256")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --xattrs")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --digests --report-file report.json")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --served-names --report-file report.json")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output mirror --empty-folders --folder-times")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output videos --preallocate")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output /mnt/archive --sync --digests")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output cold --compress zstd --checksums sha256")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output /mnt/untrusted --encrypt-to recipients.txt")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output snapshots/2026-10-14 --cas-objects objects --cas-tree hardlink")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --notify-url https://ci.example.com/hooks/yadisk")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --telegram-chat 123456789")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --slack-channel '#downloads'")
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --output download --retry-from download/"+retryFileName)
+		fmt.Fprintln(flag.CommandLine.Output(), "  yadownload --link https://disk.yandex.ru/d/abc123 --output download --queue-db queue.db --resume")
+		fmt.Fprintln(flag.CommandLine.Output(), exitCodes)
 	}
 
-	flag.Parse()
+	args := os.Args[1:]
+	if len(args) > 0 && args[0] == "completion" {
+		if len(args) != 2 {
+			fmt.Fprintf(os.Stderr, tr("Error: completion takes one of %s\n"), strings.Join(completionShells, ", "))
+			os.Exit(1)
+		}
+		if err := writeCompletion(os.Stdout, args[1], filepath.Base(os.Args[0])); err != nil {
+			fmt.Fprintf(os.Stderr, tr("Error: completion: %v\n"), err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	if len(args) > 0 && slices.Contains([]string{"watch", "serve", "stat", "duplicates", "diff", "diff-local", "upload", "copy", "move", "delete", "trash", "save", "quota", "auth"}, args[0]) {
+		config.Command = args[0]
+		args = args[1:]
+	}
+	flag.CommandLine.Parse(args)
+	if config.Version || config.Command == "" && len(args) > 0 && args[0] == "version" {
+		writeVersion(os.Stdout, filepath.Base(os.Args[0]))
+		os.Exit(0)
+	}
 
-	// Проверка обязательного параметра
-	if config.Link == "" {
-		fmt.Fprintln(os.Stderr, "Error: link is required")
+	if config.Profile == "" {
+		config.Profile = os.Getenv("YADOWNLOAD_PROFILE")
+	}
+	explicit := config.ConfigFile != ""
+	if !explicit {
+		config.ConfigFile = defaultConfigFile()
+	}
+	switch {
+	case config.ConfigFile == "" && config.Profile != "":
+		fmt.Fprintln(os.Stderr, "Error: --profile needs --config, there is no default config file here")
+		os.Exit(1)
+	case config.ConfigFile != "":
+		if err := applyProfile(config.ConfigFile, config.Profile, explicit); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: --config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if config.Lang == "" {
+		config.Lang = cmp.Or(os.Getenv("YADOWNLOAD_LANG"), "en")
+	}
+	if err := setLocale(config.Lang); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --lang: %v\n", err)
+		os.Exit(1)
+	}
+	if config.Token == "" {
+		config.Token = os.Getenv("YADISK_TOKEN")
+	}
+	if config.ClientID == "" {
+		config.ClientID = os.Getenv("YADISK_CLIENT_ID")
+	}
+	if config.ClientSecret == "" {
+		config.ClientSecret = os.Getenv("YADISK_CLIENT_SECRET")
+	}
+	if config.TelegramToken == "" {
+		config.TelegramToken = os.Getenv("TELEGRAM_BOT_TOKEN")
+	}
+	if config.SlackToken == "" {
+		config.SlackToken = os.Getenv("SLACK_TOKEN")
+	}
+	if config.APISecret == "" {
+		config.APISecret = os.Getenv("YADOWNLOAD_API_SECRET")
+	}
+	if config.Command == "auth" {
+		os.Exit(auth(config, flag.Args()))
+	}
+	if config.Token == "" {
+		if config.Login = keyringToken(config.Profile); config.Login != nil {
+			config.Token = config.Login.AccessToken
+		}
+	}
+
+	switch config.Command {
+	case "upload", "copy", "move", "delete", "trash", "save", "quota":
+		if config.Token == "" {
+			fmt.Fprintf(os.Stderr, tr("Error: %s requires --token, $YADISK_TOKEN or auth login\n"), config.Command)
+			os.Exit(exitLink)
+		}
+	}
+	switch {
+	case config.Command == "upload" && flag.NArg() != 2:
+		fmt.Fprintln(os.Stderr, tr("Error: upload takes a local file or folder and a path on the disk"))
+		os.Exit(1)
+	case (config.Command == "copy" || config.Command == "move") && flag.NArg() != 2:
+		fmt.Fprintf(os.Stderr, tr("Error: %s takes the path to take and the path to put it on the disk\n"), config.Command)
+		os.Exit(1)
+	case config.Command == "delete" && flag.NArg() == 0:
+		fmt.Fprintln(os.Stderr, tr("Error: delete takes the paths on the disk to delete"))
+		os.Exit(1)
+	case config.Command == "trash" && !slices.Contains([]string{"list", "restore", "empty"}, flag.Arg(0)):
+		fmt.Fprintln(os.Stderr, tr("Error: trash takes one of list, restore, empty"))
+		os.Exit(1)
+	case config.Command == "trash" && flag.Arg(0) == "list" && flag.NArg() > 2:
+		fmt.Fprintln(os.Stderr, tr("Error: trash list takes at most one path in the Trash"))
+		os.Exit(1)
+	case config.Command == "trash" && flag.Arg(0) == "restore" && flag.NArg() == 1:
+		fmt.Fprintln(os.Stderr, tr("Error: trash restore takes the paths in the Trash to restore"))
+		os.Exit(1)
+	case config.Command == "save" && config.Link == "":
+		fmt.Fprintln(os.Stderr, tr("Error: save requires --link"))
+		os.Exit(1)
+	}
+
+	// Check the required flags
+	if config.Link == "" && config.Token == "" && config.RetryFrom == "" && config.LinksFile == "" && config.Command != "serve" {
+		fmt.Fprintln(os.Stderr, tr("Error: link is required"))
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	if config.Link != "" {
+		key, sub, err := yadloader.ParsePublicLink(config.Link)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, tr("Error: %v\n"), err)
+			os.Exit(exitLink)
+		}
+		config.Link = key
+		if sub != "" && len(config.Paths) == 0 {
+			config.Paths = repeatValue{sub}
+		} else if sub != "" {
+			for i, p := range config.Paths {
+				config.Paths[i] = path.Join(sub, p)
+			}
+		}
+	}
+	// Several paths are stored and reported as one.
+	config.Path = config.Paths.String()
+	if len(config.Paths) > 1 && (config.Pipeline || config.Command == "diff" || config.Command == "save") {
+		fmt.Fprintln(os.Stderr, tr("Error: --pipeline, diff and save take a single --path"))
+		os.Exit(1)
+	}
+
+	if config.Command == "diff-local" && config.Folder == "" {
+		fmt.Fprintln(os.Stderr, tr("Error: diff-local requires --output"))
+		os.Exit(1)
+	}
+
+	if config.Command == "diff" {
+		if config.Link2 == "" {
+			fmt.Fprintln(os.Stderr, tr("Error: diff requires --link2"))
+			os.Exit(1)
+		}
+		key, sub, err := yadloader.ParsePublicLink(config.Link2)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, tr("Error: --link2: %v\n"), err)
+			os.Exit(exitLink)
+		}
+		config.Link2, config.Path2 = key, path.Join(sub, config.Path2)
+	}
+
+	if (config.Resume || config.QueueDB != "" || config.RetryFrom != "") && config.Folder == "" {
+		fmt.Fprintln(os.Stderr, tr("Error: --resume, --queue-db and --retry-from require --output"))
 		flag.Usage()
 		os.Exit(1)
 	}
 
+	if config.Exec != "" && config.ExecJobs < 1 {
+		fmt.Fprintln(os.Stderr, tr("Error: --exec-jobs must be at least 1"))
+		os.Exit(1)
+	}
+	if config.ExecErrors != "warn" && config.ExecErrors != "fail" {
+		fmt.Fprintf(os.Stderr, tr("Error: --exec-errors: unknown value %q\n"), config.ExecErrors)
+		os.Exit(1)
+	}
+
+	if config.Checksums != "" {
+		if _, err := yadloader.ParseChecksumType(config.Checksums); err != nil {
+			fmt.Fprintf(os.Stderr, tr("Error: --checksums: %v\n"), err)
+			os.Exit(1)
+		}
+		if config.PreviewSize != "" {
+			fmt.Fprintln(os.Stderr, tr("Error: --checksums cannot be used with --preview-size"))
+			os.Exit(1)
+		}
+	}
+
+	if config.EmptyFolders || config.FolderTimes {
+		name := "--empty-folders"
+		if !config.EmptyFolders {
+			name = "--folder-times"
+		}
+		switch {
+		case config.Flatten || config.ByDate != "" || config.StripComponents > 0 || config.NameTemplate != "":
+			fmt.Fprintf(os.Stderr, tr("Error: %s cannot be used with --flatten, --by-date, --strip-components or --name-template\n"), name)
+			os.Exit(1)
+		case config.CacheTTL > 0 || config.RetryFrom != "":
+			// The cache and retry files keep files only.
+			fmt.Fprintf(os.Stderr, tr("Error: %s cannot be used with --cache-ttl or --retry-from\n"), name)
+			os.Exit(1)
+		}
+	}
+
+	if config.Pipeline {
+		switch {
+		case config.Folder == "":
+			fmt.Fprintln(os.Stderr, tr("Error: --pipeline requires --output"))
+			os.Exit(1)
+		case config.Resume || config.QueueDB != "" || config.RetryFrom != "" || config.Checksums != "" || config.Sort != "" || config.PreviewSize != "" || config.MaxDepth != 0:
+			fmt.Fprintln(os.Stderr, tr("Error: --pipeline cannot be used with --resume, --queue-db, --retry-from, --checksums, --sort, --preview-size or --max-depth"))
+			os.Exit(1)
+		case config.Flatten || config.ByDate != "" || config.NameTemplate != "" || config.Pick:
+			// Names of repeated files and picking depend on the whole listing.
+			fmt.Fprintln(os.Stderr, tr("Error: --pipeline cannot be used with --flatten, --by-date, --name-template or --pick"))
+			os.Exit(1)
+		}
+	}
+
+	if config.Command == "watch" {
+		switch {
+		case config.Folder == "":
+			fmt.Fprintln(os.Stderr, tr("Error: watch requires --output"))
+			os.Exit(1)
+		case config.Interval <= 0:
+			fmt.Fprintln(os.Stderr, tr("Error: --interval must be positive"))
+			os.Exit(1)
+		case config.Resume || config.RetryFrom != "" || config.Pipeline || config.Checksums != "" || config.CacheTTL > 0 || config.Since.Last:
+			fmt.Fprintln(os.Stderr, tr("Error: watch cannot be used with --resume, --retry-from, --pipeline, --checksums, --cache-ttl or --since last"))
+			os.Exit(1)
+		case config.Flatten || config.ByDate != "" || config.NameTemplate != "":
+			// Files added later could take the names of downloaded ones.
+			fmt.Fprintln(os.Stderr, tr("Error: watch cannot be used with --flatten, --by-date or --name-template"))
+			os.Exit(1)
+		case config.Pick:
+			fmt.Fprintln(os.Stderr, tr("Error: watch cannot be used with --pick"))
+			os.Exit(1)
+		}
+	}
+
+	if config.Command == "serve" {
+		switch {
+		case config.Folder == "":
+			fmt.Fprintln(os.Stderr, tr("Error: serve requires --output"))
+			os.Exit(1)
+		case config.Jobs < 1:
+			fmt.Fprintln(os.Stderr, tr("Error: --jobs must be at least 1"))
+			os.Exit(1)
+		case config.APISecret == "":
+			fmt.Fprintln(os.Stderr, tr("Error: serve requires --api-secret or $YADOWNLOAD_API_SECRET"))
+			os.Exit(1)
+		case config.OwnDiskJobs && config.Token == "":
+			fmt.Fprintf(os.Stderr, tr("Error: %s requires --token, $YADISK_TOKEN or auth login\n"), "--own-disk-jobs")
+			os.Exit(1)
+		case config.Link != "" || config.Path != "":
+			fmt.Fprintln(os.Stderr, tr("Error: serve takes links and paths with each job, not --link and --path"))
+			os.Exit(1)
+		case config.Resume || config.RetryFrom != "" || config.Pipeline || config.Checksums != "" || config.CacheTTL > 0 || config.Since.Last || config.Pick:
+			fmt.Fprintln(os.Stderr, tr("Error: serve cannot be used with --resume, --retry-from, --pipeline, --checksums, --cache-ttl, --since last or --pick"))
+			os.Exit(1)
+		case config.StatusAddr != "":
+			fmt.Fprintln(os.Stderr, tr("Error: serve shows progress with GET /jobs, not --status-addr"))
+			os.Exit(1)
+		}
+	}
+
+	if config.NotifyURL != "" {
+		if u, err := url.Parse(config.NotifyURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			fmt.Fprintf(os.Stderr, tr("Error: --notify-url: %q is not an http(s) URL\n"), config.NotifyURL)
+			os.Exit(1)
+		}
+	}
+
+	if config.TelegramChat != "" && config.TelegramToken == "" {
+		fmt.Fprintln(os.Stderr, tr("Error: --telegram-chat requires --telegram-token or $TELEGRAM_BOT_TOKEN"))
+		os.Exit(1)
+	}
+	if config.SlackChannel != "" && config.SlackToken == "" {
+		fmt.Fprintln(os.Stderr, tr("Error: --slack-channel requires --slack-token or $SLACK_TOKEN"))
+		os.Exit(1)
+	}
+
+	if config.TUI {
+		switch {
+		case config.JSON || config.Command == "serve":
+			fmt.Fprintln(os.Stderr, tr("Error: --tui cannot be used with --json or serve"))
+			os.Exit(1)
+		case !term.IsTerminal(int(os.Stdout.Fd())):
+			fmt.Fprintln(os.Stderr, tr("Error: --tui needs a terminal"))
+			os.Exit(1)
+		}
+		// The dashboard shows speeds as they change, unless told otherwise.
+		progressSet := false
+		flag.Visit(func(f *flag.Flag) { progressSet = progressSet || f.Name == "progress-interval" })
+		if !progressSet {
+			config.ProgressEvery = time.Second
+		}
+	}
+
+	if config.ProgressEvery < 0 {
+		fmt.Fprintln(os.Stderr, tr("Error: --progress-interval must not be negative"))
+		os.Exit(1)
+	}
+
+	if config.CacheTTL < 0 {
+		fmt.Fprintln(os.Stderr, tr("Error: --cache-ttl must not be negative"))
+		os.Exit(1)
+	}
+	if config.CacheTTL > 0 && config.PreviewSize != "" {
+		// Preview links cannot be fetched again once they expire.
+		log.Print(tr("Warning: --cache-ttl is ignored with --preview-size"))
+		config.CacheTTL = 0
+	}
+
+	if config.MaxFiles < 0 {
+		fmt.Fprintln(os.Stderr, tr("Error: --max-files must not be negative"))
+		os.Exit(1)
+	}
+	if config.OverLimit != "stop" && config.OverLimit != "skip" {
+		fmt.Fprintf(os.Stderr, tr("Error: --over-limit: unknown value %q\n"), config.OverLimit)
+		os.Exit(1)
+	}
+
+	if config.StripComponents < 0 {
+		fmt.Fprintln(os.Stderr, tr("Error: --strip-components must not be negative"))
+		os.Exit(1)
+	}
+	if config.Pick && (config.Resume || config.RetryFrom != "") {
+		fmt.Fprintln(os.Stderr, tr("Error: --pick cannot be used with --resume or --retry-from"))
+		os.Exit(1)
+	}
+
+	if config.NameTemplate != "" {
+		if _, err := yadloader.NameTemplate(config.NameTemplate); err != nil {
+			fmt.Fprintf(os.Stderr, tr("Error: --name-template: %v\n"), err)
+			os.Exit(1)
+		}
+	}
+	if config.ByDate != "" && dateOf(config.ByDate) == nil {
+		fmt.Fprintf(os.Stderr, tr("Error: --by-date: unknown value %q\n"), config.ByDate)
+		os.Exit(1)
+	}
+	if countSet(config.Flatten, config.ByDate != "", config.StripComponents > 0, config.NameTemplate != "") > 1 {
+		fmt.Fprintln(os.Stderr, tr("Error: use only one of --flatten, --by-date, --strip-components and --name-template"))
+		os.Exit(1)
+	}
+
+	if config.MaxDepth < 0 {
+		fmt.Fprintln(os.Stderr, tr("Error: --max-depth must not be negative"))
+		os.Exit(1)
+	}
+
+	if config.BreakerFailures < 0 || config.BreakerCooldown < 0 {
+		fmt.Fprintln(os.Stderr, tr("Error: --breaker-failures and --breaker-cooldown must not be negative"))
+		os.Exit(1)
+	}
+
+	if config.RateLimit < 0 {
+		fmt.Fprintln(os.Stderr, tr("Error: --rate-limit must not be negative"))
+		os.Exit(1)
+	}
+
+	if config.RetryPasses < 0 {
+		fmt.Fprintln(os.Stderr, tr("Error: --retry-passes must not be negative"))
+		os.Exit(1)
+	}
+	if config.LinksFile != "" {
+		switch {
+		case config.Folder == "":
+			fmt.Fprintln(os.Stderr, tr("Error: --links-file requires --output"))
+			os.Exit(1)
+		case config.Command != "":
+			fmt.Fprintf(os.Stderr, tr("Error: %s cannot be used with --links-file\n"), config.Command)
+			os.Exit(1)
+		case config.Jobs < 1:
+			fmt.Fprintln(os.Stderr, tr("Error: --jobs must be at least 1"))
+			os.Exit(1)
+		case config.Link != "" || config.RetryFrom != "" || config.Pick:
+			fmt.Fprintln(os.Stderr, tr("Error: --links-file cannot be used with --link, --retry-from or --pick"))
+			os.Exit(1)
+		case config.QueueDB != "" || config.ReportFile != "" || config.RetryFile != "":
+			// Every link keeps its state and retry file in its own folder.
+			fmt.Fprintln(os.Stderr, tr("Error: --links-file cannot be used with --queue-db, --report-file or --retry-file"))
+			os.Exit(1)
+		case config.TUI && config.Jobs > 1:
+			fmt.Fprintln(os.Stderr, tr("Error: --tui shows one link at a time, use it without --jobs"))
+			os.Exit(1)
+		}
+		if _, err := linksFolder(config.LinksFolder, 1, "key", "name"); err != nil {
+			fmt.Fprintf(os.Stderr, tr("Error: --links-folder: %v\n"), err)
+			os.Exit(1)
+		}
+	}
+	if config.RetryFile == "" && config.Folder != "" {
+		config.RetryFile = filepath.Join(config.Folder, retryFileName)
+	}
+
+	if config.Sort != "" {
+		if _, _, err := yadloader.ParseSort(config.Sort); err != nil {
+			fmt.Fprintf(os.Stderr, tr("Error: --sort: %v\n"), err)
+			os.Exit(1)
+		}
+	}
+
+	if _, err := yadloader.ParseOverwritePolicy(config.IfExists); err != nil {
+		fmt.Fprintf(os.Stderr, tr("Error: --if-exists: %v\n"), err)
+		os.Exit(1)
+	}
+	if _, err := yadloader.ParseAntivirusPolicy(config.Infected); err != nil {
+		fmt.Fprintf(os.Stderr, tr("Error: --infected: %v\n"), err)
+		os.Exit(1)
+	}
+	if _, err := yadloader.ParseCompression(config.Compress); err != nil {
+		fmt.Fprintf(os.Stderr, tr("Error: --compress: %v\n"), err)
+		os.Exit(1)
+	}
+	if _, err := yadloader.ParseCASTree(config.CASTree); err != nil {
+		fmt.Fprintf(os.Stderr, tr("Error: --cas-tree: %v\n"), err)
+		os.Exit(1)
+	}
+	if config.CASObjects != "" && (config.Flatten || config.ByDate != "" || config.StripComponents > 0 || config.NameTemplate != "" || config.PreviewSize != "" || config.ServedNames) {
+		fmt.Fprintln(os.Stderr, tr("Error: --cas-objects cannot be used with --flatten, --by-date, --strip-components, --name-template, --preview-size or --served-names"))
+		os.Exit(1)
+	}
+	if config.EncryptTo != "" {
+		if _, err := loadRecipients(config.EncryptTo); err != nil {
+			fmt.Fprintf(os.Stderr, tr("Error: --encrypt-to: %v\n"), err)
+			os.Exit(1)
+		}
+	}
+
+	if config.MaxSize > 0 && config.MinSize > config.MaxSize {
+		fmt.Fprintln(os.Stderr, tr("Error: --min-size is greater than --max-size"))
+		os.Exit(1)
+	}
+
+	if config.Since.Last {
+		switch {
+		case config.Folder == "":
+			fmt.Fprintln(os.Stderr, tr("Error: --since last requires --output"))
+			os.Exit(1)
+		case config.Pipeline:
+			fmt.Fprintln(os.Stderr, tr("Error: --since last cannot be used with --pipeline"))
+			os.Exit(1)
+		}
+	} else if config.Since.After(config.NewerThan.Time) {
+		config.NewerThan = config.Since.timeValue
+	}
+
+	if !config.NewerThan.IsZero() && !config.OlderThan.IsZero() && !config.NewerThan.Before(config.OlderThan.Time) {
+		fmt.Fprintln(os.Stderr, tr("Error: --newer-than must be earlier than --older-than"))
+		os.Exit(1)
+	}
+
+	config.Concurrency = checkConcurrency("concurrency", config.Concurrency, yadloader.MaxConcurrency, safeConcurrency)
+	config.ListConcurrency = checkConcurrency("list-concurrency", config.ListConcurrency, yadloader.MaxListConcurrency, safeListConcurrency)
+
 	return config
 }
 
+func checkConcurrency(name string, value, max, safe int) int {
+	switch {
+	case value < 1:
+		fmt.Fprintf(os.Stderr, tr("Error: --%s must be at least 1\n"), name)
+		os.Exit(1)
+	case value > max:
+		log.Printf(tr("Warning: --%s %d exceeds the maximum, using %d"), name, value, max)
+		value = max
+	}
+	if value > safe {
+		log.Printf(tr("Warning: --%s %d may trigger Yandex.Disk API rate limits (HTTP 429)"), name, value)
+	}
+	return value
+}
+
+// listingLayout is the layout of --flatten, --by-date or --name-template,
+// which number repeated names throughout the listing.
+func listingLayout(params *Args, files []yadloader.DiskFile) yadloader.Layout {
+	switch {
+	case params.Flatten:
+		return yadloader.FlatLayout(files)
+	case params.NameTemplate != "":
+		layout, _ := yadloader.NameTemplate(params.NameTemplate)
+		return yadloader.UniqueNames(files, layout)
+	}
+	return yadloader.DateLayout(files, dateOf(params.ByDate))
+}
+
+// dateOf returns the date --by-date sorts by, nil for unknown names.
+func dateOf(name string) func(f yadloader.DiskFile) time.Time {
+	switch name {
+	case "taken":
+		return yadloader.TakenOrModified
+	case "modified":
+		return func(f yadloader.DiskFile) time.Time { return f.Modified }
+	case "created":
+		return func(f yadloader.DiskFile) time.Time { return f.Created }
+	}
+	return nil
+}
+
+// countSet counts the flags that are set.
+func countSet(flags ...bool) int {
+	n := 0
+	for _, set := range flags {
+		if set {
+			n++
+		}
+	}
+	return n
+}
+
+func openStore(params *Args) (stateStore, error) {
+	if params.QueueDB != "" {
+		return openQueueStore(params.QueueDB)
+	}
+	return openJournalStore(filepath.Join(params.Folder, yadloader.StateFileName))
+}
+
+// resumable returns true when the store holds a previous run of the same
+// link and path.
+func resumable(store stateStore, params *Args) bool {
+	link, path := store.Source()
+	if link == "" {
+		log.Printf(tr("Nothing to resume in %s, starting a new run"), store.Location())
+		return false
+	}
+	if link != params.source() || path != params.Path {
+		fmt.Fprintf(os.Stderr, tr("Error: %s belongs to a run of %s %s\n"), store.Location(), link, path)
+		os.Exit(1)
+	}
+	return true
+}
+
+// downloadOptions are the DownloadFiles settings taken from the flags;
+// --exec adds its AfterFile to hooks.
+func downloadOptions(params *Args, hooks yadloader.Hooks) []yadloader.Option {
+	overwrite, _ := yadloader.ParseOverwritePolicy(params.IfExists)
+	antivirus, _ := yadloader.ParseAntivirusPolicy(params.Infected)
+	compression, _ := yadloader.ParseCompression(params.Compress)
+	errorPolicy := yadloader.Collect
+	if params.FailFast {
+		errorPolicy = yadloader.FailFast
+	}
+
+	if params.Exec != "" {
+		// Keep stdout to the events in JSON mode.
+		var out io.Writer = os.Stdout
+		if params.JSON {
+			out = os.Stderr
+		}
+		hooks.AfterFile = newExecutor(params.Exec, params.ExecJobs, params.ExecErrors == "fail", out).afterFile
+	}
+
+	opts := []yadloader.Option{
+		yadloader.WithIgnoreFreeSpace(params.Force),
+		yadloader.WithOverwrite(overwrite),
+		yadloader.WithErrorPolicy(errorPolicy),
+		yadloader.WithRetryPasses(params.RetryPasses),
+		yadloader.WithHooks(hooks),
+		yadloader.WithProgressInterval(params.ProgressEvery),
+		yadloader.WithXattrs(params.Xattrs),
+		yadloader.WithDigests(params.Digests),
+		yadloader.WithServedNames(params.ServedNames),
+		yadloader.WithEmptyFolders(params.EmptyFolders),
+		yadloader.WithFolderTimes(params.FolderTimes),
+		yadloader.WithPreallocate(params.Preallocate),
+		yadloader.WithSyncWrites(params.SyncWrites),
+		yadloader.WithCompression(compression),
+		yadloader.WithSniffTypes(params.SniffTypes),
+		yadloader.WithAntivirusPolicy(antivirus),
+	}
+	if params.CASObjects != "" {
+		tree, _ := yadloader.ParseCASTree(params.CASTree)
+		opts = append(opts, yadloader.WithCAS(params.CASObjects, tree))
+	}
+	if params.EncryptTo != "" {
+		recipients, _ := loadRecipients(params.EncryptTo)
+		opts = append(opts, yadloader.WithRecipients(recipients...))
+	}
+	if params.StripComponents > 0 {
+		opts = append(opts, yadloader.WithLayout(yadloader.StripComponents(params.StripComponents)))
+	}
+	return opts
+}
+
+func treeOptions(params *Args, listing func(count, totalSize int64)) []yadloader.TreeOption {
+	opts := []yadloader.TreeOption{yadloader.WithCallback(listing)}
+	if params.Sort != "" {
+		field, reverse, _ := yadloader.ParseSort(params.Sort)
+		opts = append(opts, yadloader.WithSort(field, reverse))
+	}
+	if params.MaxDepth > 0 {
+		opts = append(opts, yadloader.WithMaxDepth(params.MaxDepth))
+	}
+	return opts
+}
+
+// listPaths lists every --path of the share, leaving out files that nested
+// paths list twice.
+func listPaths(ctx context.Context, client *yadloader.YaDiskClient, params *Args, opts []yadloader.TreeOption) ([]yadloader.DiskFile, error) {
+	if len(params.Paths) <= 1 {
+		return client.GetTree(ctx, params.Link, params.Path, opts...)
+	}
+	var files []yadloader.DiskFile
+	seen := make(map[string]bool)
+	for _, p := range params.Paths {
+		list, err := client.GetTree(ctx, params.Link, p, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", p, err)
+		}
+		for _, f := range list {
+			if !seen[f.Path] {
+				seen[f.Path] = true
+				files = append(files, f)
+			}
+		}
+	}
+	return files, nil
+}
+
+// trackProgress records download events in store and passes them on to
+// events, estimating the time left from pendingSize. Files saved under another name by --if-exists rename are added to
+// renamed with their local path.
+func trackProgress(store stateStore, events reporter, output string, pendingSize int64, renamed map[string]string) yadloader.ProgressFunc {
+	return func(e yadloader.Event) {
+		if renamed != nil && e.Type == yadloader.EventFinished && e.Dest != yadloader.LocalPath(output, e.File) {
+			if rel, err := filepath.Rel(output, e.Dest); err == nil {
+				renamed[e.File.Path] = "/" + filepath.ToSlash(rel)
+			}
+		}
+		switch e.Type {
+		case yadloader.EventStarted:
+			events.Started(e.File, e.Dest)
+		case yadloader.EventProgress:
+			events.Progress(e, eta(pendingSize-e.TotalBytes, e.Total))
+		case yadloader.EventSkipped:
+			if err := store.Mark(e.File, yadloader.StatusDone, nil); err != nil {
+				log.Printf(tr("Failed to save state: %v"), err)
+			}
+			events.Skipped(e.File, e.Dest)
+		case yadloader.EventFinished:
+			if err := store.Mark(e.File, yadloader.StatusDone, nil); err != nil {
+				log.Printf(tr("Failed to save state: %v"), err)
+			}
+			events.Finished(e.File, e.Dest, e.Elapsed)
+		case yadloader.EventFailed:
+			// Interrupted files stay pending in the journal.
+			if errors.Is(e.Err, context.Canceled) {
+				return
+			}
+			if err := store.Mark(e.File, yadloader.StatusFailed, e.Err); err != nil {
+				log.Printf(tr("Failed to save state: %v"), err)
+			}
+			events.Failed(e.File, e.Dest, e.Err)
+		}
+	}
+}
+
+// warnInfected warns about a file the antivirus flagged unless --infected
+// says otherwise.
+func warnInfected(params *Args, file yadloader.DiskFile) {
+	if file.Infected() && params.Infected == "warn" {
+		log.Printf(tr("Warning: %s is reported infected by the Yandex.Disk antivirus, use --infected skip to leave such files out"), file.Path)
+	}
+}
+
+// warnMismatches logs the files of --sniff-types that do not look like
+// what they claim to be.
+func warnMismatches(report yadloader.Report) {
+	for _, m := range report.TypeMismatches {
+		log.Printf(tr("Warning: %s looks like %s, not %s"), m.Path, m.Detected, cmp.Or(m.Expected, m.MediaType))
+	}
+}
+
+// eta is the time left for left bytes at speed, negative when unknown.
+func eta(left int64, speed yadloader.Speed) time.Duration {
+	if speed.Average <= 0 {
+		return -1
+	}
+	return time.Duration(float64(max(left, 0)) / speed.Average * float64(time.Second))
+}
+
+// loadRecipients reads the age recipients of --encrypt-to.
+func loadRecipients(filename string) ([]age.Recipient, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return age.ParseRecipients(f)
+}
+
+// encodedExt is what --compress and --encrypt-to add to file names.
+func encodedExt(params *Args) string {
+	compression, _ := yadloader.ParseCompression(params.Compress)
+	ext := compression.Ext()
+	if params.EncryptTo != "" {
+		ext += yadloader.EncryptedExt
+	}
+	return ext
+}
+
+// loadCACert returns the system roots plus the certificates in filename.
+func loadCACert(filename string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates in %s", filename)
+	}
+	return pool, nil
+}
+
+// writeReport writes a yadloader.Report, or the UploadReport of upload.
+func writeReport(filename string, report any) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+// writeChecksums lists every file done in the store, including earlier runs.
+func writeChecksums(store stateStore, params *Args, renamed map[string]string) error {
+	all, err := store.Files()
+	if err != nil {
+		return err
+	}
+	pending, err := store.Pending()
+	if err != nil {
+		return err
+	}
+	left := make(map[string]bool, len(pending))
+	for _, f := range pending {
+		left[f.Path] = true
+	}
+	done := all[:0:0]
+	for _, f := range all {
+		if left[f.Path] {
+			continue
+		}
+		if p, ok := renamed[f.Path]; ok {
+			// Listed under the name of the original, to be checked once
+			// decompressed and decrypted.
+			f.Path = strings.TrimSuffix(p, encodedExt(params))
+		}
+		done = append(done, f)
+	}
+
+	t, _ := yadloader.ParseChecksumType(params.Checksums)
+	written, err := yadloader.WriteChecksums(params.Folder, done, t, params.ChecksumsPerDir)
+	if len(written) > 0 {
+		log.Printf(tr("Wrote checksums of %d files to %d %s files"), len(done), len(written), t.FileName())
+	}
+	return err
+}
+
+// singleFile checks whether the link points to a single file (the
+// disk.yandex.ru/i/... form) rather than a folder.
+func singleFile(ctx context.Context, client *yadloader.YaDiskClient, params *Args) ([]yadloader.DiskFile, bool, error) {
+	if params.Link == "" || len(params.Paths) > 1 {
+		return nil, false, nil
+	}
+	meta, err := client.GetMeta(ctx, params.Link, params.Path)
+	if err != nil || !meta.IsFile() {
+		return nil, false, err
+	}
+	log.Printf(tr("Link is a single file: %s (%d bytes)"), meta.Name, meta.Size)
+	return []yadloader.DiskFile{meta.DiskFile()}, true, nil
+}
+
+// finishFolders creates the empty folders of --empty-folders and sets the
+// times of --folder-times.
+func finishFolders(client *yadloader.YaDiskClient, params *Args, folders []yadloader.DiskFile) {
+	if params.EmptyFolders {
+		if n, err := client.MakeFolders(params.Folder, folders); err != nil {
+			log.Printf(tr("Failed to create empty folders: %v"), err)
+		} else if n > 0 {
+			log.Printf(tr("Created %d empty folders"), n)
+		}
+	}
+	if params.FolderTimes {
+		if err := client.SetFolderTimes(params.Folder, folders); err != nil {
+			log.Printf(tr("Failed to set folder times: %v"), err)
+		}
+	}
+}
+
+// changedSince drops files not modified since the last run that downloaded
+// everything, keeping all of them if there was none.
+func changedSince(store stateStore, params *Args, files []yadloader.DiskFile) ([]yadloader.DiskFile, error) {
+	last, err := store.LastRun()
+	if err != nil {
+		return nil, err
+	}
+	if link, path := store.Source(); last.IsZero() || link != params.source() || path != params.Path {
+		log.Printf(tr("No earlier complete run in %s, downloading everything"), store.Location())
+		return files, nil
+	}
+	kept := files[:0]
+	for _, f := range files {
+		if f.Modified.After(last) {
+			kept = append(kept, f)
+		}
+	}
+	log.Printf(tr("%d of %d files changed since the last run at %s"), len(kept), len(files), last.Local().Format(time.RFC3339))
+	return kept, nil
+}
+
+// withPreviews drops files the API has no preview for.
+func withPreviews(files []yadloader.DiskFile) []yadloader.DiskFile {
+	kept := files[:0]
+	for _, f := range files {
+		if f.Preview != "" {
+			kept = append(kept, f)
+		}
+	}
+	if skipped := len(files) - len(kept); skipped > 0 {
+		log.Printf(tr("Skipping %d files without a preview"), skipped)
+	}
+	return kept
+}
+
 func main() {
-	ctx := context.Background()
 	params := parseFlags()
-	cfg := yadloader.NewDefaultConfig()
-	cfg.Wait = 0
-	cfg.Timeout = 0
-	client := yadloader.NewYaDiskClient(cfg)
-	files, err := client.GetTree(ctx, params.Link, params.Path, func(count int64, totalSize int64) {
-		log.Printf("Files: %d, Size: %d", count, totalSize)
-	})
-	if err != nil {
-		panic(err)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		// A second signal kills the process immediately.
+		stop()
+	}()
+
+	text := &textReporter{out: os.Stdout, interval: params.ProgressEvery}
+	var events reporter = text
+	switch {
+	case params.JSON:
+		events = newJSONReporter(os.Stdout)
+	case params.TUI:
+		events = newTUIReporter(os.Stdout, text)
+	}
+	if params.StatusAddr != "" {
+		status := newStatusReporter(events)
+		if err := serveStatus(params.StatusAddr, status); err != nil {
+			fmt.Fprintf(os.Stderr, tr("Error: --status-addr: %v\n"), err)
+			os.Exit(1)
+		}
+		log.Printf(tr("Serving progress on %s"), params.StatusAddr)
+		events = status
 	}
 
-	if params.Folder == "" {
+	defaults := yadloader.NewDefaultConfig()
+	clientOpts := []yadloader.Option{
+		yadloader.WithRetries(defaults.MaxTries, 0),
+		yadloader.WithConcurrency(params.Concurrency),
+		yadloader.WithListConcurrency(params.ListConcurrency),
+		yadloader.WithRateLimit(params.RateLimit, defaults.RateBurst),
+		yadloader.WithCircuitBreaker(params.BreakerFailures, params.BreakerCooldown),
+		yadloader.WithRetryBudget(params.RetryBudget, params.RetryTime),
+		yadloader.WithHTTPTimeout(params.HTTPTimeout),
+		yadloader.WithPerFileTimeout(params.FileTimeout),
+		yadloader.WithOperationTimeout(params.OpTimeout),
+		// Enough idle connections for every worker to keep its own.
+		yadloader.WithTransportOptions(yadloader.TransportOptions{MaxIdleConnsPerHost: params.Concurrency + params.ListConcurrency}),
+		yadloader.WithPreviewSize(params.PreviewSize, false),
+		yadloader.WithToken(params.Token),
+	}
+	if refresh := keyringRefresh(params); refresh != nil {
+		clientOpts = append(clientOpts, refresh)
+	}
+	if params.CACert != "" {
+		pool, err := loadCACert(params.CACert)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, tr("Error: --ca-cert: %v\n"), err)
+			os.Exit(1)
+		}
+		clientOpts = append(clientOpts, yadloader.WithRootCAs(pool))
+	}
+	if params.Insecure {
+		log.Print(tr("Warning: TLS certificate verification is disabled"))
+		clientOpts = append(clientOpts, yadloader.WithInsecureSkipVerify())
+	}
+	var deadline time.Time
+	if params.MaxDuration > 0 {
+		deadline = time.Now().Add(params.MaxDuration)
+		clientOpts = append(clientOpts, yadloader.WithDeadline(deadline))
+	}
+	pastDeadline := func() bool { return !deadline.IsZero() && !time.Now().Before(deadline) }
+	if params.DebugHTTP {
+		clientOpts = append(clientOpts, yadloader.WithLogger(log.Default()), yadloader.WithDebug(params.DebugHTTPBody))
+	}
+	filter := yadloader.FilterOptions{
+		MinSize:           int64(params.MinSize),
+		MaxSize:           int64(params.MaxSize),
+		NewerThan:         params.NewerThan.Time,
+		OlderThan:         params.OlderThan.Time,
+		MediaTypes:        params.MediaTypes,
+		Extensions:        params.Extensions,
+		BlockedExtensions: params.BlockedExts,
+	}
+	client := yadloader.NewYaDiskClient(append(clientOpts, yadloader.WithFilter(filter))...)
+
+	switch params.Command {
+	case "watch":
+		os.Exit(watch(ctx, client, params, events, deadline))
+	case "serve":
+		os.Exit(serve(ctx, client, params))
+	case "stat":
+		os.Exit(stat(ctx, client, params))
+	case "duplicates":
+		os.Exit(duplicates(ctx, client, params))
+	case "diff":
+		os.Exit(diff(ctx, client, params))
+	case "diff-local":
+		os.Exit(diffLocal(ctx, client, params))
+	case "upload":
+		os.Exit(upload(ctx, client, params, flag.Arg(0), flag.Arg(1)))
+	case "copy", "move":
+		os.Exit(copyOrMove(ctx, client, params, params.Command, flag.Arg(0), flag.Arg(1)))
+	case "delete":
+		os.Exit(remove(ctx, client, params, flag.Args()))
+	case "trash":
+		os.Exit(trash(ctx, client, params, flag.Arg(0), flag.Args()[1:]))
+	case "quota":
+		os.Exit(quota(ctx, client, params))
+	case "save":
+		saved, code := save(ctx, client, params)
+		if code != exitOK || params.Folder == "" {
+			os.Exit(code)
+		}
+		// Download the copy from the own disk.
+		params.Link, params.Path, params.Paths = "", saved, repeatValue{saved}
+	}
+
+	r := &runner{client: client, filter: filter, events: events, pastDeadline: pastDeadline}
+	if params.LinksFile != "" {
+		os.Exit(r.batch(ctx, params))
+	}
+	os.Exit(r.run(ctx, params))
+}
+
+// runner downloads a share as main does without a subcommand.
+type runner struct {
+	client       *yadloader.YaDiskClient
+	filter       yadloader.FilterOptions
+	events       reporter
+	pastDeadline func() bool
+}
+
+// run downloads the share of params, while listing it with --pipeline.
+func (r *runner) run(ctx context.Context, params *Args) int {
+	if params.Pipeline {
+		return runPipeline(ctx, r.client, params, r.events, r.pastDeadline)
+	}
+	return r.download(ctx, params)
+}
+
+// download lists or resumes the share of params and downloads it into
+// --output, returning the exit code.
+func (r *runner) download(ctx context.Context, params *Args) int {
+	client, events := r.client, r.events
+
+	var store stateStore
+	if params.Folder != "" {
+		if err := makeFolder(params.Folder, 0755); err != nil {
+			return setupError(err)
+		}
+		unlock, err := lockOutput(ctx, params)
+		if err != nil {
+			if ctx.Err() != nil {
+				return exitInterrupted
+			}
+			log.Printf(tr("Error: %v"), err)
+			return errorClass(err, exitError)
+		}
+		defer unlock()
+		if store, err = openStore(params); err != nil {
+			return setupError(err)
+		}
+		defer store.Close()
+	}
+
+	var (
+		files   []yadloader.DiskFile
+		folders []yadloader.DiskFile
+		err     error
+	)
+	retrying := params.RetryFrom != ""
+	resumed := !retrying && params.Resume && resumable(store, params)
+	// Only runs that listed the share themselves can tell what has changed
+	// since they started.
+	listed := !retrying && !resumed
+	started := time.Now()
+	if retrying {
+		retry, err := loadRetryFile(params.RetryFrom)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, tr("Error: --retry-from: %v\n"), err)
+			return 1
+		}
+		if retry.Link == "" && params.Token == "" {
+			fmt.Fprintln(os.Stderr, tr("Error: files of your own disk need --token"))
+			return 1
+		}
+		params.Link, params.Path = retry.Link, retry.Path
+		files = retry.Files
+		log.Printf(tr("Retrying %d files from %s"), len(files), params.RetryFrom)
+	} else if resumed {
+		if files, err = store.Files(); err != nil {
+			return setupError(err)
+		}
+	} else {
+		var single bool
+		if files, single, err = singleFile(ctx, client, params); err == nil && !single {
+			treeOpts := treeOptions(params, events.Listing)
+			if params.EmptyFolders || params.FolderTimes {
+				treeOpts = append(treeOpts, yadloader.WithFolders(func(f yadloader.DiskFile) {
+					folders = append(folders, f)
+				}))
+			}
+			if params.CacheTTL > 0 {
+				// The cache holds unfiltered listings, so other filters can
+				// reuse them.
+				files, err = cachedTree(ctx, client, params, treeOpts, r.filter)
+			} else {
+				files, err = listPaths(ctx, client, params, treeOpts)
+			}
+		}
+		if err != nil {
+			notify(params, newNotification(params, runStatus(ctx, err), yadloader.Report{}, err))
+			if ctx.Err() != nil {
+				log.Print(tr("Interrupted while listing"))
+				return exitInterrupted
+			}
+			if r.pastDeadline() {
+				log.Print(tr("Error: --max-duration reached while listing"))
+				return exitGaveUp
+			}
+			log.Printf(tr("Error: %v"), err)
+			return errorClass(err, exitError)
+		}
+		if params.PreviewSize != "" {
+			files = withPreviews(files)
+		}
+	}
+	if listed && params.Since.Last {
+		if files, err = changedSince(store, params, files); err != nil {
+			return setupError(err)
+		}
+	}
+	if listed && params.Pick {
+		if files, err = pick(files); errors.Is(err, errPickCancelled) {
+			log.Print(tr("Nothing picked"))
+			return 0
+		} else if err != nil {
+			fmt.Fprintf(os.Stderr, tr("Error: %v\n"), err)
+			return 1
+		}
+	}
+
+	if params.JSON || params.Folder == "" {
 		for _, file := range files {
-			fmt.Println(file.Path, file.File)
+			events.Discovered(file)
 		}
-		os.Exit(0)
 	}
 
-	output := params.Folder
-	if err := makeFolder(output, 0755); err != nil {
-		panic(err)
+	if params.Folder == "" {
+		return 0
 	}
 
+	output := params.Folder
+
 	var totalSize int64
 	for _, file := range files {
 		totalSize += file.Size
 	}
 
-	fmt.Printf("Total files %d, total size %d", len(files), totalSize)
+	events.Planned(len(files), totalSize)
 
-	for _, file := range files {
+	var pending []yadloader.DiskFile
+	if retrying {
+		// A retry keeps the state and part files of the run it came from.
+		if link, _ := store.Source(); link == "" {
+			if err := store.Init(params.source(), params.Path, files); err != nil {
+				return setupError(err)
+			}
+		}
+		pending = files
+	} else {
+		if !resumed {
+			if err := store.Init(params.source(), params.Path, files); err != nil {
+				return setupError(err)
+			}
+			// Part files of an unrelated earlier run must not be continued.
+			if n, err := yadloader.RemovePartFiles(output); err != nil {
+				return setupError(err)
+			} else if n > 0 {
+				log.Printf(tr("Removed %d stale %s files"), n, yadloader.PartSuffix)
+			}
+		}
+		if pending, err = store.Pending(); err != nil {
+			return setupError(err)
+		}
+	}
 
-		finalPath := strings.TrimSuffix(file.Path, file.Name)
-		finalFolder := filepath.Join(output, finalPath)
-		if err := makeFolder(finalFolder, 0755); err != nil {
-			panic(err)
+	// Local names of files saved under another name by --if-exists rename.
+	// Files of --cas-objects are stored as objects and shown by path.
+	var renamed map[string]string
+	if params.CASObjects == "" {
+		renamed = make(map[string]string)
+	}
+
+	var pendingSize int64
+	for _, f := range pending {
+		pendingSize += f.Size
+	}
+	progress := trackProgress(store, events, output, pendingSize, renamed)
+
+	// A run that leaves files out has not downloaded everything, so it is
+	// no last run for --since last.
+	var capped bool
+	if params.limited() {
+		kept, err := capFiles(params, pending)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, tr("Error: %v\n"), err)
+			return 1
+		}
+		if skipped := len(pending) - len(kept); skipped > 0 {
+			log.Printf(tr("Skipping the last %d files, over --max-files or --max-total-size"), skipped)
+			capped = true
 		}
+		pending = kept
+	}
 
-		finalPath = filepath.Join(finalFolder, file.Name)
+	opts := append(downloadOptions(params, yadloader.Hooks{}), yadloader.WithProgress(progress))
+	if params.Flatten || params.ByDate != "" || params.NameTemplate != "" {
+		// Name files from the whole listing, so resumed and retried runs
+		// give them the same names.
+		all, err := store.Files()
+		if err != nil {
+			return setupError(err)
+		}
+		opts = append(opts, yadloader.WithLayout(listingLayout(params, all)))
+	}
 
-		var download = func(path string) error {
-			f, err := os.Create(finalPath)
-			if err != nil {
-				panic(err)
-			}
-			defer f.Close()
-			if err := client.DownloadFile(ctx, file, f); err != nil {
-				panic(err)
+	// DownloadFiles checks the object folder of --cas-objects itself.
+	if err := client.CheckFreeSpace(output, pending, opts...); err != nil && params.CASObjects == "" {
+		if !params.Force || !errors.Is(err, yadloader.ErrInsufficientSpace) {
+			fmt.Fprintf(os.Stderr, tr("Error: %v\n"), err)
+			if errors.Is(err, yadloader.ErrInsufficientSpace) {
+				fmt.Fprintln(os.Stderr, tr("Use --force to start anyway"))
 			}
-			return nil
+			return errorClass(err, exitError)
+		}
+		log.Printf(tr("Warning: %v"), err)
+	}
+
+	for _, f := range pending {
+		warnInfected(params, f)
+	}
+
+	report, err := client.DownloadFiles(ctx, pending, output, opts...)
+	warnMismatches(report)
+	events.Summary(summary{Files: len(files), TotalSize: totalSize, Report: report})
+	if params.ReportFile != "" {
+		if rerr := writeReport(params.ReportFile, report); rerr != nil {
+			log.Printf(tr("Failed to write report: %v"), rerr)
+		}
+	}
+
+	if serr := store.Flush(); serr != nil {
+		log.Printf(tr("Failed to save state: %v"), serr)
+	}
+
+	if params.Checksums != "" && ctx.Err() == nil {
+		if err := writeChecksums(store, params, renamed); err != nil {
+			log.Printf(tr("Failed to write checksums: %v"), err)
 		}
+	}
+
+	// After the checksum files, which change the times of their folders.
+	if len(folders) > 0 && ctx.Err() == nil {
+		finishFolders(client, params, folders)
+	}
 
-		if err := download(finalPath); err != nil {
-			panic(err)
+	// An interrupted run has not tried every file, so its retry file would
+	// be incomplete.
+	if ctx.Err() == nil {
+		saveRetryFile(params, report)
+	}
+
+	if listed && !capped && err == nil && ctx.Err() == nil {
+		if err := store.SetLastRun(started); err != nil {
+			log.Printf(tr("Failed to save state: %v"), err)
 		}
+	}
 
+	notify(params, newNotification(params, runStatus(ctx, err), report, err))
+
+	if ctx.Err() != nil {
+		log.Printf(tr("Interrupted, progress saved to %s"), store.Location())
+		return exitInterrupted
+	}
+	if err != nil && r.pastDeadline() {
+		log.Printf(tr("Stopped by --max-duration, progress saved to %s"), store.Location())
+		return exitGaveUp
+	}
+	if err != nil && len(report.Failures) == 0 {
+		log.Printf(tr("Error: %v"), err)
 	}
+	return exitCode(err, report)
 }
@@ -2,50 +2,323 @@ package yadloader
 
 import (
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"mime"
+	"net/http"
 	"net/url"
+	"os"
+	"slices"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	"filippo.io/age"
 	"github.com/hashicorp/go-retryablehttp"
 )
 
+const (
+	MaxConcurrency     = 32
+	MaxListConcurrency = 8
+)
+
+// DefaultBaseURL is the Yandex Disk REST API.
+const DefaultBaseURL = "https://cloud-api.yandex.net"
+
+var ErrTokenRequired = errors.New("yadloader: OAuth token required")
+
 type Config struct {
-	Limit     int
-	Timeout   time.Duration
-	Wait      time.Duration
-	MaxTries  int
-	ChunkSize int
+	Limit int
+	// Timeout is the pause between listing pages used when PageDelay is
+	// zero.
+	//
+	// Deprecated: use PageDelay, or HTTPTimeout to bound requests.
+	Timeout time.Duration
+	// PageDelay is an extra pause between listing pages; RateLimit is what
+	// normally paces the API.
+	PageDelay time.Duration
+	// HTTPTimeout bounds every API call, retries included, and the wait
+	// for a download to start sending. Zero means no limit.
+	HTTPTimeout time.Duration
+	Wait        time.Duration
+	MaxTries    int
+	ChunkSize   int
+	// Concurrency is the number of parallel download workers.
+	Concurrency int
+	// ListConcurrency is the number of folders listed in parallel during traversal.
+	ListConcurrency int
+	Filter          FilterOptions
+	// PreviewSize switches the client to previews: listings request preview
+	// links of this size (S, M, L, XL, XXL, XXXL or WIDTHxHEIGHT) and
+	// DownloadFile fetches the preview instead of the original.
+	PreviewSize string
+	PreviewCrop bool
+	// Token is an OAuth token; it is required to access the user's own disk.
+	Token string
+	// RefreshToken, with the OAuthApp it was granted to, lets the client
+	// get a new Token when it expires at TokenExpiry or the API rejects it,
+	// instead of failing the rest of a long run. TokenRefreshed receives
+	// every new token, e.g. to store it; it is never called concurrently.
+	RefreshToken   string
+	TokenExpiry    time.Time
+	OAuthApp       *OAuthApp
+	TokenRefreshed func(OAuthToken)
+	// Progress receives download events. It is never called concurrently.
+	Progress ProgressFunc
+	// ProgressInterval, when set, makes DownloadFiles send EventProgress
+	// with transfer rates this often.
+	ProgressInterval time.Duration
+	// HTTPClient replaces the client requests are sent with; retries are
+	// still handled by the library.
+	HTTPClient *http.Client
+	// BaseURL is where the API is reached, DefaultBaseURL unless pointed
+	// at a test server such as yadloadertest.
+	BaseURL string
+	// Transport replaces the round tripper of HTTPClient, or of the default
+	// pooled client, for proxies, TLS settings or recorded responses.
+	Transport http.RoundTripper
+	// TransportOptions tune connection pooling, keep-alives and TLS
+	// handshakes.
+	TransportOptions TransportOptions
+	// TLSConfig is used for API and download connections, for example to
+	// trust the CA of a TLS-intercepting proxy.
+	TLSConfig *tls.Config
+	Logger    Logger
+	// IgnoreFreeSpace lets DownloadFiles start even if the destination
+	// looks too small; the shortage is logged instead.
+	IgnoreFreeSpace bool
+	Overwrite       OverwritePolicy
+	ErrorPolicy     ErrorPolicy
+	// RetryPasses is how many times files that failed with a retryable
+	// error are attempted again once a Collect run has been through all
+	// files.
+	RetryPasses int
+	Hooks       Hooks
+	// RateLimit caps API calls per second, with bursts of RateBurst. The
+	// rate is halved whenever the API answers 429 and recovers as calls
+	// succeed. Zero disables the limit. Downloads are not limited.
+	RateLimit float64
+	RateBurst int
+	// Debug logs every HTTP request with status and timing to Logger, and
+	// the first DebugBody bytes of API responses (all when negative).
+	Debug     bool
+	DebugBody int
+	// PerFileTimeout bounds the download of a single file, reconnects
+	// included; a file taking longer fails with ErrFileTimeout.
+	PerFileTimeout time.Duration
+	// OperationTimeout bounds the wait for an operation the API runs in
+	// the background, such as copying a folder; zero means no limit.
+	OperationTimeout time.Duration
+	// Deadline, when set, stops listing and downloading at that time.
+	Deadline time.Time
+	// Pipeline makes DownloadTree start downloading files as soon as they
+	// are listed instead of after the whole tree.
+	Pipeline bool
+	// CheckRetry and Backoff replace the retry policy and wait between
+	// attempts of the HTTP client; IsDownload tells file downloads from API
+	// calls. The policy runs after the rate limiter has seen the response.
+	CheckRetry retryablehttp.CheckRetry
+	Backoff    retryablehttp.Backoff
+	// BreakerThreshold, when set, pauses all requests of the client for
+	// BreakerCooldown once that many in a row have failed with server or
+	// network errors, instead of spending retries on every queued file.
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+	// RetryBudget and RetryBudgetTime, when set, cap the retries of the
+	// client and the time it waits before them, over all API calls,
+	// download reconnects and retry passes. Once either is used up what
+	// fails is not retried but fails with ErrRetryBudget, which stops a
+	// download even with Collect.
+	RetryBudget     int
+	RetryBudgetTime time.Duration
+	// Layout decides where files go inside the download folder, see
+	// FlatLayout.
+	Layout Layout
+	// FileMode and DirMode are the permissions of downloaded files and the
+	// folders created for them, before the umask; zero means 0644 and 0755.
+	FileMode os.FileMode
+	DirMode  os.FileMode
+	// ServedNames saves files under the name their download is sent with
+	// in the Content-Disposition header when it differs from the listed
+	// one, in the same folder. Report.ServedNames lists such files either
+	// way.
+	ServedNames bool
+	// Digests hashes every downloaded file with MD5, SHA256 and CRC32 in
+	// one pass, failing originals that do not match the checksums of the
+	// listing with ErrChecksumMismatch. The digests are in EventFinished
+	// and Report.Digests.
+	Digests bool
+	// Antivirus decides what happens to files the Yandex.Disk antivirus
+	// reports as infected.
+	Antivirus AntivirusPolicy
+	// Preallocate reserves the space of files of a megabyte and more on
+	// disk before they are downloaded, against fragmentation, on Linux and
+	// macOS. Part files keep the size of the data received, so they are
+	// still continued where they stopped.
+	Preallocate bool
+	// SyncWrites flushes every downloaded file to disk before it is renamed
+	// into place, and its folder after, so a file reported as downloaded
+	// survives a power failure. It slows down downloads of many small
+	// files.
+	SyncWrites bool
+	// Compression writes every downloaded file through a compressor, adding
+	// the extension of Compression.Ext to its name. Compressed part files
+	// are downloaded again rather than continued. With Digests, the digests
+	// are those of the original.
+	Compression Compression
+	// Recipients encrypts every downloaded file to these age recipients
+	// as it is written, adding EncryptedExt to its name; decrypt with "age
+	// -d". Encrypted part files are downloaded again rather than continued.
+	Recipients []age.Recipient
+	// CASObjects, when set, makes DownloadFiles store the body of every
+	// file once in this folder as ab/cdef… by its SHA256, and lay out the
+	// download folder as CASTree says, so snapshots of a share into
+	// different folders share the files they have in common. Objects
+	// already stored are not downloaded again, files listed without a
+	// SHA256 are kept under unhashed/ by path, and Layout and Pipeline do
+	// not apply.
+	CASObjects string
+	CASTree    CASTree
+	// SniffTypes looks at the first bytes of every downloaded original and
+	// lists those that do not look like their media type or extension in
+	// Report.TypeMismatches, such as an executable named like a photo.
+	SniffTypes bool
+	// EmptyFolders makes DownloadTree create the folders of the share no
+	// file was downloaded into, see MakeFolders.
+	EmptyFolders bool
+	// FolderTimes makes DownloadTree give the local folders the
+	// modification times of the share, see SetFolderTimes.
+	FolderTimes bool
+	// Xattrs stores the MD5, SHA256 and resource ID of every downloaded
+	// original in extended attributes, see ReadFileAttrs. Files on systems
+	// or filesystems without them are downloaded as usual.
+	Xattrs bool
+}
+
+// Logger is satisfied by *log.Logger.
+type Logger interface {
+	Printf(format string, v ...any)
 }
 
 func NewDefaultConfig() *Config {
 	return &Config{
-		Limit:     100,
-		Timeout:   10 * time.Second,
-		Wait:      5 * time.Second,
-		MaxTries:  3,
-		ChunkSize: 1024 * 1024, // 1MB
+		Limit:           100,
+		HTTPTimeout:     time.Minute,
+		RateLimit:       5,
+		RateBurst:       5,
+		Wait:            5 * time.Second,
+		MaxTries:        3,
+		ChunkSize:       1024 * 1024, // 1MB
+		Concurrency:     4,
+		ListConcurrency: 2,
+		BaseURL:         DefaultBaseURL,
 	}
 }
 
+func clamp(v, max int) int {
+	if v < 1 {
+		return 1
+	}
+	if v > max {
+		return max
+	}
+	return v
+}
+
 type GetTreeCallback func(count int64, totalSize int64)
 
 type YaDiskClient struct {
-	client *retryablehttp.Client
-	config *Config
+	client  *retryablehttp.Client
+	config  *Config
+	limiter *rateLimiter
+	breaker *breaker
+	tokens  *tokens
+	budget  *retryBudget
 }
 
-func NewYaDiskClient(config *Config) *YaDiskClient {
+// NewYaDiskClient creates a client from NewDefaultConfig adjusted by opts.
+func NewYaDiskClient(opts ...Option) *YaDiskClient {
+	config := NewDefaultConfig()
+	for _, opt := range opts {
+		opt(config)
+	}
+
 	retryClient := retryablehttp.NewClient()
 	retryClient.RetryWaitMin = config.Wait
 	retryClient.RetryMax = config.MaxTries
 	retryClient.Logger = nil
+	// Keep the last response when retries run out, so its API error can be
+	// reported.
+	retryClient.ErrorHandler = retryablehttp.PassthroughErrorHandler
+	if config.HTTPClient != nil {
+		retryClient.HTTPClient = config.HTTPClient
+	}
+	if config.Transport != nil {
+		// Copied so a caller's HTTPClient is left untouched.
+		hc := *retryClient.HTTPClient
+		hc.Transport = config.Transport
+		retryClient.HTTPClient = &hc
+	}
+	if config.Logger != nil {
+		retryClient.Logger = config.Logger
+	}
 
-	return &YaDiskClient{
-		client: retryClient,
-		config: config,
+	c := &YaDiskClient{
+		client:  retryClient,
+		config:  config,
+		limiter: newRateLimiter(config.RateLimit, config.RateBurst),
+		tokens:  newTokens(config),
+		budget:  newRetryBudget(config.RetryBudget, config.RetryBudgetTime),
+	}
+	c.breaker = newBreaker(config.BreakerThreshold, config.BreakerCooldown, c.logf)
+	retryClient.HTTPClient = c.tuneTransport(retryClient.HTTPClient)
+	if config.Debug {
+		hc := *retryClient.HTTPClient
+		next := hc.Transport
+		if next == nil {
+			next = http.DefaultTransport
+		}
+		hc.Transport = &debugTransport{next: next, logf: c.logf, api: c.apiURL(), limit: config.DebugBody}
+		retryClient.HTTPClient = &hc
+	}
+	checkRetry := retryablehttp.DefaultRetryPolicy
+	if config.CheckRetry != nil {
+		checkRetry = config.CheckRetry
+	}
+	retryClient.CheckRetry = func(ctx context.Context, resp *http.Response, err error) (bool, error) {
+		if resp != nil && strings.HasPrefix(resp.Request.URL.String(), c.apiURL()) {
+			limiterFrom(ctx, c.limiter).observe(resp.StatusCode)
+		}
+		retry, checkErr := checkRetry(ctx, resp, err)
+		if !retry || checkErr != nil {
+			return retry, checkErr
+		}
+		if berr := c.budget.take(1); berr != nil {
+			if err == nil {
+				err = checkResponse(resp)
+				resp.Body.Close()
+			}
+			return false, fmt.Errorf("%w: %w", berr, err)
+		}
+		return true, nil
+	}
+	if config.Backoff != nil {
+		retryClient.Backoff = config.Backoff
+	}
+	backoff := retryClient.Backoff
+	retryClient.Backoff = func(min, max time.Duration, attempt int, resp *http.Response) time.Duration {
+		return c.budget.pause(backoff(min, max, attempt, resp))
+	}
+	return c
+}
+
+func (c *YaDiskClient) logf(format string, v ...any) {
+	if c.config.Logger != nil {
+		c.config.Logger.Printf(format, v...)
 	}
 }
 
@@ -57,134 +330,676 @@ func (c *YaDiskClient) makeParams(a map[string]string) string {
 	return params.Encode()
 }
 
+// newRequest builds a request, authorizing it when it goes to the API.
+// Download links are pre-signed and never get the token.
+func (c *YaDiskClient) newRequest(ctx context.Context, method, url string) (*retryablehttp.Request, error) {
+	req, err := retryablehttp.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.config.Token != "" && strings.HasPrefix(url, c.apiURL()) {
+		token, err := c.tokens.get(ctx)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Authorization", "OAuth "+token)
+	}
+	return req, nil
+}
+
+func (c *YaDiskClient) withDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.config.Deadline.IsZero() {
+		return ctx, func() {}
+	}
+	return context.WithDeadline(ctx, c.config.Deadline)
+}
+
+func (c *Config) pageDelay() time.Duration {
+	if c.PageDelay > 0 {
+		return c.PageDelay
+	}
+	return c.Timeout
+}
+
 func (c *YaDiskClient) request(ctx context.Context, url string) ([]byte, error) {
+	return c.call(ctx, "GET", url)
+}
 
-	req, err := retryablehttp.NewRequestWithContext(ctx, "GET", url, nil)
+// call sends an API request without a body and returns the JSON answer,
+// which may be empty for other methods than GET.
+func (c *YaDiskClient) call(ctx context.Context, method, url string) ([]byte, error) {
+	if c.config.HTTPTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.config.HTTPTimeout)
+		defer cancel()
+	}
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+	if err := c.breaker.wait(ctx); err != nil {
+		return nil, err
+	}
+	// Calls with their own rate limit adapt their own limiter.
+	ctx = context.WithValue(ctx, limiterKey{}, c.limiter)
+
+	resp, err := c.send(ctx, method, url)
+	if isUnauthorized(err) {
+		// The token may have expired early or been refreshed by another
+		// request; try once more with a fresh one.
+		stale := strings.TrimPrefix(resp.Request.Header.Get("Authorization"), "OAuth ")
+		refreshed, rerr := c.tokens.refresh(ctx, stale)
+		if rerr != nil {
+			return nil, fmt.Errorf("%w; refreshing the token: %w", err, rerr)
+		}
+		if refreshed {
+			resp, err = c.send(ctx, method, url)
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
+	defer resp.Body.Close()
 
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) == 0 && method != "GET" {
+		return nil, nil
+	}
+	// Proxies and captive portals answer with HTML pages.
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("yandex disk: %s %s: unexpected %s response (body: %q)", method, errorURL(resp.Request.URL), resp.Header.Get("Content-Type"), excerpt(body))
+	}
+	return body, nil
+}
+
+// send sends a request to url, returning the response only when it
+// succeeded; an API error comes with the response, its body consumed.
+func (c *YaDiskClient) send(ctx context.Context, method, url string) (*http.Response, error) {
+	req, err := c.newRequest(ctx, method, url)
+	if err != nil {
+		return nil, err
+	}
 	resp, err := c.client.Do(req)
 	if err != nil {
+		c.breaker.record(err)
 		return nil, err
 	}
+	err = checkResponse(resp)
+	c.breaker.record(err)
+	if err != nil {
+		resp.Body.Close()
+		return resp, err
+	}
+	return resp, nil
+}
 
-	defer resp.Body.Close()
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
 
-	body, err := io.ReadAll(resp.Body)
+// GetTree lists all files under path of the public resource link. An empty
+// link lists the user's own disk, see ListDisk.
+func (c *YaDiskClient) GetTree(ctx context.Context, link, path string, opts ...TreeOption) ([]DiskFile, error) {
+	ctx, stop := c.withDeadline(ctx)
+	defer stop()
+
+	link, path, err := resolveLink(link, path)
 	if err != nil {
 		return nil, err
 	}
-	return body, nil
+	if path == "" {
+		path = "/"
+	}
+	if link == "" && c.config.Token == "" {
+		return nil, ErrTokenRequired
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	w := &treeWalker{
+		client:  c,
+		link:    link,
+		options: newTreeOptions(opts),
+		files:   make([]DiskFile, 0, c.config.Limit),
+		cancel:  cancel,
+	}
+	if err := w.run(ctx, path); err != nil {
+		return nil, err
+	}
+	w.options.sortFiles(w.files)
+	return w.files, nil
 }
 
-func (c *YaDiskClient) GetTree(ctx context.Context, link, path string, cb ...GetTreeCallback) ([]diskFile, error) {
+// GetMeta returns the metadata of path inside the public resource link
+// itself, without walking its contents. An empty link refers to the user's
+// own disk.
+func (c *YaDiskClient) GetMeta(ctx context.Context, link, path string) (*Resource, error) {
+	ctx, stop := c.withDeadline(ctx)
+	defer stop()
+
+	link, path, err := resolveLink(link, path)
+	if err != nil {
+		return nil, err
+	}
 	if path == "" {
 		path = "/"
 	}
+	if link == "" && c.config.Token == "" {
+		return nil, ErrTokenRequired
+	}
 
-	var callback GetTreeCallback
-	if len(cb) > 0 {
-		callback = cb[0]
+	params := map[string]string{
+		"path":   path,
+		"limit":  "0",
+		"fields": metaFields,
+	}
+	if link != "" {
+		params["public_key"] = link
+	}
+	if c.config.PreviewSize != "" {
+		params["preview_size"] = c.config.PreviewSize
+		params["preview_crop"] = strconv.FormatBool(c.config.PreviewCrop)
+		params["fields"] = metaFields + ",preview"
 	}
 
-	files := make([]diskFile, 0, c.config.Limit)
-	var count int64
-	var totalSize int64
+	resp, err := c.request(ctx, fmt.Sprintf("%s?%s", c.resourcesURL(link), c.makeParams(params)))
+	if err != nil {
+		return nil, err
+	}
 
-	if err := c.getTree(ctx, link, path, &files, &count, &totalSize, callback); err != nil {
+	var r response
+	if err := json.Unmarshal(resp, &r); err != nil {
 		return nil, err
 	}
-	return files, nil
+	res := newResource(r)
+	return &res, nil
 }
 
-func (c *YaDiskClient) getTree(
-	ctx context.Context,
-	link, path string,
-	files *[]diskFile,
-	count *int64,
-	totalSize *int64,
-	cb GetTreeCallback,
-) error {
-	offset := 0
+// List returns the files and folders directly inside path of the public
+// resource link, without descending into subfolders. A path naming a file
+// lists just that file. Only the WithSort tree option applies; the Filter
+// is not.
+func (c *YaDiskClient) List(ctx context.Context, link, path string, opts ...TreeOption) ([]Resource, error) {
+	ctx, stop := c.withDeadline(ctx)
+	defer stop()
+
+	link, path, err := resolveLink(link, path)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		path = "/"
+	}
+	if link == "" && c.config.Token == "" {
+		return nil, ErrTokenRequired
+	}
+	options := newTreeOptions(opts)
+
+	var items []Resource
+	for offset := 0; ; offset += c.config.Limit {
+		params := map[string]string{
+			"path":   path,
+			"limit":  strconv.Itoa(c.config.Limit),
+			"offset": strconv.Itoa(offset),
+			"fields": listFields("resource_id", "public_url"),
+		}
+		if link != "" {
+			params["public_key"] = link
+		}
+		if sort := options.sortParam(); sort != "" {
+			params["sort"] = sort
+		}
 
-	notify := func() {
-		if cb != nil {
-			cb(*count, *totalSize)
+		resp, err := c.request(ctx, fmt.Sprintf("%s?%s", c.resourcesURL(link), c.makeParams(params)))
+		if err != nil {
+			return nil, err
+		}
+		var r response
+		if err := json.Unmarshal(resp, &r); err != nil {
+			return nil, err
+		}
+		if r.Type == FILE {
+			r.PublicKey = link
+			return []Resource{newResource(r)}, nil
+		}
+		if r.Embedded == nil || len(r.Embedded.Items) == 0 {
+			break
+		}
+		for _, i := range r.Embedded.Items {
+			if i.PublicKey == "" {
+				i.PublicKey = link
+			}
+			items = append(items, newResource(i))
+		}
+		if len(items) >= r.Embedded.Total {
+			break
 		}
 	}
+	return items, nil
+}
+
+// ListDisk lists all files under path of the disk the Token belongs to.
+func (c *YaDiskClient) ListDisk(ctx context.Context, path string, opts ...TreeOption) ([]DiskFile, error) {
+	return c.GetTree(ctx, "", path, opts...)
+}
+
+func (c *YaDiskClient) apiURL() string {
+	base := c.config.BaseURL
+	if base == "" {
+		base = DefaultBaseURL
+	}
+	return strings.TrimSuffix(base, "/") + "/v1/disk"
+}
+
+// resourcesURL returns the resources endpoint for a public link, or for the
+// user's disk when link is empty.
+func (c *YaDiskClient) resourcesURL(link string) string {
+	if link == "" {
+		return c.apiURL() + "/resources"
+	}
+	return c.apiURL() + "/public/resources"
+}
+
+// folder is a folder waiting to be listed, depth levels below the root.
+type folder struct {
+	path  string
+	depth int
+}
+
+// treeWalker lists folders of a public resource or a disk with up to
+// ListConcurrency workers. Folders found but not listed yet wait on a stack,
+// so the tree is walked depth first and nesting never grows the Go stack;
+// only their paths are kept until a worker takes them.
+type treeWalker struct {
+	client  *YaDiskClient
+	link    string
+	options treeOptions
+	cancel  context.CancelFunc
+
+	mu        sync.Mutex
+	wake      *sync.Cond
+	stack     []folder
+	busy      int
+	files     []DiskFile
+	count     int64
+	totalSize int64
+	err       error
+}
+
+func (w *treeWalker) add(file DiskFile) {
+	if !w.options.unfiltered && !w.client.config.Filter.Match(file) {
+		return
+	}
+
+	w.mu.Lock()
+	w.files = append(w.files, file)
+	w.count++
+	w.totalSize += file.Size
+
+	if w.options.callback != nil {
+		w.options.callback(w.count, w.totalSize)
+	}
+	w.mu.Unlock()
+
+	if w.options.emit != nil {
+		w.options.emit(file)
+	}
+}
+
+func (w *treeWalker) addFolder(dir DiskFile) {
+	if w.options.folders == nil {
+		return
+	}
+	dir.PublicKey = w.link
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.options.folders(dir)
+}
+
+// run lists root and everything below it, returning the first error.
+func (w *treeWalker) run(ctx context.Context, root string) error {
+	w.wake = sync.NewCond(&w.mu)
+	w.stack = []folder{{root, 1}}
+	// Idle workers wait on wake and must see cancellation too.
+	stop := context.AfterFunc(ctx, func() {
+		w.mu.Lock()
+		w.wake.Broadcast()
+		w.mu.Unlock()
+	})
+	defer stop()
+
+	var wg sync.WaitGroup
+	for range clamp(w.client.config.ListConcurrency, MaxListConcurrency) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.work(ctx)
+		}()
+	}
+	wg.Wait()
+
+	if w.err != nil {
+		return w.err
+	}
+	return ctx.Err()
+}
 
+// work takes folders off the stack until none are left and no other worker
+// can push more, or the walk fails.
+func (w *treeWalker) work(ctx context.Context) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
 	for {
-		args := c.makeParams(map[string]string{
-			"path":       path,
-			"limit":      strconv.Itoa(c.config.Limit),
-			"offset":     strconv.Itoa(offset),
-			"public_key": link,
-		})
-
-		resp, err := c.request(ctx, fmt.Sprintf("https://cloud-api.yandex.net/v1/disk/public/resources?%s", args))
+		for len(w.stack) == 0 && w.busy > 0 && w.err == nil && ctx.Err() == nil {
+			w.wake.Wait()
+		}
+		if len(w.stack) == 0 || w.err != nil || ctx.Err() != nil {
+			w.wake.Broadcast()
+			return
+		}
+		dir := w.stack[len(w.stack)-1]
+		w.stack = w.stack[:len(w.stack)-1]
+		w.busy++
+		w.mu.Unlock()
+
+		subdirs, err := w.list(ctx, dir)
+
+		w.mu.Lock()
+		w.busy--
+		if err != nil && w.err == nil {
+			w.err = err
+			w.cancel()
+		}
+		// Pushed in reverse so they are taken in listing order.
+		slices.Reverse(subdirs)
+		w.stack = append(w.stack, subdirs...)
+		w.wake.Broadcast()
+	}
+}
+
+// list adds the files of dir and returns its subfolders to descend into.
+func (w *treeWalker) list(ctx context.Context, dir folder) ([]folder, error) {
+	c := w.client
+	var subdirs []folder
+	for offset := 0; ; offset += c.config.Limit {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		r, err := c.listPage(ctx, w.link, dir.path, offset, w.options)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		var r response
-		if err = json.Unmarshal(resp, &r); err != nil {
-			return err
+		// A single-file public link lists as the file itself.
+		if r.Type == FILE {
+			file := newResource(*r).DiskFile()
+			file.PublicKey = w.link
+			w.add(file)
+			return nil, nil
 		}
 
-		if r.Embedded == nil || r.Embedded.Items == nil || len(r.Embedded.Items) == 0 {
+		if r.Embedded == nil || len(r.Embedded.Items) == 0 {
 			break
 		}
 
 		for _, i := range r.Embedded.Items {
 			switch i.Type {
 			case FILE:
-				*files = append(*files, diskFile{
-					Name:     i.Name,
-					Size:     *i.Size,
-					File:     *i.File,
-					Path:     i.Path,
-					MD5:      *i.MD5,
-					SHA256:   *i.SHA256,
-					Created:  i.Created,
-					Modified: i.Modified,
-				})
-
-				*count++
-				*totalSize += int64(*i.Size)
-
-				notify()
+				file := newDiskFile(i)
+				file.PublicKey = w.link
+				w.add(file)
 
 			case DIR:
-				if err := c.getTree(ctx, link, i.Path, files, count, totalSize, cb); err != nil {
-					return err
+				if limit := w.options.maxDepth; limit == 0 || dir.depth < limit {
+					subdirs = append(subdirs, folder{diskPath(i.Path), dir.depth + 1})
+					w.addFolder(newDiskFile(i))
 				}
 			}
 		}
 
-		offset += c.config.Limit
-		time.Sleep(c.config.Timeout)
+		if offset+len(r.Embedded.Items) >= r.Embedded.Total {
+			break
+		}
+		if err := sleep(ctx, c.config.pageDelay()); err != nil {
+			return nil, err
+		}
 	}
+	return subdirs, nil
+}
 
-	return nil
+// listPage fetches one page of the folder listing of path.
+func (c *YaDiskClient) listPage(ctx context.Context, link, path string, offset int, options treeOptions) (*response, error) {
+	params := map[string]string{
+		"path":   path,
+		"limit":  strconv.Itoa(c.config.Limit),
+		"offset": strconv.Itoa(offset),
+		"fields": listFields(),
+	}
+	if link != "" {
+		params["public_key"] = link
+	}
+	if sort := options.sortParam(); sort != "" {
+		params["sort"] = sort
+	}
+	if c.config.PreviewSize != "" {
+		params["preview_size"] = c.config.PreviewSize
+		params["preview_crop"] = strconv.FormatBool(c.config.PreviewCrop)
+		params["fields"] = listFields("preview")
+	}
+
+	resp, err := c.request(ctx, fmt.Sprintf("%s?%s", c.resourcesURL(link), c.makeParams(params)))
+	if err != nil {
+		return nil, err
+	}
+	var r response
+	if err := json.Unmarshal(resp, &r); err != nil {
+		return nil, err
+	}
+	return &r, nil
 }
 
-func (c *YaDiskClient) DownloadFile(ctx context.Context, file diskFile, writer io.Writer) error {
-	req, err := retryablehttp.NewRequestWithContext(ctx, "GET", file.File, nil)
+// downloadLink asks the API for a fresh download link of file.
+func (c *YaDiskClient) downloadLink(ctx context.Context, file DiskFile) (string, error) {
+	params := map[string]string{"path": file.Path}
+	if file.PublicKey != "" {
+		params["public_key"] = file.PublicKey
+	}
+
+	resp, err := c.request(ctx, fmt.Sprintf("%s/download?%s", c.resourcesURL(file.PublicKey), c.makeParams(params)))
+	if err != nil {
+		return "", err
+	}
+
+	var l link
+	if err := json.Unmarshal(resp, &l); err != nil {
+		return "", err
+	}
+	if l.Href == "" {
+		return "", fmt.Errorf("no download link for %s", file.Path)
+	}
+	return l.Href, nil
+}
+
+// DownloadFile writes the contents of file to writer. When the connection
+// breaks mid-transfer, it reconnects with a Range request starting after the
+// bytes already written, up to MaxTries times. If the server ignores the
+// range, a seekable writer is rewound and truncated, otherwise the bytes
+// already written are skipped in the new response.
+func (c *YaDiskClient) DownloadFile(ctx context.Context, file DiskFile, writer io.Writer, opts ...Option) error {
+	_, _, err := c.with(opts).download(ctx, file, writer, 0)
+	return err
+}
+
+// download continues a transfer whose first offset bytes are already in
+// writer. It returns the number of bytes received and the file name of the
+// Content-Disposition header, if any.
+func (c *YaDiskClient) download(ctx context.Context, file DiskFile, writer io.Writer, offset int64) (int64, string, error) {
+	link := file.File
+	if c.config.PreviewSize != "" {
+		if file.Preview == "" {
+			return 0, "", fmt.Errorf("%s has no preview", file.Path)
+		}
+		link = file.Preview
+	}
+	if link == "" {
+		var err error
+		if link, err = c.downloadLink(ctx, file); err != nil {
+			return 0, "", fmt.Errorf("download link: %w", err)
+		}
+	}
+
+	w := &resumeWriter{w: writer, n: offset}
+	buffer := make([]byte, c.config.ChunkSize)
+	for attempt := 1; ; attempt++ {
+		if err := c.breaker.wait(ctx); err != nil {
+			return w.received, w.served, err
+		}
+		err := c.copyFrom(ctx, link, w, buffer)
+		if err == nil && c.config.PreviewSize == "" && w.n < file.Size {
+			err = &TruncatedDownloadError{Expected: file.Size, Received: w.n}
+		}
+		if w.err == nil {
+			c.breaker.record(err)
+		}
+		if err == nil {
+			return w.received, w.served, nil
+		}
+		// Local write failures, cancellation and permanent API errors are
+		// not worth a reconnect.
+		if ctx.Err() != nil || w.err != nil || !IsRetryable(err) || attempt >= c.config.MaxTries {
+			return w.received, w.served, err
+		}
+		if berr := c.budget.take(1); berr != nil {
+			return w.received, w.served, fmt.Errorf("%w: %w", berr, err)
+		}
+		c.logf("download of %s broke after %d bytes, resuming: %v", file.Path, w.n, err)
+		if err := sleep(ctx, c.budget.pause(c.config.Wait)); err != nil {
+			return w.received, w.served, err
+		}
+	}
+}
+
+type downloadKey struct{}
+
+// IsDownload reports whether ctx, as passed to Config.CheckRetry, is that of
+// a file download rather than an API call.
+func IsDownload(ctx context.Context) bool {
+	return ctx.Value(downloadKey{}) != nil
+}
+
+func (c *YaDiskClient) copyFrom(ctx context.Context, link string, w *resumeWriter, buffer []byte) error {
+	reqCtx, cancel := context.WithCancel(context.WithValue(ctx, downloadKey{}, true))
+	defer cancel()
+	req, err := c.newRequest(reqCtx, "GET", link)
 	if err != nil {
 		return err
 	}
+	if w.n > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", w.n))
+	}
 
+	// Only the wait for the response is bounded; the transfer itself may
+	// take as long as it needs.
+	var timer *time.Timer
+	if c.config.HTTPTimeout > 0 {
+		timer = time.AfterFunc(c.config.HTTPTimeout, cancel)
+	}
 	resp, err := c.client.Do(req)
+	if timer != nil && !timer.Stop() {
+		if err == nil {
+			resp.Body.Close()
+		}
+		return fmt.Errorf("no response within %s", c.config.HTTPTimeout)
+	}
 	if err != nil {
 		return err
 	}
-
 	defer resp.Body.Close()
-	buffer := make([]byte, c.config.ChunkSize)
-	_, err = io.CopyBuffer(writer, resp.Body, buffer)
+
+	if w.n > 0 && resp.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// The part file does not fit the remote file; start over.
+		if err := w.rewind(); err != nil {
+			return err
+		}
+		return errors.New("range not satisfiable, restarting download")
+	}
+	if err := checkResponse(resp); err != nil {
+		return err
+	}
+	if _, params, err := mime.ParseMediaType(resp.Header.Get("Content-Disposition")); err == nil && params["filename"] != "" {
+		w.served = params["filename"]
+	}
+
+	// total is the size of the whole file according to the response.
+	total := resp.ContentLength
+	if resp.StatusCode == http.StatusPartialContent && total >= 0 {
+		total += w.n
+	}
+	if w.n > 0 && resp.StatusCode != http.StatusPartialContent {
+		if err := w.rewind(); err != nil {
+			if _, err := io.CopyN(io.Discard, resp.Body, w.n); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err = io.CopyBuffer(w, resp.Body, buffer)
+	if total >= 0 && w.err == nil && (err == nil || errors.Is(err, io.ErrUnexpectedEOF)) && w.n < total {
+		return &TruncatedDownloadError{Expected: total, Received: w.n}
+	}
+	return err
+}
+
+// resumeWriter tracks the writer position and remembers write errors, so
+// DownloadFile knows where to resume and when not to.
+type resumeWriter struct {
+	w        io.Writer
+	n        int64
+	received int64
+	err      error
+	// served is the file name the download was sent under.
+	served string
+}
+
+func (w *resumeWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	w.received += int64(n)
 	if err != nil {
+		w.err = err
+	}
+	return n, err
+}
+
+var errNotRewindable = errors.New("writer cannot be rewound")
+
+func (w *resumeWriter) rewind() error {
+	s, ok := w.w.(interface {
+		io.Seeker
+		Truncate(size int64) error
+	})
+	if !ok {
+		return errNotRewindable
+	}
+	if _, err := s.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := s.Truncate(0); err != nil {
 		return err
 	}
+	w.n = 0
 	return nil
 }
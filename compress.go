@@ -0,0 +1,65 @@
+package yadloader
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression selects the compressor downloaded files are written through.
+type Compression int
+
+const (
+	CompressNone Compression = iota
+	CompressGzip
+	CompressZstd
+)
+
+var compressionNames = map[Compression]string{
+	CompressNone: "none",
+	CompressGzip: "gzip",
+	CompressZstd: "zstd",
+}
+
+func (c Compression) String() string {
+	if s, ok := compressionNames[c]; ok {
+		return s
+	}
+	return fmt.Sprintf("Compression(%d)", int(c))
+}
+
+func ParseCompression(s string) (Compression, error) {
+	for c, name := range compressionNames {
+		if strings.EqualFold(s, name) {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown compression %q", s)
+}
+
+// Ext is the extension appended to the names of compressed files, ".gz" or
+// ".zst".
+func (c Compression) Ext() string {
+	switch c {
+	case CompressGzip:
+		return ".gz"
+	case CompressZstd:
+		return ".zst"
+	}
+	return ""
+}
+
+// newCompressor returns the compressor c writes to w with.
+func newCompressor(w io.Writer, c Compression) (io.WriteCloser, error) {
+	switch c {
+	case CompressGzip:
+		return gzip.NewWriter(w), nil
+	case CompressZstd:
+		// One goroutine per file; the downloads already run in parallel.
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	}
+	return nil, fmt.Errorf("unknown compression %v", c)
+}
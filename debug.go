@@ -0,0 +1,47 @@
+package yadloader
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// debugTransport logs every request with its status and timing, and the
+// bodies of API responses up to limit bytes (all of them when negative).
+type debugTransport struct {
+	next  http.RoundTripper
+	logf  func(format string, v ...any)
+	api   string
+	limit int
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	started := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(started).Round(time.Millisecond)
+	if err != nil {
+		t.logf("http: %s %s: %v after %s", req.Method, errorURL(req.URL), err, elapsed)
+		return resp, err
+	}
+	t.logf("http: %s %s: %s in %s", req.Method, errorURL(req.URL), resp.Status, elapsed)
+
+	// Download bodies are file contents, not worth logging.
+	if t.limit == 0 || !strings.HasPrefix(req.URL.String(), t.api) {
+		return resp, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		t.logf("http: reading body: %v", err)
+		return resp, nil
+	}
+	shown := body
+	if t.limit > 0 && len(shown) > t.limit {
+		shown = shown[:t.limit]
+	}
+	t.logf("http: body (%d bytes): %s", len(body), shown)
+	return resp, nil
+}
@@ -0,0 +1,67 @@
+package yadloader
+
+// Changes is the difference between two listings of the same share.
+type Changes struct {
+	Added   []DiskFile
+	Changed []DiskFile
+	Removed []DiskFile
+}
+
+// Empty reports whether nothing was added, changed or removed.
+func (c Changes) Empty() bool {
+	return len(c.Added) == 0 && len(c.Changed) == 0 && len(c.Removed) == 0
+}
+
+// Diff compares listing next with an earlier listing prev by path. Added
+// and Changed hold files of next in its order, Removed files of prev.
+func Diff(prev, next []DiskFile) Changes {
+	return DiffFunc(prev, next, sameContent)
+}
+
+// DiffFunc is Diff with files of the same path compared by same, such as
+// SameHash for listings of different shares.
+func DiffFunc(prev, next []DiskFile, same func(a, b DiskFile) bool) Changes {
+	old := make(map[string]DiskFile, len(prev))
+	for _, f := range prev {
+		old[f.Path] = f
+	}
+
+	var c Changes
+	seen := make(map[string]bool, len(next))
+	for _, f := range next {
+		seen[f.Path] = true
+		o, ok := old[f.Path]
+		switch {
+		case !ok:
+			c.Added = append(c.Added, f)
+		case !same(o, f):
+			c.Changed = append(c.Changed, f)
+		}
+	}
+	for _, f := range prev {
+		if !seen[f.Path] {
+			c.Removed = append(c.Removed, f)
+		}
+	}
+	return c
+}
+
+// sameContent compares what the API reports about the file contents; the
+// download link changes with every listing.
+func sameContent(a, b DiskFile) bool {
+	return a.Size == b.Size && a.MD5 == b.MD5 && a.SHA256 == b.SHA256 && a.Modified.Equal(b.Modified)
+}
+
+// SameHash compares files by size and the hashes both of them have; copies
+// of a file in different shares have different modification times.
+func SameHash(a, b DiskFile) bool {
+	switch {
+	case a.Size != b.Size:
+		return false
+	case a.SHA256 != "" && b.SHA256 != "":
+		return a.SHA256 == b.SHA256
+	case a.MD5 != "" && b.MD5 != "":
+		return a.MD5 == b.MD5
+	}
+	return true
+}
@@ -0,0 +1,77 @@
+package yadloader
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"strings"
+)
+
+// ErrChecksumMismatch fails a file whose downloaded bytes do not have the
+// checksum the API lists for it.
+var ErrChecksumMismatch = errors.New("checksum mismatch")
+
+// Digests are the checksums of a downloaded file computed with
+// Config.Digests. CRC32 is the IEEE polynomial in hex, as zip and most DAMs
+// use it.
+type Digests struct {
+	MD5    string `json:"md5"`
+	SHA256 string `json:"sha256"`
+	CRC32  string `json:"crc32"`
+}
+
+// FileDigests computes the digests of the local file name in one pass.
+func FileDigests(name string) (Digests, error) {
+	f, err := os.Open(longPath(name))
+	if err != nil {
+		return Digests{}, err
+	}
+	defer f.Close()
+	d := newDigester()
+	if _, err := io.Copy(d, f); err != nil {
+		return Digests{}, err
+	}
+	return d.digests(), nil
+}
+
+// digester computes Digests of what is written to it.
+type digester struct {
+	md5, sha256, crc32 hash.Hash
+}
+
+func newDigester() *digester {
+	return &digester{md5: md5.New(), sha256: sha256.New(), crc32: crc32.NewIEEE()}
+}
+
+func (d *digester) Write(p []byte) (int, error) {
+	// The hashes never fail.
+	d.md5.Write(p)
+	d.sha256.Write(p)
+	d.crc32.Write(p)
+	return len(p), nil
+}
+
+func (d *digester) digests() Digests {
+	return Digests{
+		MD5:    hex.EncodeToString(d.md5.Sum(nil)),
+		SHA256: hex.EncodeToString(d.sha256.Sum(nil)),
+		CRC32:  hex.EncodeToString(d.crc32.Sum(nil)),
+	}
+}
+
+// verify checks d against the checksums the API lists for file.
+func (d Digests) verify(file DiskFile) error {
+	if file.MD5 != "" && !strings.EqualFold(d.MD5, file.MD5) {
+		return fmt.Errorf("%w: MD5 is %s, expected %s", ErrChecksumMismatch, d.MD5, file.MD5)
+	}
+	if file.SHA256 != "" && !strings.EqualFold(d.SHA256, file.SHA256) {
+		return fmt.Errorf("%w: SHA256 is %s, expected %s", ErrChecksumMismatch, d.SHA256, file.SHA256)
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package yadloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+)
+
+// DiskInfo is the space of the user's disk, in bytes. UsedSpace includes
+// what is in the Trash.
+type DiskInfo struct {
+	TotalSpace  int64 `json:"total_space"`
+	UsedSpace   int64 `json:"used_space"`
+	TrashSize   int64 `json:"trash_size"`
+	MaxFileSize int64 `json:"max_file_size"`
+	IsPaid      bool  `json:"is_paid"`
+	// SystemFolders maps folders like "downloads", where SaveToDisk saves
+	// to by default, to their paths.
+	SystemFolders map[string]string `json:"system_folders"`
+	User          struct {
+		Login       string `json:"login"`
+		DisplayName string `json:"display_name"`
+	} `json:"user"`
+}
+
+// FreeSpace is what is left of TotalSpace.
+func (d DiskInfo) FreeSpace() int64 {
+	return max(d.TotalSpace-d.UsedSpace, 0)
+}
+
+// GetDiskInfo returns the space of the disk the Token belongs to.
+func (c *YaDiskClient) GetDiskInfo(ctx context.Context) (*DiskInfo, error) {
+	if c.config.Token == "" {
+		return nil, ErrTokenRequired
+	}
+	body, err := c.request(ctx, c.apiURL())
+	if err != nil {
+		return nil, err
+	}
+	var info DiskInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, err
+	}
+	return &info, nil
+}
+
+// downloadsFolder returns the folder of the disk public resources are
+// saved to by default.
+func (c *YaDiskClient) downloadsFolder(ctx context.Context) (string, error) {
+	info, err := c.GetDiskInfo(ctx)
+	if err != nil {
+		return "", err
+	}
+	if info.SystemFolders["downloads"] == "" {
+		return "", errors.New("yandex disk: no downloads folder")
+	}
+	return info.SystemFolders["downloads"], nil
+}
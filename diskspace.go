@@ -0,0 +1,85 @@
+package yadloader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+var ErrInsufficientSpace = errors.New("insufficient free space")
+
+type InsufficientSpaceError struct {
+	Path      string
+	Needed    int64
+	Available int64
+}
+
+func (e *InsufficientSpaceError) Error() string {
+	return fmt.Sprintf("%s: %d bytes needed, %d available", e.Path, e.Needed, e.Available)
+}
+
+func (e *InsufficientSpaceError) Is(target error) bool {
+	return target == ErrInsufficientSpace
+}
+
+// CheckFreeSpace fails with an *InsufficientSpaceError when the filesystem
+// of dest cannot hold files, not counting what their part files already
+// hold. It returns nil when free space cannot be determined on this
+// platform. Part files are looked for where the default layout puts them;
+// YaDiskClient.CheckFreeSpace follows the Layout of the client.
+func CheckFreeSpace(dest string, files []DiskFile) error {
+	return checkFreeSpace(dest, files, func(f DiskFile) string {
+		return LocalPath(dest, f) + PartSuffix
+	})
+}
+
+// CheckFreeSpace is CheckFreeSpace with part files where DownloadFiles,
+// given opts, writes them.
+func (c *YaDiskClient) CheckFreeSpace(dest string, files []DiskFile, opts ...Option) error {
+	c = c.with(opts)
+	return checkFreeSpace(dest, files, func(f DiskFile) string {
+		local, err := c.localPath(dest, f)
+		if err != nil {
+			return ""
+		}
+		return local + c.config.fileExt() + PartSuffix
+	})
+}
+
+func checkFreeSpace(dest string, files []DiskFile, partFile func(f DiskFile) string) error {
+	var needed int64
+	for _, f := range files {
+		needed += f.Size
+		if part := partFile(f); part != "" {
+			if st, err := os.Stat(longPath(part)); err == nil {
+				needed -= min(st.Size(), f.Size)
+			}
+		}
+	}
+
+	// dest itself may not exist yet.
+	dir := dest
+	for {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	free, err := freeSpace(dir)
+	if errors.Is(err, errors.ErrUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if uint64(needed) > free {
+		return &InsufficientSpaceError{Path: dest, Needed: needed, Available: int64(free)}
+	}
+	return nil
+}
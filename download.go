@@ -0,0 +1,634 @@
+package yadloader
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"iter"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventStarted  EventType = "download_started"
+	EventFinished EventType = "download_finished"
+	EventFailed   EventType = "download_failed"
+	EventSkipped  EventType = "download_skipped"
+	// EventProgress is sent for every running download each
+	// Config.ProgressInterval.
+	EventProgress EventType = "download_progress"
+)
+
+// Event describes progress of a single file download.
+type Event struct {
+	Type EventType
+	File DiskFile
+	// Dest is the local path the file is written to.
+	Dest    string
+	Bytes   int64
+	Elapsed time.Duration
+	Err     error
+	// Speed is the rate of this download and Total that of all downloads
+	// of the run, which have received TotalBytes so far. They are set on
+	// EventProgress only.
+	Speed      Speed
+	Total      Speed
+	TotalBytes int64
+	// Digests are set on EventFinished with Config.Digests.
+	Digests *Digests
+}
+
+type ProgressFunc func(e Event)
+
+// PartSuffix is appended to files while they are being downloaded.
+const PartSuffix = ".part"
+
+// preallocateMin is the smallest file Config.Preallocate reserves space for.
+const preallocateMin = 1 << 20
+
+// LocalPath maps a remote file to its location under dest. The remote path
+// is cleaned as a rooted path first, so ".." elements can never escape dest.
+func LocalPath(dest string, file DiskFile) string {
+	return filepath.Join(dest, filepath.FromSlash(path.Clean("/"+file.Path)))
+}
+
+// DownloadTree lists path of the public resource link and downloads every
+// file into dest, recreating the folder structure. The report covers the
+// listing time too. With Config.Pipeline, files are downloaded while the
+// listing is still going on. Config.EmptyFolders and Config.FolderTimes
+// are applied to the folders once the files are written.
+func (c *YaDiskClient) DownloadTree(ctx context.Context, link, path, dest string, opts ...Option) (Report, error) {
+	started := time.Now()
+	c = c.with(opts)
+	var (
+		folders  []DiskFile
+		treeOpts []TreeOption
+	)
+	if c.config.EmptyFolders || c.config.FolderTimes {
+		treeOpts = append(treeOpts, WithFolders(func(f DiskFile) {
+			folders = append(folders, f)
+		}))
+	}
+
+	var (
+		report Report
+		err    error
+	)
+	// Objects are shared by files of the whole listing.
+	if c.config.Pipeline && c.config.CASObjects == "" {
+		report, err = c.downloadPipelined(ctx, link, path, dest, treeOpts)
+	} else {
+		var files []DiskFile
+		if files, err = c.GetTree(ctx, link, path, treeOpts...); err != nil {
+			return Report{Started: started, Duration: time.Since(started)}, err
+		}
+		report, err = c.DownloadFiles(ctx, files, dest)
+		report.Started, report.Duration = started, time.Since(started)
+	}
+
+	if len(folders) > 0 && ctx.Err() == nil {
+		if ferr := c.finishFolders(dest, folders); ferr != nil && err == nil {
+			err = ferr
+		}
+	}
+	return report, err
+}
+
+// DownloadFiles downloads files into dest using Concurrency workers,
+// recreating their folders. Each file is written to a PartSuffix file that
+// is renamed into place once complete; a part file left by an earlier
+// attempt is continued where it stopped. The first failure cancels the
+// remaining downloads unless Config.ErrorPolicy is Collect, in which case
+// every failure is recorded in Report.Failures and returned joined into one
+// error. Retryable failures are then attempted again up to
+// Config.RetryPasses times. Config.Hooks can skip files or reject finished
+// downloads. Before starting, the free space of dest is checked, see
+// YaDiskClient.CheckFreeSpace and Config.IgnoreFreeSpace. With Config.CASObjects the
+// files are stored in the object folder instead.
+func (c *YaDiskClient) DownloadFiles(ctx context.Context, files []DiskFile, dest string, opts ...Option) (report Report, err error) {
+	c = c.with(opts)
+	if c.config.CASObjects != "" {
+		return c.downloadCAS(ctx, files, dest)
+	}
+	ctx, cancel := c.withDeadline(ctx)
+	defer cancel()
+
+	report = Report{Files: len(files), Started: time.Now()}
+	defer func() {
+		report.Duration = time.Since(report.Started)
+	}()
+
+	if err := c.CheckFreeSpace(dest, files); err != nil {
+		if !c.config.IgnoreFreeSpace || !errors.Is(err, ErrInsufficientSpace) {
+			return report, err
+		}
+		c.logf("warning: %v", err)
+	}
+
+	if err := c.downloadPass(ctx, slices.Values(files), dest, &report); err != nil {
+		return report, err
+	}
+	return report, c.retryFailed(ctx, dest, &report)
+}
+
+// downloadPipelined feeds files to the download workers as the listing finds
+// them. Their total size is unknown up front, so free space is not checked.
+func (c *YaDiskClient) downloadPipelined(ctx context.Context, link, path, dest string, treeOpts []TreeOption) (report Report, err error) {
+	ctx, cancel := c.withDeadline(ctx)
+	defer cancel()
+	ctx, stop := context.WithCancel(ctx)
+	defer stop()
+
+	report = Report{Started: time.Now()}
+	defer func() {
+		report.Duration = time.Since(report.Started)
+	}()
+
+	found := make(chan DiskFile, c.config.Limit)
+	var listErr error
+	go func() {
+		defer close(found)
+		_, listErr = c.GetTree(ctx, link, path, append(treeOpts, withEmit(func(f DiskFile) {
+			select {
+			case found <- f:
+			case <-ctx.Done():
+			}
+		}))...)
+		if listErr != nil {
+			stop()
+		}
+	}()
+
+	files := func(yield func(DiskFile) bool) {
+		for f := range found {
+			report.Files++
+			if !yield(f) {
+				return
+			}
+		}
+	}
+	err = c.downloadPass(ctx, files, dest, &report)
+	if err != nil {
+		stop()
+	}
+	// Let the listing finish; listErr is set once found is closed.
+	for range found {
+	}
+	if err != nil {
+		return report, err
+	}
+	if listErr != nil {
+		return report, listErr
+	}
+	return report, c.retryFailed(ctx, dest, &report)
+}
+
+// retryFailed runs the retry passes of a download and returns the final
+// error.
+func (c *YaDiskClient) retryFailed(ctx context.Context, dest string, report *Report) error {
+	var exhausted error
+	for pass := 1; pass <= c.config.RetryPasses && c.config.ErrorPolicy == Collect; pass++ {
+		retry := report.Retryable()
+		if len(retry) == 0 || ctx.Err() != nil {
+			break
+		}
+		if err := c.budget.take(len(retry)); err != nil {
+			exhausted = fmt.Errorf("%w, %d failed files not retried", err, len(retry))
+			break
+		}
+		report.Failures = slices.DeleteFunc(report.Failures, func(f *FileError) bool {
+			return IsRetryable(f.Err)
+		})
+		report.Failed -= len(retry)
+		report.Retried += len(retry)
+
+		c.logf("retrying %d failed files, pass %d of %d", len(retry), pass, c.config.RetryPasses)
+		if err := sleep(ctx, c.budget.pause(c.config.Wait)); err != nil {
+			break
+		}
+		if err := c.downloadPass(ctx, slices.Values(retry), dest, report); err != nil {
+			return err
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	errs := []error{exhausted}
+	for _, f := range report.Failures {
+		errs = append(errs, f)
+	}
+	return errors.Join(errs...)
+}
+
+// downloadPass downloads files once, adding the outcome to report. With
+// FailFast it returns the first failure.
+func (c *YaDiskClient) downloadPass(ctx context.Context, files iter.Seq[DiskFile], dest string, report *Report) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+
+	notify := func(e Event) {
+		if c.config.Progress != nil {
+			c.config.Progress(e)
+		}
+	}
+
+	speed := &speedometer{last: report.Bytes}
+	if interval := c.config.ProgressInterval; interval > 0 && c.config.Progress != nil {
+		stop, stopped := make(chan struct{}), make(chan struct{})
+		defer func() {
+			close(stop)
+			<-stopped
+		}()
+		go func() {
+			defer close(stopped)
+			ticker := time.NewTicker(interval)
+			defer ticker.Stop()
+			last := time.Now()
+			for {
+				select {
+				case <-stop:
+					return
+				case now := <-ticker.C:
+					mu.Lock()
+					for _, e := range speed.sample(report.Bytes, now.Sub(last)) {
+						notify(e)
+					}
+					mu.Unlock()
+					last = now
+				}
+			}
+		}()
+	}
+
+	jobs := make(chan DiskFile)
+	for range clamp(c.config.Concurrency, MaxConcurrency) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range jobs {
+				target, err := c.localPath(dest, file)
+				var skip bool
+				if err == nil {
+					target, skip, err = c.applyOverwrite(target + c.config.fileExt())
+				}
+				if err == nil && !skip {
+					skip = c.skipInfected(file)
+				}
+				if err == nil && !skip {
+					skip, err = c.beforeFile(ctx, file, target)
+				}
+				if skip {
+					e := Event{Type: EventSkipped, File: file, Dest: target}
+					c.afterFile(ctx, &e)
+					mu.Lock()
+					report.Skipped++
+					notify(e)
+					mu.Unlock()
+					continue
+				}
+
+				mu.Lock()
+				notify(Event{Type: EventStarted, File: file, Dest: target})
+				m := speed.start(file, target)
+				mu.Unlock()
+
+				started := time.Now()
+				d := downloaded{dest: target}
+				if err == nil {
+					d, err = c.downloadTo(ctx, file, target, &m.received)
+				}
+				e := Event{Type: EventFinished, File: file, Dest: d.dest, Bytes: d.n, Elapsed: time.Since(started), Digests: d.digests}
+				if err != nil {
+					e.Type, e.Err = EventFailed, err
+				}
+				c.afterFile(ctx, &e)
+
+				mu.Lock()
+				speed.stop(m)
+				report.Bytes += d.n
+				if e.Err == nil && d.digests != nil {
+					if report.Digests == nil {
+						report.Digests = make(map[string]Digests)
+					}
+					report.Digests[file.Path] = *d.digests
+				}
+				if e.Err == nil && d.mismatch != nil {
+					report.TypeMismatches = append(report.TypeMismatches, *d.mismatch)
+				}
+				if e.Err == nil && d.served != "" && d.served != file.Name {
+					report.ServedNames = append(report.ServedNames, ServedName{Path: file.Path, Name: file.Name, Served: d.served, Dest: d.dest})
+				}
+				if e.Err != nil {
+					fe := &FileError{File: file, Dest: d.dest, Err: e.Err}
+					switch {
+					case errors.Is(e.Err, ErrRetryBudget) && c.config.ErrorPolicy == Collect:
+						// The files left would fail the same way.
+						report.Failures = append(report.Failures, fe)
+						report.Failed++
+						if firstErr == nil {
+							firstErr = fe
+							cancel()
+						}
+					case c.config.ErrorPolicy == Collect:
+						// Files interrupted by cancellation are not failures of
+						// their own.
+						if ctx.Err() == nil || !errors.Is(e.Err, context.Canceled) {
+							report.Failures = append(report.Failures, fe)
+							report.Failed++
+						}
+					case firstErr == nil:
+						report.Failed++
+						firstErr = fe
+						cancel()
+					}
+				} else {
+					report.Downloaded++
+				}
+				notify(e)
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for file := range files {
+		select {
+		case jobs <- file:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return firstErr
+}
+
+// downloaded is the outcome of downloadTo.
+type downloaded struct {
+	// dest is where the file was saved, target unless it was served under
+	// another name with Config.ServedNames.
+	dest    string
+	served  string
+	n       int64
+	digests *Digests
+	// mismatch is set with Config.SniffTypes.
+	mismatch *TypeMismatch
+}
+
+// downloadTo downloads file into target through a part file, counting the
+// bytes written in received.
+func (c *YaDiskClient) downloadTo(ctx context.Context, file DiskFile, target string, received *atomic.Int64) (d downloaded, err error) {
+	d.dest = target
+	if timeout := c.config.PerFileTimeout; timeout > 0 {
+		parent := ctx
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+		defer func() {
+			if err != nil && parent.Err() == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				err = fmt.Errorf("%w after %s", ErrFileTimeout, timeout)
+			}
+		}()
+	}
+
+	long := longPath(target)
+	if err := os.MkdirAll(filepath.Dir(long), c.config.dirMode()); err != nil {
+		return d, err
+	}
+
+	part := long + PartSuffix
+	f, err := os.OpenFile(part, os.O_RDWR|os.O_CREATE, c.config.fileMode())
+	if err != nil {
+		return d, err
+	}
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		f.Close()
+		return d, err
+	}
+	// Previews have sizes and checksums of their own, which the API does
+	// not tell.
+	verify := offset > 0 && c.config.PreviewSize == ""
+	encoded := c.config.encoded()
+	switch {
+	case offset > 0 && encoded:
+		// A compressed or encrypted stream cannot be continued.
+		c.logf("cannot continue %s, which is encoded, downloading it again", part)
+		offset, verify, err = 0, false, truncateFile(f)
+	case verify && file.Size > 0 && offset > file.Size:
+		c.logf("%s is larger than %s, downloading it again", part, file.Path)
+		offset, err = 0, truncateFile(f)
+	case offset > 0:
+		c.logf("continuing %s from %d bytes", part, offset)
+	}
+
+	if err == nil && c.config.Preallocate && !encoded && c.config.PreviewSize == "" && file.Size >= preallocateMin && offset < file.Size {
+		if perr := preallocate(f, file.Size); perr != nil && !errors.Is(perr, errors.ErrUnsupported) {
+			c.logf("cannot preallocate %s: %v", part, perr)
+		}
+	}
+	var (
+		out io.Writer = &meteredFile{File: f, n: received}
+		ef  *encodedFile
+	)
+	if err == nil && encoded {
+		ef, err = c.newEncodedFile(f, received)
+		out = ef
+	}
+	if err == nil {
+		d.n, d.served, err = c.download(ctx, file, out, offset)
+	}
+	if err == nil && ef != nil {
+		err = ef.Close()
+	}
+	if err == nil && verify && offset > 0 {
+		// The part file may have been damaged while it waited; only the
+		// whole file can be checked.
+		var ok bool
+		if ok, err = intact(f, file); err == nil && !ok {
+			c.logf("%s does not match %s after resuming, downloading it again", part, file.Path)
+			if err = truncateFile(f); err == nil {
+				var again int64
+				again, d.served, err = c.download(ctx, file, &meteredFile{File: f, n: received}, 0)
+				d.n += again
+			}
+		}
+	}
+	if err == nil && c.config.SniffTypes && c.config.PreviewSize == "" {
+		var head []byte
+		if ef != nil {
+			head = ef.head
+		} else {
+			head = make([]byte, sniffLen)
+			var n int
+			if n, err = f.ReadAt(head, 0); err == io.EOF {
+				err = nil
+			}
+			head = head[:n]
+		}
+		if m, ok := typeMismatch(file, head); ok {
+			d.mismatch = &m
+		}
+	}
+	if err == nil && c.config.SyncWrites {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// The part file stays for the next attempt to continue.
+		return d, err
+	}
+
+	if c.config.Digests {
+		// Encoded files are hashed as they are written, so the digests are
+		// those of the original.
+		var digests Digests
+		if ef != nil {
+			digests = ef.digests.digests()
+		} else if digests, err = FileDigests(part); err != nil {
+			return d, err
+		}
+		// Previews do not have the checksums of the originals.
+		if c.config.PreviewSize == "" {
+			if err := digests.verify(file); err != nil {
+				// The next attempt must not continue the broken file.
+				os.Remove(part)
+				return d, err
+			}
+		}
+		d.digests = &digests
+	}
+
+	if name := servedName(d.served); c.config.ServedNames && name != "" && name+c.config.fileExt() != filepath.Base(target) {
+		dest, skip, err := c.applyOverwrite(filepath.Join(filepath.Dir(target), name+c.config.fileExt()))
+		if err != nil {
+			return d, err
+		}
+		d.dest = dest
+		if skip {
+			return d, os.Remove(part)
+		}
+	}
+	long = longPath(d.dest)
+	if err := os.Rename(part, long); err != nil {
+		return d, err
+	}
+	// Previews do not have the checksums of the originals.
+	if c.config.Xattrs && c.config.PreviewSize == "" {
+		if err := writeFileAttrs(long, file); err != nil {
+			c.logf("cannot write extended attributes of %s: %v", d.dest, err)
+		}
+	}
+	if c.config.SyncWrites {
+		// Without this the rename may be lost on power failure, leaving the
+		// part file or nothing at all.
+		if err := syncDir(filepath.Dir(long)); err != nil {
+			return d, err
+		}
+	}
+	return d, nil
+}
+
+// servedName makes the file name of a Content-Disposition header safe to
+// save under, or returns "" if nothing of it is usable.
+func servedName(name string) string {
+	name = path.Base(strings.ReplaceAll(name, `\`, "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || strings.ContainsRune(`<>:"|?*`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.TrimRight(strings.TrimSpace(name), ".")
+	if name == "" || name == "/" {
+		return ""
+	}
+	return name
+}
+
+// intact reports whether f has the size and MD5 of file, as far as they are
+// known.
+func intact(f *os.File, file DiskFile) (bool, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	if file.Size > 0 && info.Size() != file.Size {
+		return false, nil
+	}
+	if file.MD5 == "" {
+		return true, nil
+	}
+	h := md5.New()
+	if _, err := io.Copy(h, io.NewSectionReader(f, 0, info.Size())); err != nil {
+		return false, err
+	}
+	return strings.EqualFold(hex.EncodeToString(h.Sum(nil)), file.MD5), nil
+}
+
+func truncateFile(f *os.File) error {
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	_, err := f.Seek(0, io.SeekStart)
+	return err
+}
+
+func (c *Config) fileMode() os.FileMode {
+	if c.FileMode == 0 {
+		return 0644
+	}
+	return c.FileMode
+}
+
+func (c *Config) dirMode() os.FileMode {
+	if c.DirMode == 0 {
+		return 0755
+	}
+	return c.DirMode
+}
+
+// RemovePartFiles deletes part files left under dest by interrupted runs
+// and returns how many were removed.
+func RemovePartFiles(dest string) (int, error) {
+	var removed int
+	err := filepath.WalkDir(longPath(dest), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), PartSuffix) {
+			return nil
+		}
+		if err := os.Remove(p); err != nil {
+			return err
+		}
+		removed++
+		return nil
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		err = nil
+	}
+	return removed, err
+}
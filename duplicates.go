@@ -0,0 +1,38 @@
+package yadloader
+
+import (
+	"cmp"
+	"slices"
+)
+
+// Duplicates groups files with the same content by MD5 and size. Groups are
+// ordered by the space the extra copies take, largest first, and list their
+// files by path. Files without an MD5 are left out.
+func Duplicates(files []DiskFile) [][]DiskFile {
+	type key struct {
+		md5  string
+		size int64
+	}
+	byContent := make(map[key][]DiskFile)
+	for _, f := range files {
+		if f.MD5 == "" {
+			continue
+		}
+		k := key{f.MD5, f.Size}
+		byContent[k] = append(byContent[k], f)
+	}
+
+	var groups [][]DiskFile
+	for _, g := range byContent {
+		if len(g) < 2 {
+			continue
+		}
+		slices.SortFunc(g, func(a, b DiskFile) int { return cmp.Compare(a.Path, b.Path) })
+		groups = append(groups, g)
+	}
+	slices.SortFunc(groups, func(a, b []DiskFile) int {
+		wasteA, wasteB := a[0].Size*int64(len(a)-1), b[0].Size*int64(len(b)-1)
+		return cmp.Or(cmp.Compare(wasteB, wasteA), cmp.Compare(a[0].Path, b[0].Path))
+	})
+	return groups
+}
@@ -0,0 +1,88 @@
+package yadloader
+
+import (
+	"io"
+	"sync/atomic"
+
+	"filippo.io/age"
+)
+
+// EncryptedExt is appended to the names of files encrypted with
+// Config.Recipients, after that of the compression.
+const EncryptedExt = ".age"
+
+// encoded reports whether downloaded files are compressed or encrypted
+// rather than saved as they are.
+func (c *Config) encoded() bool {
+	return c.Compression != CompressNone || len(c.Recipients) > 0
+}
+
+// fileExt is what encoding adds to the names of downloaded files.
+func (c *Config) fileExt() string {
+	ext := c.Compression.Ext()
+	if len(c.Recipients) > 0 {
+		ext += EncryptedExt
+	}
+	return ext
+}
+
+// encodedFile writes a download into its part file through the compressor
+// and encryption of Config, counting the original bytes in n, hashing them
+// when digests is set and keeping the first of them in head when sniff is.
+type encodedFile struct {
+	w io.Writer
+	// closers end the streams, the outermost first.
+	closers []io.Closer
+	n       *atomic.Int64
+	digests *digester
+	sniff   bool
+	head    []byte
+}
+
+func (c *YaDiskClient) newEncodedFile(w io.Writer, n *atomic.Int64) (*encodedFile, error) {
+	f := &encodedFile{n: n, sniff: c.config.SniffTypes}
+	// Encrypted data does not compress, so compression comes first.
+	if len(c.config.Recipients) > 0 {
+		enc, err := age.Encrypt(w, c.config.Recipients...)
+		if err != nil {
+			return nil, err
+		}
+		w = enc
+		f.closers = append(f.closers, enc)
+	}
+	if c.config.Compression != CompressNone {
+		comp, err := newCompressor(w, c.config.Compression)
+		if err != nil {
+			return nil, err
+		}
+		w = comp
+		f.closers = append([]io.Closer{comp}, f.closers...)
+	}
+	f.w = w
+	if c.config.Digests {
+		f.digests = newDigester()
+	}
+	return f, nil
+}
+
+func (f *encodedFile) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	f.n.Add(int64(n))
+	if f.digests != nil {
+		f.digests.Write(p[:n])
+	}
+	if f.sniff && len(f.head) < sniffLen {
+		f.head = append(f.head, p[:min(n, sniffLen-len(f.head))]...)
+	}
+	return n, err
+}
+
+// Close ends the streams; the part file stays open.
+func (f *encodedFile) Close() error {
+	for _, c := range f.closers {
+		if err := c.Close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
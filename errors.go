@@ -0,0 +1,89 @@
+package yadloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+)
+
+// ErrFileTimeout fails a file that took longer than Config.PerFileTimeout.
+// Such failures are retryable.
+var ErrFileTimeout = errors.New("file download timed out")
+
+// TruncatedDownloadError fails a download that ended before the size the
+// listing or the Content-Length header gave. Such failures are retryable.
+type TruncatedDownloadError struct {
+	Expected int64
+	Received int64
+}
+
+func (e *TruncatedDownloadError) Error() string {
+	return fmt.Sprintf("download truncated at %d of %d bytes", e.Received, e.Expected)
+}
+
+// ErrorPolicy decides how DownloadFiles reacts to a failed file.
+type ErrorPolicy int
+
+const (
+	// FailFast cancels the remaining downloads on the first failure.
+	FailFast ErrorPolicy = iota
+	// Collect keeps going and reports every failure at the end.
+	Collect
+)
+
+// FileError is the failure of a single file.
+type FileError struct {
+	File DiskFile
+	// Dest is the local file it was downloaded to, if it got that far.
+	Dest string
+	Err  error
+}
+
+func (e *FileError) Error() string {
+	if e.Dest == "" {
+		return fmt.Sprintf("%s: %v", e.File.Path, e.Err)
+	}
+	return fmt.Sprintf("%s -> %s: %v", e.File.Path, e.Dest, e.Err)
+}
+
+func (e *FileError) Unwrap() error {
+	return e.Err
+}
+
+func (e *FileError) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Path      string `json:"path"`
+		Dest      string `json:"dest,omitempty"`
+		Size      int64  `json:"size"`
+		Error     string `json:"error"`
+		Retryable bool   `json:"retryable"`
+	}{e.File.Path, e.Dest, e.File.Size, e.Err.Error(), IsRetryable(e.Err)})
+}
+
+// IsRetryable reports whether trying the operation again may succeed.
+func IsRetryable(err error) bool {
+	switch {
+	case err == nil:
+		return false
+	case errors.Is(err, context.Canceled),
+		errors.Is(err, ErrRetryBudget),
+		errors.Is(err, ErrFileExists),
+		errors.Is(err, ErrTokenRequired),
+		errors.Is(err, ErrAfterFile),
+		errors.Is(err, ErrInsufficientSpace):
+		return false
+	}
+	// Local filesystem failures, such as a full disk or a read-only or
+	// unwritable folder, fail every attempt the same way.
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return false
+	}
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.Temporary()
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package yadloader
+
+import (
+	"context"
+	"iter"
+	"slices"
+)
+
+// Files iterates over the files under path of the public resource link,
+// fetching listing pages as the loop asks for more; breaking out of the loop
+// stops further API calls. Folders are walked one at a time, depth first,
+// and Filter, WithMaxDepth, WithFolders and the per-folder order of WithSort
+// apply. An error ends the iteration after being yielded.
+func (c *YaDiskClient) Files(ctx context.Context, link, path string, opts ...TreeOption) iter.Seq2[DiskFile, error] {
+	return func(yield func(DiskFile, error) bool) {
+		ctx, stop := c.withDeadline(ctx)
+		defer stop()
+
+		link, path, err := resolveLink(link, path)
+		if err != nil {
+			yield(DiskFile{}, err)
+			return
+		}
+		if path == "" {
+			path = "/"
+		}
+		if link == "" && c.config.Token == "" {
+			yield(DiskFile{}, ErrTokenRequired)
+			return
+		}
+		options := newTreeOptions(opts)
+
+		stack := []folder{{path, 1}}
+		for len(stack) > 0 {
+			dir := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+
+			var subdirs []folder
+			for offset := 0; ; offset += c.config.Limit {
+				r, err := c.listPage(ctx, link, dir.path, offset, options)
+				if err != nil {
+					yield(DiskFile{}, err)
+					return
+				}
+				if r.Type == FILE {
+					file := newResource(*r).DiskFile()
+					file.PublicKey = link
+					if (options.unfiltered || c.config.Filter.Match(file)) && !yield(file, nil) {
+						return
+					}
+					break
+				}
+				if r.Embedded == nil || len(r.Embedded.Items) == 0 {
+					break
+				}
+
+				for _, i := range r.Embedded.Items {
+					switch i.Type {
+					case FILE:
+						file := newDiskFile(i)
+						file.PublicKey = link
+						if (options.unfiltered || c.config.Filter.Match(file)) && !yield(file, nil) {
+							return
+						}
+					case DIR:
+						if limit := options.maxDepth; limit == 0 || dir.depth < limit {
+							subdirs = append(subdirs, folder{diskPath(i.Path), dir.depth + 1})
+							if options.folders != nil {
+								d := newDiskFile(i)
+								d.PublicKey = link
+								options.folders(d)
+							}
+						}
+					}
+				}
+				if offset+len(r.Embedded.Items) >= r.Embedded.Total {
+					break
+				}
+				if err := sleep(ctx, c.config.pageDelay()); err != nil {
+					yield(DiskFile{}, err)
+					return
+				}
+			}
+			// Pushed in reverse so they are walked in listing order.
+			slices.Reverse(subdirs)
+			stack = append(stack, subdirs...)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package yadloader
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// FilterOptions selects which files GetTree returns. Zero values disable
+// the corresponding check.
+type FilterOptions struct {
+	MinSize int64
+	MaxSize int64
+	// NewerThan and OlderThan compare against the Modified timestamp.
+	NewerThan time.Time
+	OlderThan time.Time
+	// MediaTypes lists accepted media types as reported by the API, such as
+	// image, video, audio or document.
+	MediaTypes []string
+	// Extensions, when set, accepts only files with one of these
+	// extensions, and BlockedExtensions refuses files with any of them.
+	// Extensions are compared case-insensitively, with or without the
+	// leading dot, and may span dots, as in "tar.gz".
+	Extensions        []string
+	BlockedExtensions []string
+}
+
+func (f FilterOptions) Match(file DiskFile) bool {
+	if f.MinSize > 0 && file.Size < f.MinSize {
+		return false
+	}
+	if f.MaxSize > 0 && file.Size > f.MaxSize {
+		return false
+	}
+	if !f.NewerThan.IsZero() && !file.Modified.After(f.NewerThan) {
+		return false
+	}
+	if !f.OlderThan.IsZero() && !file.Modified.Before(f.OlderThan) {
+		return false
+	}
+	if len(f.MediaTypes) > 0 && !slices.ContainsFunc(f.MediaTypes, func(t string) bool {
+		return strings.EqualFold(t, file.MediaType)
+	}) {
+		return false
+	}
+	if len(f.Extensions) > 0 && !hasExtension(file.Name, f.Extensions) {
+		return false
+	}
+	if hasExtension(file.Name, f.BlockedExtensions) {
+		return false
+	}
+	return true
+}
+
+// hasExtension reports whether name ends in one of exts.
+func hasExtension(name string, exts []string) bool {
+	name = strings.ToLower(name)
+	return slices.ContainsFunc(exts, func(ext string) bool {
+		ext = strings.ToLower(strings.TrimPrefix(ext, "."))
+		return ext != "" && strings.HasSuffix(name, "."+ext)
+	})
+}
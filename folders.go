@@ -0,0 +1,80 @@
+package yadloader
+
+import (
+	"cmp"
+	"errors"
+	"io/fs"
+	"os"
+	"slices"
+	"strings"
+	"time"
+)
+
+// MakeFolders creates the listed folders that do not exist under dest yet,
+// such as empty ones no file was downloaded into, with the modification
+// times of the share. The folders are those passed to WithFolders. Nothing
+// is created with a Config.Layout, which does not keep the folders of the
+// share. It returns how many folders it created.
+func (c *YaDiskClient) MakeFolders(dest string, folders []DiskFile) (int, error) {
+	if c.config.Layout != nil {
+		return 0, nil
+	}
+	// Parents come first, so they are created here rather than by
+	// MkdirAll of a subfolder and get their times too.
+	sorted := slices.SortedFunc(slices.Values(folders), func(a, b DiskFile) int {
+		return cmp.Compare(a.Path, b.Path)
+	})
+	var made []DiskFile
+	for _, f := range sorted {
+		dir := longPath(LocalPath(dest, f))
+		if _, err := os.Lstat(dir); err == nil {
+			continue
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return len(made), err
+		}
+		if err := os.MkdirAll(dir, c.config.dirMode()); err != nil {
+			return len(made), err
+		}
+		made = append(made, f)
+	}
+	// Times are set once all folders exist, as creating a subfolder
+	// changes the time of its parent.
+	return len(made), c.SetFolderTimes(dest, made)
+}
+
+// SetFolderTimes gives the listed folders under dest the modification times
+// of the share, deepest first, so it is called once their files are
+// written. Folders missing locally are left out, and nothing is changed with
+// a Config.Layout.
+func (c *YaDiskClient) SetFolderTimes(dest string, folders []DiskFile) error {
+	if c.config.Layout != nil {
+		return nil
+	}
+	sorted := slices.SortedStableFunc(slices.Values(folders), func(a, b DiskFile) int {
+		return cmp.Compare(strings.Count(b.Path, "/"), strings.Count(a.Path, "/"))
+	})
+	for _, f := range sorted {
+		if f.Modified.IsZero() {
+			continue
+		}
+		err := os.Chtimes(longPath(LocalPath(dest, f)), time.Time{}, f.Modified)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// finishFolders creates the empty folders and sets the folder times
+// DownloadTree is configured for.
+func (c *YaDiskClient) finishFolders(dest string, folders []DiskFile) error {
+	if c.config.EmptyFolders {
+		if _, err := c.MakeFolders(dest, folders); err != nil {
+			return err
+		}
+	}
+	if c.config.FolderTimes {
+		return c.SetFolderTimes(dest, folders)
+	}
+	return nil
+}
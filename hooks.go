@@ -0,0 +1,52 @@
+package yadloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrSkipFile is returned by Hooks.BeforeFile to leave a file out without
+// counting it as a failure.
+var ErrSkipFile = errors.New("skip file")
+
+// ErrAfterFile fails a downloaded file whose Hooks.AfterFile returned an
+// error. Such failures are not retryable, the download itself succeeded.
+var ErrAfterFile = errors.New("after file hook failed")
+
+// Hooks are called by DownloadFiles around every file. They run on the
+// download workers, concurrently for different files.
+type Hooks struct {
+	// BeforeFile is called with the local target before a file is
+	// downloaded. ErrSkipFile skips the file, any other error fails it.
+	BeforeFile func(ctx context.Context, file DiskFile, dest string) error
+	// AfterFile is called with the finished, failed or skipped event of a
+	// file. An error turns a finished download into a failure wrapping
+	// ErrAfterFile, for example when a scan rejects it.
+	AfterFile func(ctx context.Context, e Event) error
+}
+
+func (c *YaDiskClient) beforeFile(ctx context.Context, file DiskFile, dest string) (skip bool, err error) {
+	if c.config.Hooks.BeforeFile == nil {
+		return false, nil
+	}
+	err = c.config.Hooks.BeforeFile(ctx, file, dest)
+	if errors.Is(err, ErrSkipFile) {
+		return true, nil
+	}
+	return false, err
+}
+
+func (c *YaDiskClient) afterFile(ctx context.Context, e *Event) {
+	if c.config.Hooks.AfterFile == nil {
+		return
+	}
+	err := c.config.Hooks.AfterFile(ctx, *e)
+	switch {
+	case err == nil:
+	case e.Type == EventFinished:
+		e.Type, e.Err = EventFailed, fmt.Errorf("%w: %w", ErrAfterFile, err)
+	default:
+		c.logf("%s: after file hook: %v", e.File.Path, err)
+	}
+}
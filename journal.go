@@ -0,0 +1,114 @@
+package yadloader
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const StateFileName = ".yadloader-state.json"
+
+type FileStatus string
+
+const (
+	StatusPending FileStatus = "pending"
+	StatusDone    FileStatus = "done"
+	StatusFailed  FileStatus = "failed"
+)
+
+// Journal records the tree snapshot and the status of every file of a run,
+// so an interrupted run can be resumed without listing the share again.
+type Journal struct {
+	mu sync.Mutex
+
+	Link    string                `json:"link"`
+	Path    string                `json:"path"`
+	Updated time.Time             `json:"updated"`
+	LastRun time.Time             `json:"last_run,omitzero"`
+	Tree    []DiskFile            `json:"tree"`
+	Files   map[string]FileStatus `json:"files"`
+}
+
+func NewJournal(link, path string, files []DiskFile) *Journal {
+	j := &Journal{
+		Link:  link,
+		Path:  path,
+		Tree:  files,
+		Files: make(map[string]FileStatus, len(files)),
+	}
+	for _, f := range files {
+		j.Files[f.Path] = StatusPending
+	}
+	return j
+}
+
+func (j *Journal) Mark(path string, status FileStatus) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.Files[path] = status
+}
+
+func (j *Journal) Status(path string) FileStatus {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.Files[path]
+}
+
+func LoadJournal(filename string) (*Journal, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var j Journal
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, fmt.Errorf("parse journal %s: %w", filename, err)
+	}
+	if j.Files == nil {
+		j.Files = make(map[string]FileStatus, len(j.Tree))
+	}
+	return &j, nil
+}
+
+// Pending returns the files of the snapshot that are not finished yet.
+func (j *Journal) Pending() []DiskFile {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	pending := make([]DiskFile, 0, len(j.Tree))
+	for _, f := range j.Tree {
+		if j.Files[f.Path] != StatusDone {
+			pending = append(pending, f)
+		}
+	}
+	return pending
+}
+
+// Save writes the journal to a temporary file next to filename and renames
+// it into place, so a crash never leaves a half-written journal.
+func (j *Journal) Save(filename string) error {
+	j.mu.Lock()
+	j.Updated = time.Now().UTC()
+	data, err := json.MarshalIndent(j, "", "  ")
+	j.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
@@ -0,0 +1,197 @@
+package yadloader
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Layout maps a remote file to its slash-separated path inside the download
+// folder. A nil Layout keeps the folder structure of the share.
+type Layout func(file DiskFile) string
+
+// FlatLayout puts every file of files directly into the download folder.
+// Files sharing a name are told apart as "name (1).ext", "name (2).ext" and
+// so on in path order, so the same files always get the same names. Files
+// not in the list keep their own name.
+func FlatLayout(files []DiskFile) Layout {
+	return uniqueLayout(files, fileName)
+}
+
+// DateLayout sorts every file of files into YYYY/MM folders by date, such as
+// DiskFile.Modified, keeping its name. Files sharing a name within a month
+// are told apart as with FlatLayout.
+func DateLayout(files []DiskFile, date func(f DiskFile) time.Time) Layout {
+	return uniqueLayout(files, func(f DiskFile) string {
+		return date(f).Format("2006/01/") + fileName(f)
+	})
+}
+
+// uniqueLayout lays files out by place, numbering files that get the same
+// path. Files not in the list are placed as they are.
+func uniqueLayout(files []DiskFile, place func(f DiskFile) string) Layout {
+	sorted := slices.Clone(files)
+	slices.SortFunc(sorted, func(a, b DiskFile) int { return cmp.Compare(a.Path, b.Path) })
+
+	names := make(map[string]string, len(sorted))
+	taken := make(map[string]bool, len(sorted))
+	// Names are compared case-insensitively for Windows and macOS.
+	for _, f := range sorted {
+		name := place(f)
+		if path.Clean("/"+name) == "/" {
+			// Left for localPath to reject.
+			continue
+		}
+		ext := path.Ext(name)
+		candidate := name
+		for i := 1; taken[strings.ToLower(candidate)]; i++ {
+			candidate = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), i, ext)
+		}
+		taken[strings.ToLower(candidate)] = true
+		names[f.Path] = candidate
+	}
+
+	return func(file DiskFile) string {
+		if name, ok := names[file.Path]; ok {
+			return name
+		}
+		return place(file)
+	}
+}
+
+// UniqueNames numbers the files of files that layout gives the same path,
+// as FlatLayout does.
+func UniqueNames(files []DiskFile, layout Layout) Layout {
+	return uniqueLayout(files, layout)
+}
+
+// StripComponents drops the first n folders of every path, like tar
+// --strip-components. Files in fewer folders keep just their name.
+func StripComponents(n int) Layout {
+	return func(file DiskFile) string {
+		parts := strings.Split(strings.TrimPrefix(path.Clean("/"+file.Path), "/"), "/")
+		return path.Join(parts[min(n, len(parts)-1):]...)
+	}
+}
+
+// NameTemplate lays files out by a template such as
+// "{modified:2006-01-02}_{name}" or "{md5}{ext}". Fields are name, base (the
+// name without extension), ext, dir (the folder of the file, so
+// "{dir}/{name}" keeps the structure), path, size, md5, sha256, media_type,
+// and taken, modified and created with an optional time.Format layout, 2006-01-02
+// by default. Slashes in the result make folders. Files given the same name
+// are handled by Config.Overwrite unless the layout is passed through
+// UniqueNames; files given no name at all fail.
+func NameTemplate(tmpl string) (Layout, error) {
+	var parts []func(f DiskFile) string
+	for rest := tmpl; rest != ""; {
+		open := strings.IndexByte(rest, '{')
+		if open < 0 {
+			open = len(rest)
+		}
+		if text := rest[:open]; text != "" {
+			parts = append(parts, func(DiskFile) string { return text })
+		}
+		rest = rest[open:]
+		if rest == "" {
+			break
+		}
+		end := strings.IndexByte(rest, '}')
+		if end < 0 {
+			return nil, fmt.Errorf("unclosed { in template %q", tmpl)
+		}
+		field, err := templateField(rest[1:end])
+		if err != nil {
+			return nil, err
+		}
+		parts = append(parts, field)
+		rest = rest[end+1:]
+	}
+	if len(parts) == 0 {
+		return nil, errors.New("empty template")
+	}
+
+	return func(file DiskFile) string {
+		var b strings.Builder
+		for _, part := range parts {
+			b.WriteString(part(file))
+		}
+		return b.String()
+	}, nil
+}
+
+func templateField(spec string) (func(f DiskFile) string, error) {
+	name, layout, hasLayout := strings.Cut(spec, ":")
+	if !hasLayout {
+		layout = time.DateOnly
+	}
+	timed := func(t func(f DiskFile) time.Time) func(f DiskFile) string {
+		return func(f DiskFile) string { return t(f).Format(layout) }
+	}
+	if hasLayout && name != "taken" && name != "modified" && name != "created" {
+		return nil, fmt.Errorf("template field %q takes no layout", name)
+	}
+
+	switch name {
+	case "name":
+		return fileName, nil
+	case "base":
+		return func(f DiskFile) string {
+			n := fileName(f)
+			return strings.TrimSuffix(n, path.Ext(n))
+		}, nil
+	case "ext":
+		return func(f DiskFile) string { return path.Ext(fileName(f)) }, nil
+	case "dir":
+		return func(f DiskFile) string { return path.Dir(path.Clean("/" + f.Path)) }, nil
+	case "path":
+		return func(f DiskFile) string { return f.Path }, nil
+	case "size":
+		return func(f DiskFile) string { return strconv.FormatInt(f.Size, 10) }, nil
+	case "md5":
+		return func(f DiskFile) string { return f.MD5 }, nil
+	case "sha256":
+		return func(f DiskFile) string { return f.SHA256 }, nil
+	case "media_type":
+		return func(f DiskFile) string { return f.MediaType }, nil
+	case "taken":
+		return timed(TakenOrModified), nil
+	case "modified":
+		return timed(func(f DiskFile) time.Time { return f.Modified }), nil
+	case "created":
+		return timed(func(f DiskFile) time.Time { return f.Created }), nil
+	}
+	return nil, fmt.Errorf("unknown template field %q", name)
+}
+
+// TakenOrModified is when a photo was taken by its EXIF data, or when the
+// file was last modified if that is unknown.
+func TakenOrModified(f DiskFile) time.Time {
+	if !f.Taken.IsZero() {
+		return f.Taken
+	}
+	return f.Modified
+}
+
+func fileName(f DiskFile) string {
+	return path.Base(path.Clean("/" + f.Path))
+}
+
+// localPath is LocalPath with the Layout of c applied. A layout giving no
+// name would put the file at dest itself.
+func (c *YaDiskClient) localPath(dest string, file DiskFile) (string, error) {
+	if c.config.Layout == nil {
+		return LocalPath(dest, file), nil
+	}
+	p := path.Clean("/" + c.config.Layout(file))
+	if p == "/" {
+		return "", errors.New("layout gives no local name")
+	}
+	return filepath.Join(dest, filepath.FromSlash(p)), nil
+}
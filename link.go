@@ -0,0 +1,86 @@
+package yadloader
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+)
+
+// yandexTLDs are the domains disk.yandex.* links are issued on.
+var yandexTLDs = []string{"ru", "com", "kz", "by", "ua", "uz", "az", "com.tr", "com.am", "com.ge", "co.il", "kg", "md", "tj", "tm", "lt", "lv", "ee", "fr"}
+
+func isPublicHost(host string) bool {
+	host = strings.TrimPrefix(strings.ToLower(host), "www.")
+	if host == "yadi.sk" {
+		return true
+	}
+	for _, prefix := range []string{"disk.yandex.", "disk.360.yandex."} {
+		if tld, ok := strings.CutPrefix(host, prefix); ok {
+			return slices.Contains(yandexTLDs, tld)
+		}
+	}
+	return false
+}
+
+// ParsePublicLink splits a public link as pasted from a browser into the
+// public_key the API expects and the path inside the resource. It accepts
+// the /d/ (folder) and /i/ (file) forms on disk.yandex.* and yadi.sk, with
+// or without a scheme, trailing subfolders and query parameters, as well as
+// the legacy /public/?hash= form. Anything that does not look like a URL is
+// returned unchanged as a key.
+func ParsePublicLink(raw string) (publicKey, resourcePath string, err error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", "", fmt.Errorf("empty public link")
+	}
+
+	s := raw
+	if !strings.Contains(s, "://") {
+		host, _, _ := strings.Cut(s, "/")
+		if !isPublicHost(host) {
+			return raw, "", nil
+		}
+		s = "https://" + s
+	}
+
+	u, err := url.Parse(s)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid public link %q: %w", raw, err)
+	}
+	if !isPublicHost(u.Hostname()) {
+		return "", "", fmt.Errorf("invalid public link %q: unknown host %s", raw, u.Host)
+	}
+
+	if hash := u.Query().Get("hash"); hash != "" {
+		return hash, u.Query().Get("path"), nil
+	}
+
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) < 2 || (parts[0] != "d" && parts[0] != "i") || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid public link %q: expected /d/<key> or /i/<key>", raw)
+	}
+
+	publicKey = fmt.Sprintf("https://%s/%s/%s", u.Host, parts[0], parts[1])
+	if len(parts) > 2 {
+		resourcePath = "/" + strings.Join(parts[2:], "/")
+	}
+	return publicKey, resourcePath, nil
+}
+
+// resolveLink normalizes link and prepends the path embedded in it to p.
+// An empty link refers to the user's disk and is returned as is.
+func resolveLink(link, p string) (string, string, error) {
+	if link == "" {
+		return "", p, nil
+	}
+	key, sub, err := ParsePublicLink(link)
+	if err != nil {
+		return "", "", err
+	}
+	if sub != "" {
+		p = path.Join(sub, p)
+	}
+	return key, p, nil
+}
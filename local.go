@@ -0,0 +1,86 @@
+package yadloader
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalFiles lists the regular files under dest as DiskFiles with the
+// slash-separated path below dest, size and modification time. Part files
+// and the state and lock files of runs are left out.
+func LocalFiles(dest string) ([]DiskFile, error) {
+	var files []DiskFile
+	dest = longPath(dest)
+	err := filepath.WalkDir(dest, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return err
+		}
+		if strings.HasSuffix(p, PartSuffix) || d.Name() == StateFileName || d.Name() == LockFileName {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dest, p)
+		if err != nil {
+			return err
+		}
+		files = append(files, DiskFile{
+			Name:     d.Name(),
+			Path:     "/" + filepath.ToSlash(rel),
+			Size:     info.Size(),
+			Modified: info.ModTime(),
+		})
+		return nil
+	})
+	return files, err
+}
+
+// CompareLocal compares files with what was downloaded of them into dest.
+// Added are the files missing locally and Changed those of another size or,
+// with checkMD5, another MD5 than the API reports, taken from WithXattrs
+// attributes where the file has them; a sync would download both. Removed are the local files that are not in files.
+func CompareLocal(dest string, files []DiskFile, checkMD5 bool) (Changes, error) {
+	local, err := LocalFiles(dest)
+	if err != nil {
+		return Changes{}, err
+	}
+	var hashErr error
+	c := DiffFunc(local, files, func(l, remote DiskFile) bool {
+		if l.Size != remote.Size {
+			return false
+		}
+		if !checkMD5 || remote.MD5 == "" || hashErr != nil {
+			return true
+		}
+		if attrs, ok := ReadFileAttrs(LocalPath(dest, l)); ok && attrs.MD5 != "" {
+			return strings.EqualFold(attrs.MD5, remote.MD5)
+		}
+		sum, err := fileMD5(LocalPath(dest, l))
+		if err != nil {
+			hashErr = err
+			return true
+		}
+		return strings.EqualFold(sum, remote.MD5)
+	})
+	return c, hashErr
+}
+
+func fileMD5(name string) (string, error) {
+	f, err := os.Open(longPath(name))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := md5.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,105 @@
+package yadloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LockFileName is the file LockOutput locks in a download folder.
+const LockFileName = ".yadloader.lock"
+
+// lockPoll is how often LockOutput tries again while waiting.
+const lockPoll = 500 * time.Millisecond
+
+// ErrLocked fails LockOutput while another run holds the lock.
+var ErrLocked = errors.New("download folder is locked by another run")
+
+// LockedError is the lock of Path held by the process PID, which is 0 when
+// it is not known.
+type LockedError struct {
+	Path string
+	PID  int
+}
+
+func (e *LockedError) Error() string {
+	if e.PID == 0 {
+		return fmt.Sprintf("%s: %v", e.Path, ErrLocked)
+	}
+	return fmt.Sprintf("%s: %v, process %d", e.Path, ErrLocked, e.PID)
+}
+
+func (e *LockedError) Is(target error) bool {
+	return target == ErrLocked
+}
+
+// OutputLock is the lock of a download folder taken by LockOutput.
+type OutputLock struct {
+	f *os.File
+}
+
+// LockOutput locks the download folder dir, so that runs sharing it do not
+// mix up its state file and part files. The lock is an advisory lock on
+// LockFileName, which holds the PID of the run, and ends with the process,
+// so a crashed run leaves no stale lock behind. When another run holds it,
+// LockOutput fails with a *LockedError, or with wait tries again until the
+// lock is free or ctx is done. It returns errors.ErrUnsupported on
+// platforms without file locks.
+func LockOutput(ctx context.Context, dir string, wait bool) (*OutputLock, error) {
+	name := filepath.Join(dir, LockFileName)
+	f, err := os.OpenFile(longPath(name), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if ok {
+			break
+		}
+		locked := &LockedError{Path: name, PID: lockHolder(name)}
+		if !wait {
+			f.Close()
+			return nil, locked
+		}
+		if err := sleep(ctx, lockPoll); err != nil {
+			f.Close()
+			if errors.Is(err, context.DeadlineExceeded) {
+				return nil, locked
+			}
+			return nil, err
+		}
+	}
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &OutputLock{f: f}, nil
+}
+
+// lockHolder returns the PID written to the lock file name, or 0.
+func lockHolder(name string) int {
+	b, err := os.ReadFile(longPath(name))
+	if err != nil {
+		return 0
+	}
+	pid, _ := strconv.Atoi(strings.TrimSpace(string(b)))
+	return pid
+}
+
+// Unlock releases the lock. The lock file stays, so that runs waiting for
+// it lock the same file.
+func (l *OutputLock) Unlock() error {
+	if err := l.f.Truncate(0); err != nil {
+		l.f.Close()
+		return err
+	}
+	return l.f.Close()
+}
@@ -0,0 +1,7 @@
+//go:build !windows
+
+package yadloader
+
+func longPath(p string) string {
+	return p
+}
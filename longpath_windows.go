@@ -0,0 +1,28 @@
+//go:build windows
+
+package yadloader
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// maxPath is the longest path Windows opens without the \\?\ prefix; folders
+// are limited to MAX_PATH less room for an 8.3 file name.
+const maxPath = 260 - 12
+
+// longPath returns p in the \\?\ form when it is too long for the plain
+// Windows APIs, which deep shares reach easily.
+func longPath(p string) string {
+	if strings.HasPrefix(p, `\\?\`) {
+		return p
+	}
+	abs, err := filepath.Abs(p)
+	if err != nil || len(abs) < maxPath {
+		return p
+	}
+	if strings.HasPrefix(abs, `\\`) {
+		return `\\?\UNC\` + abs[2:]
+	}
+	return `\\?\` + abs
+}
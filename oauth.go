@@ -0,0 +1,206 @@
+package yadloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultOAuthURL is the Yandex OAuth server.
+const DefaultOAuthURL = "https://oauth.yandex.ru"
+
+// OAuthApp is an application registered at oauth.yandex.ru with access to
+// Yandex.Disk, whose tokens the user grants with the device or the
+// authorization code flow.
+type OAuthApp struct {
+	ClientID     string
+	ClientSecret string
+	// URL is where the OAuth server is reached, DefaultOAuthURL unless
+	// pointed at a test server.
+	URL        string
+	HTTPClient *http.Client
+}
+
+// OAuthToken is a token granted to an OAuthApp. RefreshToken and Expiry
+// are empty when the server did not send them.
+type OAuthToken struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	Expiry       time.Time `json:"expiry,omitzero"`
+}
+
+// Expired reports whether the token has expired or will within d.
+func (t *OAuthToken) Expired(d time.Duration) bool {
+	return !t.Expiry.IsZero() && time.Now().Add(d).After(t.Expiry)
+}
+
+// DeviceCode is a pending device flow: the user enters UserCode at
+// VerificationURL while PollToken waits for the token.
+type DeviceCode struct {
+	DeviceCode      string
+	UserCode        string
+	VerificationURL string
+	Interval        time.Duration
+	Expiry          time.Time
+}
+
+// OAuthError is an error answer of the OAuth server, e.g.
+// {"error": "invalid_grant", "error_description": "..."}.
+type OAuthError struct {
+	StatusCode  int    `json:"-"`
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *OAuthError) Error() string {
+	msg := e.Code
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	return fmt.Sprintf("yandex oauth: %d %s", e.StatusCode, msg)
+}
+
+// ErrDeviceCodeExpired fails PollToken when the user did not enter the
+// code in time.
+var ErrDeviceCodeExpired = errors.New("yadloader: device code expired")
+
+func (a *OAuthApp) url(path string) string {
+	base := a.URL
+	if base == "" {
+		base = DefaultOAuthURL
+	}
+	return strings.TrimSuffix(base, "/") + path
+}
+
+// AuthCodeURL is the page where the user grants the app access and is
+// shown the confirmation code Exchange takes.
+func (a *OAuthApp) AuthCodeURL() string {
+	return a.url("/authorize?" + url.Values{"response_type": {"code"}, "client_id": {a.ClientID}}.Encode())
+}
+
+// post sends form to path of the OAuth server and decodes the answer into
+// v.
+func (a *OAuthApp) post(ctx context.Context, path string, form url.Values, v any) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", a.url(path), strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	hc := a.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		e := &OAuthError{StatusCode: resp.StatusCode}
+		if json.Unmarshal(body, e) != nil || e.Code == "" {
+			e.Code = http.StatusText(resp.StatusCode)
+			e.Description = excerpt(body)
+		}
+		return e
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return fmt.Errorf("yandex oauth: POST %s: %w (body: %q)", path, err, excerpt(body))
+	}
+	return nil
+}
+
+// token asks the token endpoint for a token with the grant of form.
+func (a *OAuthApp) token(ctx context.Context, form url.Values) (*OAuthToken, error) {
+	form.Set("client_id", a.ClientID)
+	if a.ClientSecret != "" {
+		form.Set("client_secret", a.ClientSecret)
+	}
+	var r struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		ExpiresIn    int64  `json:"expires_in"`
+	}
+	if err := a.post(ctx, "/token", form, &r); err != nil {
+		return nil, err
+	}
+	if r.AccessToken == "" {
+		return nil, errors.New("yandex oauth: no access token in the answer")
+	}
+	t := &OAuthToken{AccessToken: r.AccessToken, RefreshToken: r.RefreshToken}
+	if r.ExpiresIn > 0 {
+		t.Expiry = time.Now().Add(time.Duration(r.ExpiresIn) * time.Second)
+	}
+	return t, nil
+}
+
+// Exchange trades the confirmation code of AuthCodeURL for a token.
+func (a *OAuthApp) Exchange(ctx context.Context, code string) (*OAuthToken, error) {
+	return a.token(ctx, url.Values{"grant_type": {"authorization_code"}, "code": {code}})
+}
+
+// Refresh trades a refresh token for a new token. The answer may leave out
+// the refresh token, which then stays valid.
+func (a *OAuthApp) Refresh(ctx context.Context, refreshToken string) (*OAuthToken, error) {
+	return a.token(ctx, url.Values{"grant_type": {"refresh_token"}, "refresh_token": {refreshToken}})
+}
+
+// RequestDeviceCode starts the device flow.
+func (a *OAuthApp) RequestDeviceCode(ctx context.Context) (*DeviceCode, error) {
+	var r struct {
+		DeviceCode      string `json:"device_code"`
+		UserCode        string `json:"user_code"`
+		VerificationURL string `json:"verification_url"`
+		Interval        int64  `json:"interval"`
+		ExpiresIn       int64  `json:"expires_in"`
+	}
+	if err := a.post(ctx, "/device/code", url.Values{"client_id": {a.ClientID}}, &r); err != nil {
+		return nil, err
+	}
+	dc := &DeviceCode{
+		DeviceCode:      r.DeviceCode,
+		UserCode:        r.UserCode,
+		VerificationURL: r.VerificationURL,
+		Interval:        time.Duration(r.Interval) * time.Second,
+		Expiry:          time.Now().Add(time.Duration(r.ExpiresIn) * time.Second),
+	}
+	if dc.Interval <= 0 {
+		dc.Interval = 5 * time.Second
+	}
+	return dc, nil
+}
+
+// PollToken waits until the user has entered the code of dc and returns
+// the token granted, or fails once the user declined or the code expired.
+func (a *OAuthApp) PollToken(ctx context.Context, dc *DeviceCode) (*OAuthToken, error) {
+	interval := dc.Interval
+	for {
+		if err := sleep(ctx, interval); err != nil {
+			return nil, err
+		}
+		t, err := a.token(ctx, url.Values{"grant_type": {"device_code"}, "code": {dc.DeviceCode}})
+		var oe *OAuthError
+		switch {
+		case err == nil:
+			return t, nil
+		case !errors.As(err, &oe):
+			return nil, err
+		case oe.Code == "slow_down":
+			interval += 5 * time.Second
+		case oe.Code != "authorization_pending":
+			return nil, err
+		}
+		if !dc.Expiry.IsZero() && time.Now().After(dc.Expiry) {
+			return nil, ErrDeviceCodeExpired
+		}
+	}
+}
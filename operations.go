@@ -0,0 +1,127 @@
+package yadloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The status of an operation is first asked for after operationPoll, then
+// twice as long after every answer that it is not done, up to
+// maxOperationPoll.
+const (
+	operationPoll    = 500 * time.Millisecond
+	maxOperationPoll = 10 * time.Second
+)
+
+var (
+	// ErrOperationFailed is the error of an operation the API reports failed.
+	ErrOperationFailed = errors.New("yadloader: operation failed")
+	// ErrOperationTimeout is returned when an operation is not done within
+	// Config.OperationTimeout.
+	ErrOperationTimeout = errors.New("yadloader: operation timed out")
+)
+
+// Copy copies the file or folder from to the path to of the user's disk,
+// replacing what is there with overwrite. Folders are copied by the server
+// in the background; Copy waits until it is done.
+func (c *YaDiskClient) Copy(ctx context.Context, from, to string, overwrite bool) error {
+	return c.transfer(ctx, "copy", from, to, overwrite)
+}
+
+// Move moves the file or folder from to the path to of the user's disk,
+// as Copy does.
+func (c *YaDiskClient) Move(ctx context.Context, from, to string, overwrite bool) error {
+	return c.transfer(ctx, "move", from, to, overwrite)
+}
+
+// Delete moves path of the user's disk to the Trash, or deletes it for
+// good with permanently, waiting until that is done.
+func (c *YaDiskClient) Delete(ctx context.Context, path string, permanently bool) error {
+	if c.config.Token == "" {
+		return ErrTokenRequired
+	}
+	params := map[string]string{"path": path, "permanently": strconv.FormatBool(permanently)}
+	return c.operation(ctx, "DELETE", c.apiURL()+"/resources?"+c.makeParams(params))
+}
+
+func (c *YaDiskClient) transfer(ctx context.Context, op, from, to string, overwrite bool) error {
+	if c.config.Token == "" {
+		return ErrTokenRequired
+	}
+	params := map[string]string{"from": from, "path": to, "overwrite": strconv.FormatBool(overwrite)}
+	return c.operation(ctx, "POST", c.apiURL()+"/resources/"+op+"?"+c.makeParams(params))
+}
+
+// operation sends a request the API may carry out in the background, and
+// then waits for its operation to finish.
+func (c *YaDiskClient) operation(ctx context.Context, method, url string) error {
+	ctx, stop := c.withDeadline(ctx)
+	defer stop()
+	body, err := c.call(ctx, method, url)
+	if err != nil || len(body) == 0 {
+		return err
+	}
+	var link struct {
+		Href string `json:"href"`
+	}
+	if err := json.Unmarshal(body, &link); err != nil {
+		return err
+	}
+	if !c.isOperation(link.Href) {
+		// Done already; the link is to the new resource.
+		return nil
+	}
+	return c.WaitForOperation(ctx, link.Href)
+}
+
+// isOperation tells whether href, from an answer of the API, is the link to
+// the status of an operation rather than to a resource.
+func (c *YaDiskClient) isOperation(href string) bool {
+	return strings.HasPrefix(href, c.apiURL()+"/operations/")
+}
+
+// WaitForOperation asks for the status of the operation at href, the link
+// the API answers 202 Accepted with, until it has succeeded or failed,
+// waiting longer between asks as it goes on. It gives up with
+// ErrOperationTimeout after Config.OperationTimeout.
+func (c *YaDiskClient) WaitForOperation(ctx context.Context, href string) (err error) {
+	ctx, stop := c.withDeadline(ctx)
+	defer stop()
+	if timeout := c.config.OperationTimeout; timeout > 0 {
+		parent := ctx
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+		defer func() {
+			if err != nil && parent.Err() == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				err = fmt.Errorf("%w after %s: %s", ErrOperationTimeout, timeout, href)
+			}
+		}()
+	}
+	for wait := operationPoll; ; wait = min(2*wait, maxOperationPoll) {
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+		body, err := c.request(ctx, href)
+		if err != nil {
+			return err
+		}
+		var op struct {
+			Status string `json:"status"`
+		}
+		if err := json.Unmarshal(body, &op); err != nil {
+			return err
+		}
+		switch op.Status {
+		case "success":
+			return nil
+		case "failed":
+			return fmt.Errorf("%w: %s", ErrOperationFailed, href)
+		}
+	}
+}
@@ -0,0 +1,388 @@
+package yadloader
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"os"
+	"time"
+
+	"filippo.io/age"
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// Option adjusts a Config. Options passed to a single call apply to that
+// call only; settings of the underlying HTTP client, such as Wait and
+// MaxTries, are fixed when the client is created. A call given its own
+// RateLimit is paced apart from the client's other calls.
+type Option func(*Config)
+
+// with returns a client sharing the HTTP client of c whose config is a copy
+// of c's with opts applied.
+func (c *YaDiskClient) with(opts []Option) *YaDiskClient {
+	if len(opts) == 0 {
+		return c
+	}
+	cfg := *c.config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	limiter := c.limiter
+	if cfg.RateLimit != c.config.RateLimit || cfg.RateBurst != c.config.RateBurst {
+		limiter = newRateLimiter(cfg.RateLimit, cfg.RateBurst)
+	}
+	tokens := c.tokens
+	if !sameToken(&cfg, c.config) {
+		tokens = newTokens(&cfg)
+	}
+	return &YaDiskClient{client: c.client, config: &cfg, limiter: limiter, breaker: c.breaker, tokens: tokens, budget: c.budget}
+}
+
+// WithConfig replaces the whole configuration with a copy of cfg; options
+// following it adjust the copy.
+func WithConfig(cfg *Config) Option {
+	return func(c *Config) {
+		*c = *cfg
+	}
+}
+
+func WithLimit(limit int) Option {
+	return func(c *Config) {
+		c.Limit = limit
+	}
+}
+
+// Deprecated: use WithPageDelay or WithHTTPTimeout.
+func WithTimeout(d time.Duration) Option {
+	return func(c *Config) {
+		c.Timeout = d
+	}
+}
+
+func WithPageDelay(d time.Duration) Option {
+	return func(c *Config) {
+		c.PageDelay = d
+	}
+}
+
+func WithHTTPTimeout(d time.Duration) Option {
+	return func(c *Config) {
+		c.HTTPTimeout = d
+	}
+}
+
+func WithRetries(maxTries int, wait time.Duration) Option {
+	return func(c *Config) {
+		c.MaxTries = maxTries
+		c.Wait = wait
+	}
+}
+
+func WithChunkSize(size int) Option {
+	return func(c *Config) {
+		c.ChunkSize = size
+	}
+}
+
+// WithPermissions sets the modes of downloaded files and of the folders
+// created for them.
+func WithPermissions(file, dir os.FileMode) Option {
+	return func(c *Config) {
+		c.FileMode = file
+		c.DirMode = dir
+	}
+}
+
+func WithLayout(l Layout) Option {
+	return func(c *Config) {
+		c.Layout = l
+	}
+}
+
+func WithListConcurrency(n int) Option {
+	return func(c *Config) {
+		c.ListConcurrency = n
+	}
+}
+
+func WithPreviewSize(size string, crop bool) Option {
+	return func(c *Config) {
+		c.PreviewSize = size
+		c.PreviewCrop = crop
+	}
+}
+
+func WithToken(token string) Option {
+	return func(c *Config) {
+		c.Token = token
+	}
+}
+
+// WithRefreshToken sets the token granted to app, refreshing it when it
+// expires; refreshed, if not nil, receives the new tokens.
+func WithRefreshToken(app *OAuthApp, token OAuthToken, refreshed func(OAuthToken)) Option {
+	return func(c *Config) {
+		c.OAuthApp = app
+		c.Token = token.AccessToken
+		c.RefreshToken = token.RefreshToken
+		c.TokenExpiry = token.Expiry
+		c.TokenRefreshed = refreshed
+	}
+}
+
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Config) {
+		c.HTTPClient = hc
+	}
+}
+
+func WithBaseURL(u string) Option {
+	return func(c *Config) {
+		c.BaseURL = u
+	}
+}
+
+func WithTransport(rt http.RoundTripper) Option {
+	return func(c *Config) {
+		c.Transport = rt
+	}
+}
+
+func WithTransportOptions(o TransportOptions) Option {
+	return func(c *Config) {
+		c.TransportOptions = o
+	}
+}
+
+func WithLogger(l Logger) Option {
+	return func(c *Config) {
+		c.Logger = l
+	}
+}
+
+func WithConcurrency(n int) Option {
+	return func(c *Config) {
+		c.Concurrency = n
+	}
+}
+
+func WithFilter(f FilterOptions) Option {
+	return func(c *Config) {
+		c.Filter = f
+	}
+}
+
+func WithProgress(fn ProgressFunc) Option {
+	return func(c *Config) {
+		c.Progress = fn
+	}
+}
+
+func WithProgressInterval(d time.Duration) Option {
+	return func(c *Config) {
+		c.ProgressInterval = d
+	}
+}
+
+func WithIgnoreFreeSpace(ignore bool) Option {
+	return func(c *Config) {
+		c.IgnoreFreeSpace = ignore
+	}
+}
+
+func WithOverwrite(p OverwritePolicy) Option {
+	return func(c *Config) {
+		c.Overwrite = p
+	}
+}
+
+func WithErrorPolicy(p ErrorPolicy) Option {
+	return func(c *Config) {
+		c.ErrorPolicy = p
+	}
+}
+
+func WithRetryPasses(n int) Option {
+	return func(c *Config) {
+		c.RetryPasses = n
+	}
+}
+
+func WithHooks(h Hooks) Option {
+	return func(c *Config) {
+		c.Hooks = h
+	}
+}
+
+func WithCheckRetry(fn retryablehttp.CheckRetry) Option {
+	return func(c *Config) {
+		c.CheckRetry = fn
+	}
+}
+
+func WithBackoff(fn retryablehttp.Backoff) Option {
+	return func(c *Config) {
+		c.Backoff = fn
+	}
+}
+
+// WithCircuitBreaker pauses all requests for cooldown after threshold
+// consecutive server or network failures. It belongs to the client; passed
+// to a single call it has no effect.
+func WithCircuitBreaker(threshold int, cooldown time.Duration) Option {
+	return func(c *Config) {
+		c.BreakerThreshold = threshold
+		c.BreakerCooldown = cooldown
+	}
+}
+
+// WithRetryBudget caps the retries of the client at retries, and the time
+// it waits before them at wait; zero leaves either unlimited. It belongs to
+// the client; passed to a single call it has no effect.
+func WithRetryBudget(retries int, wait time.Duration) Option {
+	return func(c *Config) {
+		c.RetryBudget = retries
+		c.RetryBudgetTime = wait
+	}
+}
+
+// WithRateLimit caps API calls at rps per second with bursts of burst; zero
+// rps disables the limit.
+func WithRateLimit(rps float64, burst int) Option {
+	return func(c *Config) {
+		c.RateLimit = rps
+		c.RateBurst = burst
+	}
+}
+
+// WithDebug logs HTTP traffic to the Logger, including up to body bytes of
+// each API response; a negative body logs them whole.
+func WithDebug(body int) Option {
+	return func(c *Config) {
+		c.Debug = true
+		c.DebugBody = body
+	}
+}
+
+func WithPerFileTimeout(d time.Duration) Option {
+	return func(c *Config) {
+		c.PerFileTimeout = d
+	}
+}
+
+func WithOperationTimeout(d time.Duration) Option {
+	return func(c *Config) {
+		c.OperationTimeout = d
+	}
+}
+
+func WithPipeline(on bool) Option {
+	return func(c *Config) {
+		c.Pipeline = on
+	}
+}
+
+func WithDigests(on bool) Option {
+	return func(c *Config) {
+		c.Digests = on
+	}
+}
+
+func WithAntivirusPolicy(p AntivirusPolicy) Option {
+	return func(c *Config) {
+		c.Antivirus = p
+	}
+}
+
+func WithServedNames(on bool) Option {
+	return func(c *Config) {
+		c.ServedNames = on
+	}
+}
+
+func WithPreallocate(on bool) Option {
+	return func(c *Config) {
+		c.Preallocate = on
+	}
+}
+
+func WithSyncWrites(on bool) Option {
+	return func(c *Config) {
+		c.SyncWrites = on
+	}
+}
+
+func WithCompression(c Compression) Option {
+	return func(cfg *Config) {
+		cfg.Compression = c
+	}
+}
+
+func WithRecipients(r ...age.Recipient) Option {
+	return func(c *Config) {
+		c.Recipients = r
+	}
+}
+
+// WithCAS stores the downloaded files in the object folder objects, see
+// Config.CASObjects.
+func WithCAS(objects string, tree CASTree) Option {
+	return func(c *Config) {
+		c.CASObjects = objects
+		c.CASTree = tree
+	}
+}
+
+func WithSniffTypes(on bool) Option {
+	return func(c *Config) {
+		c.SniffTypes = on
+	}
+}
+
+func WithEmptyFolders(on bool) Option {
+	return func(c *Config) {
+		c.EmptyFolders = on
+	}
+}
+
+func WithFolderTimes(on bool) Option {
+	return func(c *Config) {
+		c.FolderTimes = on
+	}
+}
+
+func WithXattrs(on bool) Option {
+	return func(c *Config) {
+		c.Xattrs = on
+	}
+}
+
+// WithDeadline stops listing and downloading at t.
+func WithDeadline(t time.Time) Option {
+	return func(c *Config) {
+		c.Deadline = t
+	}
+}
+
+// WithTLSConfig sets the TLS config of API and download connections.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(c *Config) {
+		c.TLSConfig = cfg
+	}
+}
+
+// WithRootCAs trusts the certificates in pool instead of the system ones,
+// e.g. the CA of a TLS-intercepting proxy.
+func WithRootCAs(pool *x509.CertPool) Option {
+	return func(c *Config) {
+		c.tlsConfig().RootCAs = pool
+	}
+}
+
+// WithInsecureSkipVerify turns off certificate verification. It is meant
+// for debugging only.
+func WithInsecureSkipVerify() Option {
+	return func(c *Config) {
+		c.tlsConfig().InsecureSkipVerify = true
+	}
+}
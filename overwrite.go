@@ -0,0 +1,79 @@
+package yadloader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OverwritePolicy decides what happens when a destination file exists.
+type OverwritePolicy int
+
+const (
+	OverwriteAlways OverwritePolicy = iota
+	OverwriteSkip
+	// OverwriteRenameNew keeps the existing file and saves the new one as
+	// "name (1).ext", "name (2).ext" and so on.
+	OverwriteRenameNew
+	OverwriteError
+)
+
+var ErrFileExists = errors.New("destination file exists")
+
+var overwriteNames = map[OverwritePolicy]string{
+	OverwriteAlways:    "overwrite",
+	OverwriteSkip:      "skip",
+	OverwriteRenameNew: "rename",
+	OverwriteError:     "error",
+}
+
+func (p OverwritePolicy) String() string {
+	if s, ok := overwriteNames[p]; ok {
+		return s
+	}
+	return fmt.Sprintf("OverwritePolicy(%d)", int(p))
+}
+
+func ParseOverwritePolicy(s string) (OverwritePolicy, error) {
+	for p, name := range overwriteNames {
+		if strings.EqualFold(s, name) {
+			return p, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown overwrite policy %q", s)
+}
+
+// applyOverwrite returns the path to write to, or skip when the file must
+// be left alone.
+func (c *YaDiskClient) applyOverwrite(target string) (path string, skip bool, err error) {
+	if _, err := os.Lstat(longPath(target)); errors.Is(err, os.ErrNotExist) {
+		return target, false, nil
+	} else if err != nil {
+		return "", false, err
+	}
+
+	switch c.config.Overwrite {
+	case OverwriteSkip:
+		return target, true, nil
+	case OverwriteRenameNew:
+		return freeName(target)
+	case OverwriteError:
+		return "", false, fmt.Errorf("%s: %w", target, ErrFileExists)
+	}
+	return target, false, nil
+}
+
+func freeName(target string) (string, bool, error) {
+	ext := filepath.Ext(target)
+	base := strings.TrimSuffix(target, ext)
+	for i := 1; ; i++ {
+		candidate := fmt.Sprintf("%s (%d)%s", base, i, ext)
+		if _, err := os.Lstat(longPath(candidate)); errors.Is(err, os.ErrNotExist) {
+			return candidate, false, nil
+		} else if err != nil {
+			return "", false, err
+		}
+	}
+}
@@ -0,0 +1,15 @@
+//go:build linux
+
+package yadloader
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// preallocate reserves size bytes for f without changing its size, so a
+// part file still tells how much of it was downloaded.
+func preallocate(f *os.File, size int64) error {
+	return unix.Fallocate(int(f.Fd()), unix.FALLOC_FL_KEEP_SIZE, 0, size)
+}
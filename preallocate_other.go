@@ -0,0 +1,12 @@
+//go:build !linux && !darwin
+
+package yadloader
+
+import (
+	"errors"
+	"os"
+)
+
+func preallocate(*os.File, int64) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,63 @@
+package yadloader
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"golang.org/x/time/rate"
+)
+
+// minRate is the slowest an adaptive limiter backs off to, in requests per
+// second.
+const minRate = 0.2
+
+// rateLimiter is a token bucket for API calls that halves its rate on HTTP
+// 429 and recovers gradually as requests succeed.
+type rateLimiter struct {
+	mu      sync.Mutex
+	max     rate.Limit
+	limiter *rate.Limiter
+}
+
+// newRateLimiter returns nil, which never waits, when rps is not positive.
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	if rps <= 0 {
+		return nil
+	}
+	return &rateLimiter{max: rate.Limit(rps), limiter: rate.NewLimiter(rate.Limit(rps), max(burst, 1))}
+}
+
+func (r *rateLimiter) wait(ctx context.Context) error {
+	if r == nil {
+		return nil
+	}
+	return r.limiter.Wait(ctx)
+}
+
+// observe adapts the rate to an API response.
+func (r *rateLimiter) observe(status int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cur := r.limiter.Limit()
+	switch {
+	case status == http.StatusTooManyRequests:
+		// Never faster than the configured rate, even below minRate.
+		r.limiter.SetLimit(max(cur/2, min(minRate, r.max)))
+	case status < 400 && cur < r.max:
+		r.limiter.SetLimit(min(cur+r.max/10, r.max))
+	}
+}
+
+type limiterKey struct{}
+
+// limiterFrom returns the limiter request put in ctx, or fallback.
+func limiterFrom(ctx context.Context, fallback *rateLimiter) *rateLimiter {
+	if r, ok := ctx.Value(limiterKey{}).(*rateLimiter); ok {
+		return r
+	}
+	return fallback
+}
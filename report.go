@@ -0,0 +1,69 @@
+package yadloader
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Report summarizes a DownloadFiles or DownloadTree run.
+type Report struct {
+	Files      int           `json:"files"`
+	Downloaded int           `json:"downloaded"`
+	Skipped    int           `json:"skipped"`
+	Failed     int           `json:"failed"`
+	Retried    int           `json:"retried"`
+	Bytes      int64         `json:"bytes"`
+	Started    time.Time     `json:"started"`
+	Duration   time.Duration `json:"-"`
+	// Failures lists every failed file when Config.ErrorPolicy is Collect.
+	Failures []*FileError `json:"failures,omitempty"`
+	// Digests are the checksums of the downloaded files by path, with
+	// Config.Digests.
+	Digests map[string]Digests `json:"digests,omitempty"`
+	// TypeMismatches lists the files whose content does not look like
+	// their media type or extension, with Config.SniffTypes.
+	TypeMismatches []TypeMismatch `json:"type_mismatches,omitempty"`
+	// Linked is how many files the download folder shows with
+	// Config.CASObjects.
+	Linked int `json:"linked,omitempty"`
+	// ServedNames lists the files whose download was sent under another
+	// name than the listing gives.
+	ServedNames []ServedName `json:"served_names,omitempty"`
+}
+
+// ServedName is a file whose Content-Disposition name differs from its
+// listed Name. Dest is where it was saved.
+type ServedName struct {
+	Path   string `json:"path"`
+	Name   string `json:"name"`
+	Served string `json:"served"`
+	Dest   string `json:"dest"`
+}
+
+// Retryable returns the failed files that may succeed if tried again.
+func (r Report) Retryable() []DiskFile {
+	var files []DiskFile
+	for _, f := range r.Failures {
+		if IsRetryable(f.Err) {
+			files = append(files, f.File)
+		}
+	}
+	return files
+}
+
+// Throughput returns the average transfer rate in bytes per second.
+func (r Report) Throughput() float64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return float64(r.Bytes) / r.Duration.Seconds()
+}
+
+func (r Report) MarshalJSON() ([]byte, error) {
+	type report Report
+	return json.Marshal(struct {
+		report
+		DurationSeconds float64 `json:"duration_seconds"`
+		BytesPerSecond  float64 `json:"bytes_per_second"`
+	}{report(r), r.Duration.Seconds(), r.Throughput()})
+}
@@ -0,0 +1,71 @@
+package yadloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"path"
+)
+
+// SaveToDisk copies path of the public resource link into the folder
+// savePath of the user's disk, by default its Downloads folder, on the
+// server without downloading it. It waits until the copy is done and
+// returns its path on the disk. The copy is named like the resource;
+// download it with ListDisk or DownloadTree with an empty link.
+func (c *YaDiskClient) SaveToDisk(ctx context.Context, link, path, savePath string) (string, error) {
+	ctx, stop := c.withDeadline(ctx)
+	defer stop()
+	link, path, err := resolveLink(link, path)
+	if err != nil {
+		return "", err
+	}
+	if link == "" {
+		return "", errors.New("yadloader: no public link to save")
+	}
+	if c.config.Token == "" {
+		return "", ErrTokenRequired
+	}
+	meta, err := c.GetMeta(ctx, link, path)
+	if err != nil {
+		return "", err
+	}
+
+	params := map[string]string{"public_key": link, "name": meta.Name}
+	if path != "" && path != "/" {
+		params["path"] = path
+	}
+	if savePath == "" {
+		if savePath, err = c.downloadsFolder(ctx); err != nil {
+			return "", err
+		}
+	}
+	params["save_path"] = savePath
+	body, err := c.call(ctx, "POST", c.apiURL()+"/public/resources/save-to-disk?"+c.makeParams(params))
+	if err != nil {
+		return "", err
+	}
+	var saved struct {
+		Href string `json:"href"`
+	}
+	if err := json.Unmarshal(body, &saved); err != nil {
+		return "", err
+	}
+	if c.isOperation(saved.Href) {
+		if err := c.WaitForOperation(ctx, saved.Href); err != nil {
+			return "", err
+		}
+		return joinDisk(savePath, meta.Name), nil
+	}
+	// The link is to the copy.
+	u, err := url.Parse(saved.Href)
+	if err != nil {
+		return "", err
+	}
+	return diskPath(u.Query().Get("path")), nil
+}
+
+// joinDisk joins a path of the disk, with or without "disk:", and name.
+func joinDisk(dir, name string) string {
+	return path.Join("/", diskPath(dir), name)
+}
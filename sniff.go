@@ -0,0 +1,78 @@
+package yadloader
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// sniffLen is how much of a file Config.SniffTypes looks at.
+const sniffLen = 512
+
+// TypeMismatch is a downloaded file whose content does not look like what
+// its media type or extension says, see Config.SniffTypes. Expected is the
+// MIME type of the extension and Detected that of the content.
+type TypeMismatch struct {
+	Path      string `json:"path"`
+	MediaType string `json:"media_type"`
+	Expected  string `json:"expected,omitempty"`
+	Detected  string `json:"detected"`
+}
+
+// typeMismatch compares the first bytes of file with its media type and
+// extension. Only content with a telling signature is judged: text, zip
+// containers such as office documents and MP4, which holds audio as well,
+// could be many things.
+func typeMismatch(file DiskFile, head []byte) (TypeMismatch, bool) {
+	detected := sniffType(head)
+	kind := mediaKind(detected)
+	if kind == "" {
+		return TypeMismatch{}, false
+	}
+	expected, _, _ := strings.Cut(mime.TypeByExtension(strings.ToLower(path.Ext(file.Name))), ";")
+	m := TypeMismatch{Path: file.Path, MediaType: file.MediaType, Expected: expected, Detected: detected}
+	if file.MediaType != "" && !strings.EqualFold(file.MediaType, kind) {
+		return m, true
+	}
+	// A .jpg holding a PNG is still an image, but not the one it claims.
+	if kind == "image" && strings.HasPrefix(expected, "image/") && expected != detected {
+		return m, true
+	}
+	return TypeMismatch{}, false
+}
+
+// sniffType is http.DetectContentType without parameters, telling Windows
+// and ELF executables apart too.
+func sniffType(head []byte) string {
+	switch {
+	case bytes.HasPrefix(head, []byte("MZ")):
+		return "application/x-msdownload"
+	case bytes.HasPrefix(head, []byte("\x7fELF")):
+		return "application/x-elf"
+	}
+	t, _, _ := strings.Cut(http.DetectContentType(head), ";")
+	return t
+}
+
+// mediaKind maps a detected MIME type to the media type the API would list
+// it with, or "" if the type says too little.
+func mediaKind(t string) string {
+	switch t {
+	case "video/mp4", "video/webm", "application/ogg":
+		return ""
+	case "application/pdf":
+		return "document"
+	case "application/x-gzip", "application/x-rar-compressed", "application/x-7z-compressed":
+		return "compressed"
+	case "application/x-msdownload", "application/x-elf":
+		return "executable"
+	}
+	major, _, _ := strings.Cut(t, "/")
+	switch major {
+	case "image", "video", "audio":
+		return major
+	}
+	return ""
+}
@@ -0,0 +1,103 @@
+package yadloader
+
+import (
+	"os"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+// speedSmoothing weighs the latest sample in Speed.Average.
+const speedSmoothing = 0.2
+
+// Speed is a transfer rate in bytes per second.
+type Speed struct {
+	// Current is the rate over the last Config.ProgressInterval.
+	Current float64
+	// Average is a moving average that evens out short stalls and bursts.
+	Average float64
+}
+
+func (s *Speed) sample(bytes int64, d time.Duration, first bool) {
+	s.Current = float64(bytes) / d.Seconds()
+	if first {
+		s.Average = s.Current
+		return
+	}
+	s.Average += speedSmoothing * (s.Current - s.Average)
+}
+
+// meter tracks a running download for EventProgress.
+type meter struct {
+	file     DiskFile
+	dest     string
+	started  time.Time
+	received atomic.Int64
+
+	last    int64
+	samples int
+	speed   Speed
+}
+
+// meteredFile counts what is written to a download's part file. Seek and
+// Truncate stay available for rewinding.
+type meteredFile struct {
+	*os.File
+	n *atomic.Int64
+}
+
+func (f *meteredFile) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	f.n.Add(int64(n))
+	return n, err
+}
+
+// speedometer samples the running downloads of a pass. It is guarded by the
+// mutex of the pass.
+type speedometer struct {
+	active  []*meter
+	last    int64
+	samples int
+	total   Speed
+}
+
+func (s *speedometer) start(file DiskFile, dest string) *meter {
+	m := &meter{file: file, dest: dest, started: time.Now()}
+	s.active = append(s.active, m)
+	return m
+}
+
+func (s *speedometer) stop(m *meter) {
+	s.active = slices.DeleteFunc(s.active, func(a *meter) bool { return a == m })
+}
+
+// sample returns an EventProgress for every running download. done is what
+// earlier and finished downloads received, d the time since the last sample.
+func (s *speedometer) sample(done int64, d time.Duration) []Event {
+	now := time.Now()
+	total := done
+	events := make([]Event, 0, len(s.active))
+	for _, m := range s.active {
+		n := m.received.Load()
+		total += n
+		m.speed.sample(n-m.last, d, m.samples == 0)
+		m.last = n
+		m.samples++
+		events = append(events, Event{
+			Type:    EventProgress,
+			File:    m.file,
+			Dest:    m.dest,
+			Bytes:   n,
+			Elapsed: now.Sub(m.started),
+			Speed:   m.speed,
+		})
+	}
+	s.total.sample(max(total-s.last, 0), d, s.samples == 0)
+	s.last = total
+	s.samples++
+	for i := range events {
+		events[i].Total = s.total
+		events[i].TotalBytes = total
+	}
+	return events
+}
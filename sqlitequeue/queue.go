@@ -0,0 +1,214 @@
+// Package sqlitequeue stores the download queue of a run in SQLite, for
+// shares too large for the JSON journal. It uses a pure-Go driver.
+package sqlitequeue
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/brandquad/yadloader-go"
+	_ "modernc.org/sqlite"
+)
+
+const schema = `
+CREATE TABLE IF NOT EXISTS meta (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS files (
+	path       TEXT PRIMARY KEY,
+	size       INTEGER NOT NULL,
+	md5        TEXT NOT NULL,
+	sha256     TEXT NOT NULL,
+	data       TEXT NOT NULL,
+	status     TEXT NOT NULL,
+	attempts   INTEGER NOT NULL DEFAULT 0,
+	last_error TEXT NOT NULL DEFAULT '',
+	updated_at TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS files_status ON files (status);
+`
+
+type Queue struct {
+	db *sql.DB
+}
+
+func Open(filename string) (*Queue, error) {
+	db, err := sql.Open("sqlite", "file:"+filename+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_pragma=synchronous(NORMAL)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite serializes writers anyway; a single connection avoids SQLITE_BUSY.
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(schema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init %s: %w", filename, err)
+	}
+	return &Queue{db: db}, nil
+}
+
+func (q *Queue) Close() error {
+	return q.db.Close()
+}
+
+// Init replaces the queue contents with files discovered for link and path.
+// The last run time is kept if link and path are unchanged.
+func (q *Queue) Init(link, path string, files []yadloader.DiskFile) error {
+	oldLink, oldPath, err := q.Source()
+	if err != nil {
+		return err
+	}
+	clear := `DELETE FROM files; DELETE FROM meta;`
+	if oldLink == link && oldPath == path {
+		clear = `DELETE FROM files; DELETE FROM meta WHERE key != 'last_run';`
+	}
+
+	tx, err := q.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(clear); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`INSERT INTO meta (key, value) VALUES ('link', ?), ('path', ?)`, link, path); err != nil {
+		return err
+	}
+
+	stmt, err := tx.Prepare(`INSERT INTO files (path, size, md5, sha256, data, status, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	for _, f := range files {
+		data, err := json.Marshal(f)
+		if err != nil {
+			return err
+		}
+		if _, err := stmt.Exec(f.Path, f.Size, f.MD5, f.SHA256, string(data), yadloader.StatusPending, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Source returns the link and path the queue was initialized with. Both are
+// empty for a new database.
+func (q *Queue) Source() (link, path string, err error) {
+	rows, err := q.db.Query(`SELECT key, value FROM meta`)
+	if err != nil {
+		return "", "", err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var k, v string
+		if err := rows.Scan(&k, &v); err != nil {
+			return "", "", err
+		}
+		switch k {
+		case "link":
+			link = v
+		case "path":
+			path = v
+		}
+	}
+	return link, path, rows.Err()
+}
+
+// LastRun returns the time set by SetLastRun, zero if there is none.
+func (q *Queue) LastRun() (time.Time, error) {
+	var v string
+	err := q.db.QueryRow(`SELECT value FROM meta WHERE key = 'last_run'`).Scan(&v)
+	if errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Parse(time.RFC3339Nano, v)
+}
+
+// SetLastRun records when the last run that downloaded everything started.
+func (q *Queue) SetLastRun(t time.Time) error {
+	_, err := q.db.Exec(
+		`INSERT INTO meta (key, value) VALUES ('last_run', ?) ON CONFLICT (key) DO UPDATE SET value = excluded.value`,
+		t.UTC().Format(time.RFC3339Nano),
+	)
+	return err
+}
+
+func (q *Queue) Files() ([]yadloader.DiskFile, error) {
+	return q.query(`SELECT data FROM files ORDER BY rowid`)
+}
+
+func (q *Queue) Pending() ([]yadloader.DiskFile, error) {
+	return q.query(`SELECT data FROM files WHERE status != ? ORDER BY rowid`, yadloader.StatusDone)
+}
+
+func (q *Queue) query(query string, args ...any) ([]yadloader.DiskFile, error) {
+	rows, err := q.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []yadloader.DiskFile
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var f yadloader.DiskFile
+		if err := json.Unmarshal([]byte(data), &f); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// Mark records the outcome of a download attempt. Finished and failed
+// attempts are counted; cause is kept as the last error of the file.
+func (q *Queue) Mark(path string, status yadloader.FileStatus, cause error) error {
+	var msg string
+	if cause != nil {
+		msg = cause.Error()
+	}
+	attempt := 0
+	if status != yadloader.StatusPending {
+		attempt = 1
+	}
+	_, err := q.db.Exec(
+		`UPDATE files SET status = ?, attempts = attempts + ?, last_error = ?, updated_at = ? WHERE path = ?`,
+		status, attempt, msg, time.Now().UTC().Format(time.RFC3339), path,
+	)
+	return err
+}
+
+// Counts returns the number of files per status.
+func (q *Queue) Counts() (map[yadloader.FileStatus]int, error) {
+	rows, err := q.db.Query(`SELECT status, COUNT(*) FROM files GROUP BY status`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[yadloader.FileStatus]int)
+	for rows.Next() {
+		var s yadloader.FileStatus
+		var n int
+		if err := rows.Scan(&s, &n); err != nil {
+			return nil, err
+		}
+		counts[s] = n
+	}
+	return counts, rows.Err()
+}
@@ -1,5 +1,10 @@
 package yadloader
 
+import (
+	"strings"
+	"time"
+)
+
 type entryType string
 
 const (
@@ -11,18 +16,48 @@ type response struct {
 	Path       string    `json:"path"`
 	Type       entryType `json:"type"`
 	Name       string    `json:"name"`
-	Created    string    `json:"created"`
-	Modified   string    `json:"modified"`
+	Created    time.Time `json:"created"`
+	Modified   time.Time `json:"modified"`
 	Size       *int64    `json:"size"`
 	MD5        *string   `json:"md5"`
 	SHA256     *string   `json:"sha256"`
 	PublicKey  string    `json:"public_key"`
+	PublicURL  string    `json:"public_url"`
 	MediaType  *string   `json:"media_type"`
 	ResourceId string    `json:"resource_id"`
+	Antivirus  *string   `json:"antivirus_status"`
 	File       *string   `json:"file"`
+	Preview    *string   `json:"preview"`
+	Exif       *exif     `json:"exif"`
 	Embedded   *embedded `json:"_embedded"`
+	// OriginPath and Deleted are set for resources in the Trash.
+	OriginPath string    `json:"origin_path"`
+	Deleted    time.Time `json:"deleted"`
+}
+
+type exif struct {
+	DateTime time.Time `json:"date_time"`
+}
+
+// itemFields are the item attributes DiskFile is built from; listings ask
+// the API for these only.
+var itemFields = []string{
+	"type", "name", "path", "size", "file", "md5", "sha256", "media_type", "created", "modified",
+	"exif", "resource_id", "antivirus_status",
+}
+
+// listFields also asks for the attributes of the listed resource itself, in
+// case it is a file rather than a folder.
+func listFields(extra ...string) string {
+	fields := make([]string, 0, 2*(len(itemFields)+len(extra)))
+	for _, f := range append(itemFields, extra...) {
+		fields = append(fields, f, "_embedded.items."+f)
+	}
+	return strings.Join(fields, ",")
 }
 
+var metaFields = "type,name,path,size,resource_id,public_key,public_url,media_type,file,md5,sha256,antivirus_status,created,modified,exif,_embedded.total"
+
 type embedded struct {
 	Path   string     `json:"path"`
 	Limit  int        `json:"limit"`
@@ -32,13 +67,157 @@ type embedded struct {
 	Items  []response `json:"items"`
 }
 
-type diskFile struct {
-	Name     string `json:"name"`
-	Path     string `json:"path"`
-	Size     int64  `json:"size"`
-	File     string `json:"file"`
-	MD5      string `json:"md5"`
-	SHA256   string `json:"sha256"`
-	Created  string `json:"created"`
-	Modified string `json:"modified"`
+// Resource is the metadata of a single file or folder.
+type Resource struct {
+	Type       entryType `json:"type"`
+	Name       string    `json:"name"`
+	Path       string    `json:"path"`
+	Size       int64     `json:"size"`
+	ResourceID string    `json:"resource_id"`
+	PublicKey  string    `json:"public_key,omitempty"`
+	PublicURL  string    `json:"public_url,omitempty"`
+	MediaType  string    `json:"media_type,omitempty"`
+	File       string    `json:"file,omitempty"`
+	MD5        string    `json:"md5,omitempty"`
+	SHA256     string    `json:"sha256,omitempty"`
+	// AntivirusStatus is the verdict of the Yandex.Disk antivirus on a
+	// file, such as "clean" or "infected".
+	AntivirusStatus string    `json:"antivirus_status,omitempty"`
+	Created         time.Time `json:"created"`
+	Modified        time.Time `json:"modified"`
+	// Preview is the preview link of a file with Config.PreviewSize, and
+	// Taken when a photo was taken, as in DiskFile.
+	Preview string    `json:"preview,omitempty"`
+	Taken   time.Time `json:"taken,omitzero"`
+	// Items is the number of direct children of a folder.
+	Items int `json:"items"`
+}
+
+func (r Resource) IsDir() bool {
+	return r.Type == DIR
+}
+
+func (r Resource) IsFile() bool {
+	return r.Type == FILE
+}
+
+// DiskFile converts file metadata to a DiskFile. The root of a single-file
+// public link has path "/", so the file is placed at "/<name>".
+func (r Resource) DiskFile() DiskFile {
+	path := r.Path
+	if path == "" || path == "/" {
+		path = "/" + r.Name
+	}
+	return DiskFile{
+		Name:            r.Name,
+		Path:            path,
+		Size:            r.Size,
+		File:            r.File,
+		MD5:             r.MD5,
+		SHA256:          r.SHA256,
+		MediaType:       r.MediaType,
+		PublicKey:       r.PublicKey,
+		ResourceID:      r.ResourceID,
+		AntivirusStatus: r.AntivirusStatus,
+		Created:         r.Created,
+		Modified:        r.Modified,
+		Preview:         r.Preview,
+		Taken:           r.Taken,
+	}
+}
+
+func newResource(i response) Resource {
+	f := newDiskFile(i)
+	r := Resource{
+		Type:            i.Type,
+		Name:            i.Name,
+		Path:            f.Path,
+		Size:            f.Size,
+		ResourceID:      i.ResourceId,
+		PublicKey:       i.PublicKey,
+		PublicURL:       i.PublicURL,
+		MediaType:       f.MediaType,
+		File:            f.File,
+		MD5:             f.MD5,
+		SHA256:          f.SHA256,
+		AntivirusStatus: f.AntivirusStatus,
+		Created:         i.Created,
+		Modified:        i.Modified,
+		Preview:         f.Preview,
+		Taken:           f.Taken,
+	}
+	if i.Embedded != nil {
+		r.Items = i.Embedded.Total
+	}
+	return r
+}
+
+type link struct {
+	Href      string `json:"href"`
+	Method    string `json:"method"`
+	Templated bool   `json:"templated"`
+}
+
+type DiskFile struct {
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Size      int64  `json:"size"`
+	File      string `json:"file"`
+	MD5       string `json:"md5"`
+	SHA256    string `json:"sha256"`
+	MediaType string `json:"media_type"`
+	Preview   string `json:"preview,omitempty"`
+	// PublicKey is the public resource the file was listed from, empty for
+	// files of the user's own disk.
+	PublicKey  string `json:"public_key,omitempty"`
+	ResourceID string `json:"resource_id,omitempty"`
+	// AntivirusStatus is the verdict of the Yandex.Disk antivirus, such as
+	// "clean" or "infected"; see AntivirusPolicy.
+	AntivirusStatus string    `json:"antivirus_status,omitempty"`
+	Created         time.Time `json:"created"`
+	Modified        time.Time `json:"modified"`
+	// Taken is when a photo was taken according to its EXIF data, as far as
+	// Yandex.Disk knows it.
+	Taken time.Time `json:"taken,omitzero"`
+}
+
+// diskPath strips the "disk:" scheme the private API prefixes paths with,
+// so files of public shares and of the user's disk share one layout.
+func diskPath(p string) string {
+	return strings.TrimPrefix(p, "disk:")
+}
+
+func newDiskFile(i response) DiskFile {
+	f := DiskFile{
+		Name:       i.Name,
+		Path:       diskPath(i.Path),
+		ResourceID: i.ResourceId,
+		Created:    i.Created,
+		Modified:   i.Modified,
+	}
+	if i.Size != nil {
+		f.Size = *i.Size
+	}
+	if i.File != nil {
+		f.File = *i.File
+	}
+	if i.MD5 != nil {
+		f.MD5 = *i.MD5
+	}
+	if i.SHA256 != nil {
+		f.SHA256 = *i.SHA256
+	}
+	if i.MediaType != nil {
+		f.MediaType = *i.MediaType
+	}
+	if i.Preview != nil {
+		f.Preview = *i.Preview
+	}
+	if i.Exif != nil {
+		f.Taken = i.Exif.DateTime
+	}
+	if i.Antivirus != nil {
+		f.AntivirusStatus = *i.Antivirus
+	}
+	return f
 }
@@ -0,0 +1,15 @@
+//go:build !windows
+
+package yadloader
+
+import "os"
+
+// syncDir flushes the entries of dir, such as a file just renamed into it.
+func syncDir(dir string) error {
+	f, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return f.Sync()
+}
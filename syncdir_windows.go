@@ -0,0 +1,9 @@
+//go:build windows
+
+package yadloader
+
+// syncDir does nothing on Windows, where folders cannot be flushed and
+// renames are made durable by the filesystem journal.
+func syncDir(string) error {
+	return nil
+}
@@ -0,0 +1,89 @@
+package yadloader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// tokenLeeway is how long before its expiry a token is refreshed.
+const tokenLeeway = time.Minute
+
+// tokens is the OAuth token of a client and the clients derived from it
+// with per-call options, refreshed with Config.RefreshToken when it
+// expires or the API rejects it.
+type tokens struct {
+	mu        sync.Mutex
+	token     OAuthToken
+	app       *OAuthApp
+	refreshed func(OAuthToken)
+}
+
+func newTokens(c *Config) *tokens {
+	return &tokens{
+		token:     OAuthToken{AccessToken: c.Token, RefreshToken: c.RefreshToken, Expiry: c.TokenExpiry},
+		app:       c.OAuthApp,
+		refreshed: c.TokenRefreshed,
+	}
+}
+
+// sameToken reports whether a and b start with the same token, so that a
+// client derived by with can share the tokens of its parent.
+func sameToken(a, b *Config) bool {
+	return a.Token == b.Token && a.RefreshToken == b.RefreshToken && a.OAuthApp == b.OAuthApp
+}
+
+func (t *tokens) canRefresh() bool {
+	return t.app != nil && t.token.RefreshToken != ""
+}
+
+// get returns the access token, refreshing it first when it is about to
+// expire.
+func (t *tokens) get(ctx context.Context) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.canRefresh() && t.token.Expired(tokenLeeway) {
+		if err := t.refreshLocked(ctx); err != nil {
+			return "", err
+		}
+	}
+	return t.token.AccessToken, nil
+}
+
+// refresh replaces the access token stale the API rejected. Concurrent
+// requests rejected with the same token wait for one refresh and share its
+// result.
+func (t *tokens) refresh(ctx context.Context, stale string) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.token.AccessToken != stale {
+		return true, nil
+	}
+	if !t.canRefresh() {
+		return false, nil
+	}
+	return true, t.refreshLocked(ctx)
+}
+
+func (t *tokens) refreshLocked(ctx context.Context) error {
+	next, err := t.app.Refresh(ctx, t.token.RefreshToken)
+	if err != nil {
+		return err
+	}
+	if next.RefreshToken == "" {
+		next.RefreshToken = t.token.RefreshToken
+	}
+	t.token = *next
+	if t.refreshed != nil {
+		t.refreshed(*next)
+	}
+	return nil
+}
+
+// isUnauthorized reports whether the API rejected the token of a request.
+func isUnauthorized(err error) bool {
+	var e *APIError
+	return errors.As(err, &e) && e.StatusCode == http.StatusUnauthorized
+}
@@ -0,0 +1,83 @@
+package yadloader
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// TransportOptions tune the connections of the HTTP client. Zero fields
+// keep the defaults.
+type TransportOptions struct {
+	// MaxIdleConnsPerHost should be at least Concurrency, so parallel
+	// downloads from the same CDN host reuse their connections.
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	TLSHandshakeTimeout time.Duration
+	// KeepAlive is the TCP keep-alive period; negative disables it.
+	KeepAlive  time.Duration
+	ForceHTTP2 bool
+}
+
+func (o TransportOptions) isZero() bool {
+	return o == TransportOptions{}
+}
+
+// apply returns a copy of t with the options set.
+func (o TransportOptions) apply(t *http.Transport) *http.Transport {
+	t = t.Clone()
+	if o.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = o.MaxIdleConnsPerHost
+		t.MaxIdleConns = max(t.MaxIdleConns, o.MaxIdleConnsPerHost)
+	}
+	if o.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = o.IdleConnTimeout
+	}
+	if o.TLSHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = o.TLSHandshakeTimeout
+	}
+	if o.KeepAlive != 0 {
+		dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: o.KeepAlive}
+		t.DialContext = dialer.DialContext
+	}
+	if o.ForceHTTP2 {
+		t.ForceAttemptHTTP2 = true
+	}
+	return t
+}
+
+// tuneTransport applies the transport options and TLS config of c to hc's
+// transport, which has to be an *http.Transport.
+func (c *YaDiskClient) tuneTransport(hc *http.Client) *http.Client {
+	if c.config.TransportOptions.isZero() && c.config.TLSConfig == nil {
+		return hc
+	}
+	rt := hc.Transport
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	t, ok := rt.(*http.Transport)
+	if !ok {
+		c.logf("warning: transport options ignored for custom transport %T", rt)
+		return hc
+	}
+	t = c.config.TransportOptions.apply(t)
+	if c.config.TLSConfig != nil {
+		t.TLSClientConfig = c.config.TLSConfig.Clone()
+	}
+	tuned := *hc
+	tuned.Transport = t
+	return &tuned
+}
+
+// tlsConfig returns the TLS config of c for changing, creating it if needed.
+func (c *Config) tlsConfig() *tls.Config {
+	if c.TLSConfig == nil {
+		c.TLSConfig = &tls.Config{}
+	} else {
+		// Options must not change a config the caller still holds.
+		c.TLSConfig = c.TLSConfig.Clone()
+	}
+	return c.TLSConfig
+}
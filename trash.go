@@ -0,0 +1,95 @@
+package yadloader
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TrashItem is a file or folder in the Trash of the user's disk. Its Path
+// is in the Trash, without the "trash:" the API prefixes it with.
+type TrashItem struct {
+	Resource
+	// OriginPath is where on the disk it was deleted from.
+	OriginPath string    `json:"origin_path"`
+	Deleted    time.Time `json:"deleted"`
+}
+
+// ListTrash returns the files and folders directly inside path of the Trash,
+// all of it for "" or "/", without descending into subfolders.
+func (c *YaDiskClient) ListTrash(ctx context.Context, path string) ([]TrashItem, error) {
+	if c.config.Token == "" {
+		return nil, ErrTokenRequired
+	}
+	var items []TrashItem
+	for offset := 0; ; offset += c.config.Limit {
+		params := map[string]string{
+			"path":   trashPath(path),
+			"limit":  strconv.Itoa(c.config.Limit),
+			"offset": strconv.Itoa(offset),
+			"fields": listFields("origin_path", "deleted"),
+		}
+		resp, err := c.request(ctx, c.apiURL()+"/trash/resources?"+c.makeParams(params))
+		if err != nil {
+			return nil, err
+		}
+		var r response
+		if err := json.Unmarshal(resp, &r); err != nil {
+			return nil, err
+		}
+		if r.Type == FILE {
+			return []TrashItem{newTrashItem(r)}, nil
+		}
+		if r.Embedded == nil || len(r.Embedded.Items) == 0 {
+			break
+		}
+		for _, i := range r.Embedded.Items {
+			items = append(items, newTrashItem(i))
+		}
+		if len(items) >= r.Embedded.Total {
+			break
+		}
+	}
+	return items, nil
+}
+
+// RestoreTrash puts path of the Trash back where it was deleted from,
+// named name unless that is empty, replacing what is there with
+// overwrite. It waits until the server is done with a folder.
+func (c *YaDiskClient) RestoreTrash(ctx context.Context, path, name string, overwrite bool) error {
+	if c.config.Token == "" {
+		return ErrTokenRequired
+	}
+	params := map[string]string{"path": trashPath(path), "overwrite": strconv.FormatBool(overwrite)}
+	if name != "" {
+		params["name"] = name
+	}
+	return c.operation(ctx, "PUT", c.apiURL()+"/trash/resources/restore?"+c.makeParams(params))
+}
+
+// EmptyTrash deletes path of the Trash for good, or everything in it when
+// path is empty, waiting until that is done.
+func (c *YaDiskClient) EmptyTrash(ctx context.Context, path string) error {
+	if c.config.Token == "" {
+		return ErrTokenRequired
+	}
+	u := c.apiURL() + "/trash/resources"
+	if path != "" {
+		u += "?" + c.makeParams(map[string]string{"path": trashPath(path)})
+	}
+	return c.operation(ctx, "DELETE", u)
+}
+
+func newTrashItem(i response) TrashItem {
+	t := TrashItem{Resource: newResource(i), OriginPath: diskPath(i.OriginPath), Deleted: i.Deleted}
+	t.Path = strings.TrimPrefix(t.Path, "trash:")
+	return t
+}
+
+// trashPath returns p of the Trash the way the API takes it.
+func trashPath(p string) string {
+	return "trash:" + path.Join("/", strings.TrimPrefix(p, "trash:"))
+}
@@ -0,0 +1,133 @@
+package yadloader
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+type SortField string
+
+const (
+	SortName     SortField = "name"
+	SortPath     SortField = "path"
+	SortSize     SortField = "size"
+	SortCreated  SortField = "created"
+	SortModified SortField = "modified"
+)
+
+// ParseSort parses the API notation for sorting: a field name, prefixed
+// with "-" for reverse order.
+func ParseSort(s string) (SortField, bool, error) {
+	field, reverse := strings.CutPrefix(s, "-")
+	switch f := SortField(field); f {
+	case SortName, SortPath, SortSize, SortCreated, SortModified:
+		return f, reverse, nil
+	}
+	return "", false, fmt.Errorf("unknown sort field %q", field)
+}
+
+type TreeOption func(*treeOptions)
+
+type treeOptions struct {
+	callback   GetTreeCallback
+	sort       SortField
+	reverse    bool
+	maxDepth   int
+	emit       func(DiskFile)
+	folders    func(DiskFile)
+	unfiltered bool
+}
+
+func newTreeOptions(opts []TreeOption) treeOptions {
+	var o treeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+// WithCallback reports the running file count and size while listing.
+func WithCallback(cb GetTreeCallback) TreeOption {
+	return func(o *treeOptions) {
+		o.callback = cb
+	}
+}
+
+// WithSort orders the listing. Folders are listed in parallel, so the
+// collected files are sorted again once traversal ends.
+func WithSort(field SortField, reverse bool) TreeOption {
+	return func(o *treeOptions) {
+		o.sort = field
+		o.reverse = reverse
+	}
+}
+
+// WithMaxDepth limits how deep GetTree descends: 1 lists only the files
+// directly inside the path, 2 also those of its subfolders and so on. Zero
+// means no limit.
+func WithMaxDepth(depth int) TreeOption {
+	return func(o *treeOptions) {
+		o.maxDepth = depth
+	}
+}
+
+// WithFolders passes every folder the listing descends into to fn, for
+// MakeFolders and SetFolderTimes. The Filter does not apply to folders.
+func WithFolders(fn func(folder DiskFile)) TreeOption {
+	return func(o *treeOptions) {
+		o.folders = fn
+	}
+}
+
+// Unfiltered lists every file, leaving out the Filter of the client, for
+// listings filtered later.
+func Unfiltered() TreeOption {
+	return func(o *treeOptions) {
+		o.unfiltered = true
+	}
+}
+
+// withEmit passes every file to fn as soon as it is found.
+func withEmit(fn func(DiskFile)) TreeOption {
+	return func(o *treeOptions) {
+		o.emit = fn
+	}
+}
+
+func (o treeOptions) sortParam() string {
+	if o.sort == "" {
+		return ""
+	}
+	if o.reverse {
+		return "-" + string(o.sort)
+	}
+	return string(o.sort)
+}
+
+func (o treeOptions) sortFiles(files []DiskFile) {
+	if o.sort == "" {
+		return
+	}
+	slices.SortStableFunc(files, func(a, b DiskFile) int {
+		var r int
+		switch o.sort {
+		case SortName:
+			r = cmp.Compare(a.Name, b.Name)
+		case SortSize:
+			r = cmp.Compare(a.Size, b.Size)
+		case SortCreated:
+			r = a.Created.Compare(b.Created)
+		case SortModified:
+			r = a.Modified.Compare(b.Modified)
+		}
+		if r == 0 {
+			r = cmp.Compare(a.Path, b.Path)
+		}
+		if o.reverse {
+			r = -r
+		}
+		return r
+	})
+}
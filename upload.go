@@ -0,0 +1,282 @@
+package yadloader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// Upload sends these events with File the file on the disk and Dest the
+// local one it is uploaded from.
+const (
+	EventUploadStarted  EventType = "upload_started"
+	EventUploadFinished EventType = "upload_finished"
+	EventUploadFailed   EventType = "upload_failed"
+	EventUploadSkipped  EventType = "upload_skipped"
+)
+
+// UploadReport summarizes an Upload.
+type UploadReport struct {
+	Files    int           `json:"files"`
+	Uploaded int           `json:"uploaded"`
+	Skipped  int           `json:"skipped"`
+	Failed   int           `json:"failed"`
+	Bytes    int64         `json:"bytes"`
+	Started  time.Time     `json:"started"`
+	Duration time.Duration `json:"-"`
+	// Failures lists every failed file when Config.ErrorPolicy is Collect.
+	Failures []*FileError `json:"failures,omitempty"`
+}
+
+func (r UploadReport) MarshalJSON() ([]byte, error) {
+	type report UploadReport
+	return json.Marshal(struct {
+		report
+		DurationSeconds float64 `json:"duration_seconds"`
+	}{report(r), r.Duration.Seconds()})
+}
+
+// upload is a local file and where it goes on the disk.
+type upload struct {
+	local  string
+	remote string
+	size   int64
+}
+
+// Upload copies localPath to remotePath of the user's disk. A file is
+// uploaded as remotePath, or into it when remotePath ends with a slash; a
+// folder is uploaded with everything in it, Config.Concurrency files at a
+// time, creating the folders on the disk that files go into. As with
+// LocalFiles, part files and the state and lock files of downloads are left
+// out. Config.Overwrite decides about files that exist on the disk, and
+// Config.ErrorPolicy about failed files; with Collect the error joins the
+// Failures.
+func (c *YaDiskClient) Upload(ctx context.Context, localPath, remotePath string, opts ...Option) (report UploadReport, err error) {
+	c = c.with(opts)
+	ctx, cancel := c.withDeadline(ctx)
+	defer cancel()
+	report = UploadReport{Started: time.Now()}
+	defer func() {
+		report.Duration = time.Since(report.Started)
+	}()
+	if c.config.Token == "" {
+		return report, ErrTokenRequired
+	}
+
+	info, err := os.Stat(longPath(localPath))
+	if err != nil {
+		return report, err
+	}
+	remotePath = strings.TrimPrefix(remotePath, "disk:")
+	into := remotePath == "" || strings.HasSuffix(remotePath, "/")
+	remotePath = path.Join("/", remotePath)
+	var (
+		uploads []upload
+		folders []string
+	)
+	if !info.IsDir() {
+		if into {
+			remotePath = path.Join(remotePath, filepath.Base(localPath))
+		}
+		uploads = append(uploads, upload{local: localPath, remote: remotePath, size: info.Size()})
+	} else {
+		files, err := LocalFiles(localPath)
+		if err != nil {
+			return report, err
+		}
+		seen := map[string]bool{"/": true}
+		for _, f := range files {
+			remote := path.Join(remotePath, f.Path)
+			for dir := path.Dir(remote); !seen[dir]; dir = path.Dir(dir) {
+				seen[dir] = true
+				folders = append(folders, dir)
+			}
+			uploads = append(uploads, upload{local: filepath.Join(localPath, filepath.FromSlash(f.Path)), remote: remote, size: f.Size})
+		}
+		// Parents sort before their folders.
+		slices.Sort(folders)
+	}
+	report.Files = len(uploads)
+
+	for _, dir := range folders {
+		if err := c.mkdir(ctx, dir); err != nil {
+			return report, err
+		}
+	}
+	if err := c.uploadFiles(ctx, uploads, &report); err != nil {
+		return report, err
+	}
+	if err := ctx.Err(); err != nil {
+		return report, err
+	}
+	errs := make([]error, len(report.Failures))
+	for i, f := range report.Failures {
+		errs[i] = f
+	}
+	return report, errors.Join(errs...)
+}
+
+// mkdir creates the folder p of the disk unless it exists.
+func (c *YaDiskClient) mkdir(ctx context.Context, p string) error {
+	_, err := c.call(ctx, "PUT", c.apiURL()+"/resources?"+c.makeParams(map[string]string{"path": p}))
+	var e *APIError
+	if errors.As(err, &e) && e.StatusCode == http.StatusConflict && e.Code == "DiskPathPointsToExistentDirectoryError" {
+		return nil
+	}
+	return err
+}
+
+func (c *YaDiskClient) uploadFiles(ctx context.Context, uploads []upload, report *UploadReport) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	notify := func(e Event) {
+		if c.config.Progress != nil {
+			c.config.Progress(e)
+		}
+	}
+
+	jobs := make(chan upload)
+	for range clamp(c.config.Concurrency, MaxConcurrency) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for u := range jobs {
+				file := DiskFile{Name: path.Base(u.remote), Path: u.remote, Size: u.size}
+				mu.Lock()
+				notify(Event{Type: EventUploadStarted, File: file, Dest: u.local})
+				mu.Unlock()
+
+				started := time.Now()
+				remote, skip, err := c.uploadFile(ctx, u)
+				file.Name, file.Path = path.Base(remote), remote
+				e := Event{Type: EventUploadFinished, File: file, Dest: u.local, Bytes: u.size, Elapsed: time.Since(started)}
+				mu.Lock()
+				switch {
+				case skip:
+					e.Type, e.Bytes = EventUploadSkipped, 0
+					report.Skipped++
+				case err != nil:
+					e.Type, e.Bytes, e.Err = EventUploadFailed, 0, err
+					fe := &FileError{File: file, Dest: u.local, Err: err}
+					switch {
+					case errors.Is(err, ErrRetryBudget) && c.config.ErrorPolicy == Collect:
+						// The files left would fail the same way.
+						report.Failures = append(report.Failures, fe)
+						report.Failed++
+						if firstErr == nil {
+							firstErr = fe
+							cancel()
+						}
+					case c.config.ErrorPolicy == Collect:
+						if ctx.Err() == nil || !errors.Is(err, context.Canceled) {
+							report.Failures = append(report.Failures, fe)
+							report.Failed++
+						}
+					case firstErr == nil:
+						report.Failed++
+						firstErr = fe
+						cancel()
+					}
+				default:
+					report.Uploaded++
+					report.Bytes += u.size
+				}
+				notify(e)
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for _, u := range uploads {
+		select {
+		case jobs <- u:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	return firstErr
+}
+
+// uploadFile asks for the upload link of u and sends the file there. It
+// returns where the file went, which differs from u.remote with
+// OverwriteRenameNew, or skip with OverwriteSkip when the file exists.
+func (c *YaDiskClient) uploadFile(ctx context.Context, u upload) (remote string, skip bool, err error) {
+	if timeout := c.config.PerFileTimeout; timeout > 0 {
+		parent := ctx
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+		defer func() {
+			if err != nil && parent.Err() == nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				err = fmt.Errorf("%w after %s", ErrFileTimeout, timeout)
+			}
+		}()
+	}
+	remote = u.remote
+	for i := 1; ; i++ {
+		params := map[string]string{"path": remote, "overwrite": fmt.Sprint(c.config.Overwrite == OverwriteAlways)}
+		body, err := c.request(ctx, c.apiURL()+"/resources/upload?"+c.makeParams(params))
+		var e *APIError
+		if errors.As(err, &e) && e.StatusCode == http.StatusConflict && e.Code == "DiskResourceAlreadyExistsError" {
+			switch c.config.Overwrite {
+			case OverwriteSkip:
+				return remote, true, nil
+			case OverwriteRenameNew:
+				ext := path.Ext(u.remote)
+				remote = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(u.remote, ext), i, ext)
+				continue
+			case OverwriteError:
+				return remote, false, fmt.Errorf("%s: %w", remote, ErrFileExists)
+			}
+		}
+		if err != nil {
+			return remote, false, err
+		}
+		var link struct {
+			Href string `json:"href"`
+		}
+		if err := json.Unmarshal(body, &link); err != nil {
+			return remote, false, err
+		}
+		return remote, false, c.put(ctx, link.Href, u)
+	}
+}
+
+// put sends the file of u to the upload link href.
+func (c *YaDiskClient) put(ctx context.Context, href string, u upload) error {
+	f, err := os.Open(longPath(u.local))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	req, err := retryablehttp.NewRequestWithContext(ctx, "PUT", href, f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = u.size
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return checkResponse(resp)
+}
@@ -0,0 +1,67 @@
+package yadloader
+
+import (
+	"os"
+	"time"
+)
+
+// XattrPrefix starts the names of the extended attributes WithXattrs
+// writes, such as user.yadloader.md5.
+const XattrPrefix = "user.yadloader."
+
+// FileAttrs is what WithXattrs recorded about the source of a downloaded
+// file.
+type FileAttrs struct {
+	MD5        string
+	SHA256     string
+	ResourceID string
+	// Modified is the modification time of the local file when the
+	// attributes were written.
+	Modified time.Time
+}
+
+// writeFileAttrs records the checksums and resource ID of file on the local
+// file name.
+func writeFileAttrs(name string, file DiskFile) error {
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+	attrs := [][2]string{
+		{"md5", file.MD5},
+		{"sha256", file.SHA256},
+		{"resource_id", file.ResourceID},
+		{"mtime", info.ModTime().UTC().Format(time.RFC3339Nano)},
+	}
+	for _, a := range attrs {
+		if a[1] == "" {
+			continue
+		}
+		if err := setXattr(name, XattrPrefix+a[0], a[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadFileAttrs reads the attributes WithXattrs wrote on the file name. ok
+// is false when it has none, this system has no extended attributes, or the
+// file was modified after they were written.
+func ReadFileAttrs(name string) (attrs FileAttrs, ok bool) {
+	name = longPath(name)
+	info, err := os.Stat(name)
+	if err != nil {
+		return FileAttrs{}, false
+	}
+	mtime, err := getXattr(name, XattrPrefix+"mtime")
+	if err != nil {
+		return FileAttrs{}, false
+	}
+	if attrs.Modified, err = time.Parse(time.RFC3339Nano, mtime); err != nil || !attrs.Modified.Equal(info.ModTime()) {
+		return FileAttrs{}, false
+	}
+	attrs.MD5, _ = getXattr(name, XattrPrefix+"md5")
+	attrs.SHA256, _ = getXattr(name, XattrPrefix+"sha256")
+	attrs.ResourceID, _ = getXattr(name, XattrPrefix+"resource_id")
+	return attrs, true
+}
@@ -0,0 +1,13 @@
+//go:build !linux && !darwin
+
+package yadloader
+
+import "errors"
+
+func setXattr(string, string, string) error {
+	return errors.ErrUnsupported
+}
+
+func getXattr(string, string) (string, error) {
+	return "", errors.ErrUnsupported
+}
@@ -0,0 +1,24 @@
+//go:build linux || darwin
+
+package yadloader
+
+import "golang.org/x/sys/unix"
+
+func setXattr(name, attr, value string) error {
+	return unix.Setxattr(name, attr, []byte(value), 0)
+}
+
+func getXattr(name, attr string) (string, error) {
+	buf := make([]byte, 256)
+	for {
+		n, err := unix.Getxattr(name, attr, buf)
+		if err == unix.ERANGE {
+			buf = make([]byte, 2*len(buf))
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return string(buf[:n]), nil
+	}
+}
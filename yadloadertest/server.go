@@ -0,0 +1,799 @@
+// Package yadloadertest runs a fake Yandex Disk API for tests. It serves
+// public resources and the own disk with pagination, download links and
+// Range requests, takes uploads, new folders, copies, moves and deletes on
+// the own disk, saves public resources to it, keeps deleted resources in a
+// Trash to list, restore and empty, and can be told to fail requests.
+// Folders are copied, moved, saved, deleted and restored with an operation
+// that is in progress the first time it is asked for.
+package yadloadertest
+
+import (
+	"bytes"
+	"cmp"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/brandquad/yadloader-go"
+)
+
+// File is a file served by Server.
+type File struct {
+	// Path is the path in the share, e.g. "/photos/a.jpg".
+	Path      string
+	Content   []byte
+	MediaType string
+	// Created and Modified default to the time the file was added.
+	Created  time.Time
+	Modified time.Time
+	// Taken is served as the EXIF date when set.
+	Taken time.Time
+	// AntivirusStatus is served as antivirus_status when set, e.g.
+	// "infected".
+	AntivirusStatus string
+	// ServedName is the file name downloads are sent under, the name in
+	// Path by default.
+	ServedName string
+}
+
+type node struct {
+	dir      bool
+	name     string
+	path     string
+	file     File
+	children map[string]*node
+	// modified is the time of a folder, when it was added unless set with
+	// SetDirModified.
+	modified time.Time
+	// origin and deleted are set for resources at the top of the Trash.
+	origin  string
+	deleted time.Time
+}
+
+// trashKey is the share the Trash of the own disk is kept as.
+const trashKey = "trash:"
+
+type failure struct {
+	status int
+	times  int
+}
+
+// Server is a fake API. Point clients at it with Options.
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	shares   map[string]*node
+	token    string
+	failures map[string]*failure
+	requests int
+	// operations counts the status requests of each operation.
+	operations map[string]int
+	totalSpace int64
+}
+
+// TotalSpace is the size of the own disk unless set with SetTotalSpace.
+const TotalSpace = 10 << 30
+
+// NewServer starts a server without any shares; call Close when done.
+func NewServer() *Server {
+	s := &Server{
+		shares:     make(map[string]*node),
+		failures:   make(map[string]*failure),
+		operations: make(map[string]int),
+		totalSpace: TotalSpace,
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/disk/public/resources", s.handleResources)
+	mux.HandleFunc("GET /v1/disk/public/resources/download", s.handleDownloadLink)
+	mux.HandleFunc("GET /v1/disk/resources", s.handleResources)
+	mux.HandleFunc("GET /v1/disk/resources/download", s.handleDownloadLink)
+	mux.HandleFunc("GET /download", s.handleDownload)
+	mux.HandleFunc("PUT /v1/disk/resources", s.handleMkdir)
+	mux.HandleFunc("GET /v1/disk/resources/upload", s.handleUploadLink)
+	mux.HandleFunc("PUT /upload", s.handleUpload)
+	mux.HandleFunc("POST /v1/disk/resources/copy", s.handleTransfer)
+	mux.HandleFunc("POST /v1/disk/resources/move", s.handleTransfer)
+	mux.HandleFunc("DELETE /v1/disk/resources", s.handleDelete)
+	mux.HandleFunc("GET /v1/disk/operations/{id}", s.handleOperation)
+	mux.HandleFunc("POST /v1/disk/public/resources/save-to-disk", s.handleSaveToDisk)
+	mux.HandleFunc("GET /v1/disk", s.handleDisk)
+	mux.HandleFunc("GET /v1/disk/trash/resources", s.handleTrash)
+	mux.HandleFunc("PUT /v1/disk/trash/resources/restore", s.handleRestore)
+	mux.HandleFunc("DELETE /v1/disk/trash/resources", s.handleEmptyTrash)
+	s.Server = httptest.NewServer(mux)
+	return s
+}
+
+// Options point a yadloader client at the server.
+func (s *Server) Options() []yadloader.Option {
+	return []yadloader.Option{
+		yadloader.WithBaseURL(s.URL),
+		yadloader.WithHTTPClient(s.Client()),
+	}
+}
+
+// SetToken makes the own disk require this OAuth token.
+func (s *Server) SetToken(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.token = token
+}
+
+// SetTotalSpace sets the size of the own disk.
+func (s *Server) SetTotalSpace(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.totalSpace = n
+}
+
+// AddFile adds f to the public resource key, creating its folders. An empty
+// key adds it to the own disk.
+func (s *Server) AddFile(key string, f File) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC().Truncate(time.Second)
+	if f.Created.IsZero() {
+		f.Created = now
+	}
+	if f.Modified.IsZero() {
+		f.Modified = now
+	}
+	if f.MediaType == "" {
+		f.MediaType = "document"
+	}
+	f.Path = path.Clean("/" + f.Path)
+	parent := s.mkdir(key, path.Dir(f.Path))
+	name := path.Base(f.Path)
+	parent.children[name] = &node{name: name, path: f.Path, file: f}
+}
+
+// Content returns the content of the file p of the own disk, or false if
+// there is no such file.
+func (s *Server) Content(p string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := s.find("", path.Clean("/"+p))
+	if n == nil || n.dir {
+		return nil, false
+	}
+	return n.file.Content, true
+}
+
+// AddDir adds an empty folder.
+func (s *Server) AddDir(key, p string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mkdir(key, path.Clean("/"+p))
+}
+
+// SetDirModified sets the modification time of folder p, adding it if
+// needed.
+func (s *Server) SetDirModified(key, p string, t time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.mkdir(key, path.Clean("/"+p)).modified = t
+}
+
+// FailNext answers the next times requests for p, listing, download link
+// or content, with status.
+func (s *Server) FailNext(p string, status, times int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[path.Clean("/"+p)] = &failure{status: status, times: times}
+}
+
+// Requests returns how many requests the server has received.
+func (s *Server) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *Server) mkdir(key, p string) *node {
+	root, ok := s.shares[key]
+	if !ok {
+		root = &node{dir: true, name: "disk", path: "/", children: make(map[string]*node), modified: time.Now()}
+		s.shares[key] = root
+	}
+	n := root
+	for _, name := range strings.Split(strings.Trim(p, "/"), "/") {
+		if name == "" {
+			continue
+		}
+		child, ok := n.children[name]
+		if !ok {
+			child = &node{dir: true, name: name, path: path.Join(n.path, name), children: make(map[string]*node), modified: time.Now()}
+			n.children[name] = child
+		}
+		n = child
+	}
+	return n
+}
+
+// lookup finds the resource a request is about, writing the API error when
+// there is none.
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) (key string, n *node, ok bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+
+	public := strings.Contains(r.URL.Path, "/public/")
+	key = r.URL.Query().Get("public_key")
+	if public && key == "" {
+		writeError(w, http.StatusBadRequest, "FieldValidationError", "public_key is required")
+		return "", nil, false
+	}
+	if !public {
+		key = ""
+		if s.token != "" && r.Header.Get("Authorization") != "OAuth "+s.token {
+			writeError(w, http.StatusUnauthorized, "UnauthorizedError", "Unauthorized")
+			return "", nil, false
+		}
+	}
+
+	p := path.Clean("/" + strings.TrimPrefix(r.URL.Query().Get("path"), "disk:"))
+	if s.failed(w, p) {
+		return "", nil, false
+	}
+
+	n = s.find(key, p)
+	if n == nil {
+		writeError(w, http.StatusNotFound, "DiskNotFoundError", "Resource not found.")
+		return "", nil, false
+	}
+	return key, n, true
+}
+
+// own checks the token of a request about the own disk, or its Trash, and
+// returns its path. The caller holds s.mu.
+func (s *Server) own(w http.ResponseWriter, r *http.Request) (string, bool) {
+	s.requests++
+	if s.token != "" && r.Header.Get("Authorization") != "OAuth "+s.token {
+		writeError(w, http.StatusUnauthorized, "UnauthorizedError", "Unauthorized")
+		return "", false
+	}
+	p := r.URL.Query().Get("path")
+	p = path.Clean("/" + strings.TrimPrefix(strings.TrimPrefix(p, "disk:"), trashKey))
+	return p, !s.failed(w, p)
+}
+
+// find returns the resource p of the share key, or nil. The caller holds
+// s.mu.
+func (s *Server) find(key, p string) *node {
+	n := s.shares[key]
+	for _, name := range strings.Split(strings.Trim(p, "/"), "/") {
+		if n == nil || name == "" {
+			continue
+		}
+		n = n.children[name]
+	}
+	return n
+}
+
+// isDir reports whether p of the share key is a folder; the root of the
+// own disk always is. The caller holds s.mu.
+func (s *Server) isDir(key, p string) bool {
+	if key == "" && p == "/" {
+		return true
+	}
+	n := s.find(key, p)
+	return n != nil && n.dir
+}
+
+// failed applies an injected failure for p. The caller holds s.mu.
+func (s *Server) failed(w http.ResponseWriter, p string) bool {
+	f, ok := s.failures[p]
+	if !ok {
+		return false
+	}
+	if f.times--; f.times <= 0 {
+		delete(s.failures, p)
+	}
+	writeError(w, f.status, "InjectedError", http.StatusText(f.status))
+	return true
+}
+
+func (s *Server) handleResources(w http.ResponseWriter, r *http.Request) {
+	key, n, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeJSON(w, http.StatusOK, s.listing(key, n, r.URL.Query()))
+}
+
+// listing renders n with the page of its children q asks for. The caller
+// holds s.mu.
+func (s *Server) listing(key string, n *node, q url.Values) map[string]any {
+	limit := 20
+	if v, err := strconv.Atoi(q.Get("limit")); err == nil {
+		limit = v
+	}
+	offset, _ := strconv.Atoi(q.Get("offset"))
+	res := s.resource(key, n)
+	if n.dir {
+		children := make([]*node, 0, len(n.children))
+		for _, c := range n.children {
+			children = append(children, c)
+		}
+		sortNodes(children, q.Get("sort"))
+		items := []map[string]any{}
+		for i, c := range children {
+			if i >= offset && i < offset+limit {
+				items = append(items, s.resource(key, c))
+			}
+		}
+		res["_embedded"] = map[string]any{
+			"path": res["path"], "items": items, "total": len(children), "limit": limit, "offset": offset, "sort": q.Get("sort"),
+		}
+	}
+	return res
+}
+
+func (s *Server) handleDownloadLink(w http.ResponseWriter, r *http.Request) {
+	key, n, ok := s.lookup(w, r)
+	if !ok {
+		return
+	}
+	if n.dir {
+		writeError(w, http.StatusBadRequest, "DiskNotAFileError", "Resource is a folder.")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"href": s.downloadURL(key, n), "method": "GET", "templated": false})
+}
+
+func (s *Server) handleDownload(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	s.mu.Lock()
+	s.requests++
+	p := path.Clean("/" + q.Get("path"))
+	if s.failed(w, p) {
+		s.mu.Unlock()
+		return
+	}
+	n := s.shares[q.Get("key")]
+	for _, name := range strings.Split(strings.Trim(p, "/"), "/") {
+		if n != nil && name != "" {
+			n = n.children[name]
+		}
+	}
+	s.mu.Unlock()
+
+	if n == nil || n.dir {
+		http.NotFound(w, r)
+		return
+	}
+	served := n.name
+	if n.file.ServedName != "" {
+		served = n.file.ServedName
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": served}))
+	http.ServeContent(w, r, n.name, n.file.Modified, bytes.NewReader(n.file.Content))
+}
+
+func (s *Server) handleMkdir(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.own(w, r)
+	if !ok {
+		return
+	}
+	switch n := s.find("", p); {
+	case s.isDir("", p):
+		writeError(w, http.StatusConflict, "DiskPathPointsToExistentDirectoryError", "Specified path points to existent directory.")
+		return
+	case n != nil:
+		writeError(w, http.StatusConflict, "DiskResourceAlreadyExistsError", "Resource already exists.")
+		return
+	case !s.isDir("", path.Dir(p)):
+		writeError(w, http.StatusConflict, "DiskPathDoesntExistsError", "Specified path doesn't exist.")
+		return
+	}
+	s.mkdir("", p)
+	writeJSON(w, http.StatusCreated, map[string]any{"href": s.URL + "/v1/disk/resources?path=" + url.QueryEscape("disk:"+p), "method": "GET", "templated": false})
+}
+
+func (s *Server) handleUploadLink(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.own(w, r)
+	if !ok {
+		return
+	}
+	if !s.isDir("", path.Dir(p)) {
+		writeError(w, http.StatusConflict, "DiskPathDoesntExistsError", "Specified path doesn't exist.")
+		return
+	}
+	if n := s.find("", p); n != nil && (n.dir || r.URL.Query().Get("overwrite") != "true") {
+		writeError(w, http.StatusConflict, "DiskResourceAlreadyExistsError", "Resource already exists.")
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"href": s.URL + "/upload?path=" + url.QueryEscape(p), "method": "PUT", "templated": false})
+}
+
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p := path.Clean("/" + r.URL.Query().Get("path"))
+	s.mu.Lock()
+	s.requests++
+	failed := s.failed(w, p)
+	s.mu.Unlock()
+	if failed {
+		return
+	}
+	s.AddFile("", File{Path: p, Content: body})
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) handleTransfer(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	to, ok := s.own(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	from := path.Clean("/" + strings.TrimPrefix(q.Get("from"), "disk:"))
+	n := s.find("", from)
+	switch {
+	case n == nil:
+		writeError(w, http.StatusNotFound, "DiskNotFoundError", "Resource not found.")
+		return
+	case !s.isDir("", path.Dir(to)):
+		writeError(w, http.StatusConflict, "DiskPathDoesntExistsError", "Specified path doesn't exist.")
+		return
+	case s.find("", to) != nil && q.Get("overwrite") != "true":
+		writeError(w, http.StatusConflict, "DiskResourceAlreadyExistsError", "Resource already exists.")
+		return
+	case to == from || strings.HasPrefix(to, from+"/"):
+		writeError(w, http.StatusConflict, "DiskResourceAlreadyExistsError", "Cannot copy or move a resource into itself.")
+		return
+	}
+	if strings.HasSuffix(r.URL.Path, "/move") {
+		delete(s.find("", path.Dir(from)).children, n.name)
+	}
+	parent := s.find("", path.Dir(to))
+	if parent == nil {
+		parent = s.mkdir("", "/")
+	}
+	c := clone(n, to)
+	parent.children[c.name] = c
+	if n.dir {
+		s.startOperation(w)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"href": s.URL + "/v1/disk/resources?path=" + url.QueryEscape("disk:"+to), "method": "GET", "templated": false})
+}
+
+func (s *Server) handleDelete(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.own(w, r)
+	if !ok {
+		return
+	}
+	n := s.find("", p)
+	if n == nil || p == "/" {
+		writeError(w, http.StatusNotFound, "DiskNotFoundError", "Resource not found.")
+		return
+	}
+	delete(s.find("", path.Dir(p)).children, n.name)
+	if r.URL.Query().Get("permanently") != "true" {
+		s.toTrash(n)
+	}
+	if n.dir {
+		s.startOperation(w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// toTrash puts n, deleted from the own disk, at the top of the Trash, with
+// a number added to its name if another resource there has it. The caller
+// holds s.mu.
+func (s *Server) toTrash(n *node) {
+	trash := s.mkdir(trashKey, "/")
+	name := n.name
+	for i := 1; trash.children[name] != nil; i++ {
+		ext := path.Ext(n.name)
+		name = fmt.Sprintf("%s_%d%s", strings.TrimSuffix(n.name, ext), i, ext)
+	}
+	c := clone(n, "/"+name)
+	c.origin, c.deleted = n.path, time.Now().UTC().Truncate(time.Second)
+	trash.children[name] = c
+}
+
+func (s *Server) handleTrash(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.own(w, r)
+	if !ok {
+		return
+	}
+	s.mkdir(trashKey, "/")
+	n := s.find(trashKey, p)
+	if n == nil {
+		writeError(w, http.StatusNotFound, "DiskNotFoundError", "Resource not found.")
+		return
+	}
+	writeJSON(w, http.StatusOK, s.listing(trashKey, n, r.URL.Query()))
+}
+
+func (s *Server) handleRestore(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.own(w, r)
+	if !ok {
+		return
+	}
+	q := r.URL.Query()
+	n := s.find(trashKey, p)
+	if n == nil || n.origin == "" {
+		writeError(w, http.StatusNotFound, "DiskNotFoundError", "Resource not found.")
+		return
+	}
+	to := n.origin
+	if name := q.Get("name"); name != "" {
+		to = path.Join(path.Dir(to), name)
+	}
+	if s.find("", to) != nil && q.Get("overwrite") != "true" {
+		writeError(w, http.StatusConflict, "DiskResourceAlreadyExistsError", "Resource already exists.")
+		return
+	}
+	delete(s.find(trashKey, "/").children, n.name)
+	c := clone(n, to)
+	c.origin, c.deleted = "", time.Time{}
+	s.mkdir("", path.Dir(to)).children[c.name] = c
+	if n.dir {
+		s.startOperation(w)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"href": s.URL + "/v1/disk/resources?path=" + url.QueryEscape("disk:"+to), "method": "GET", "templated": false})
+}
+
+func (s *Server) handleEmptyTrash(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.own(w, r)
+	if !ok {
+		return
+	}
+	trash := s.mkdir(trashKey, "/")
+	if p == "/" {
+		empty := len(trash.children) == 0
+		trash.children = make(map[string]*node)
+		if !empty {
+			s.startOperation(w)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	n := s.find(trashKey, p)
+	if n == nil {
+		writeError(w, http.StatusNotFound, "DiskNotFoundError", "Resource not found.")
+		return
+	}
+	delete(s.find(trashKey, path.Dir(p)).children, n.name)
+	if n.dir {
+		s.startOperation(w)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// SaveFolder is where public resources are saved to by default. It is
+// created by the first save.
+const SaveFolder = "/Downloads"
+
+func (s *Server) handleDisk(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.own(w, r); !ok {
+		return
+	}
+	used, trash := size(s.shares[""]), size(s.shares[trashKey])
+	writeJSON(w, http.StatusOK, map[string]any{
+		"total_space":    s.totalSpace,
+		"used_space":     used + trash,
+		"trash_size":     trash,
+		"max_file_size":  1 << 30,
+		"is_paid":        false,
+		"system_folders": map[string]string{"downloads": "disk:" + SaveFolder + "/"},
+		"user":           map[string]string{"login": "test", "display_name": "Test"},
+	})
+}
+
+// size adds up the files under n, which may be nil.
+func size(n *node) int64 {
+	if n == nil {
+		return 0
+	}
+	if !n.dir {
+		return int64(len(n.file.Content))
+	}
+	var total int64
+	for _, c := range n.children {
+		total += size(c)
+	}
+	return total
+}
+
+func (s *Server) handleSaveToDisk(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.own(w, r); !ok {
+		return
+	}
+	q := r.URL.Query()
+	n := s.find(q.Get("public_key"), path.Clean("/"+q.Get("path")))
+	if n == nil || s.shares[q.Get("public_key")] == nil {
+		writeError(w, http.StatusNotFound, "DiskNotFoundError", "Resource not found.")
+		return
+	}
+	dir := SaveFolder
+	if q.Has("save_path") {
+		dir = path.Clean("/" + strings.TrimPrefix(q.Get("save_path"), "disk:"))
+		if dir != SaveFolder && !s.isDir("", dir) {
+			writeError(w, http.StatusConflict, "DiskPathDoesntExistsError", "Specified path doesn't exist.")
+			return
+		}
+	}
+	name := cmp.Or(q.Get("name"), n.name)
+	to := path.Join(dir, name)
+	if s.find("", to) != nil {
+		writeError(w, http.StatusConflict, "DiskResourceAlreadyExistsError", "Resource already exists.")
+		return
+	}
+	s.mkdir("", dir).children[name] = clone(n, to)
+	if n.dir {
+		s.startOperation(w)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]any{"href": s.URL + "/v1/disk/resources?path=" + url.QueryEscape("disk:"+to), "method": "GET", "templated": false})
+}
+
+// startOperation answers with a new operation. The caller holds s.mu.
+func (s *Server) startOperation(w http.ResponseWriter) {
+	id := strconv.Itoa(len(s.operations) + 1)
+	s.operations[id] = 0
+	writeJSON(w, http.StatusAccepted, map[string]any{"href": s.URL + "/v1/disk/operations/" + id, "method": "GET", "templated": false})
+}
+
+func (s *Server) handleOperation(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.own(w, r); !ok {
+		return
+	}
+	id := r.PathValue("id")
+	polls, ok := s.operations[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "DiskNotFoundError", "Operation not found.")
+		return
+	}
+	s.operations[id] = polls + 1
+	status := "success"
+	if polls == 0 {
+		status = "in-progress"
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": status})
+}
+
+// clone copies n to the path p.
+func clone(n *node, p string) *node {
+	c := *n
+	c.name, c.path = path.Base(p), p
+	c.file.Path = p
+	if n.dir {
+		c.children = make(map[string]*node, len(n.children))
+		for name, child := range n.children {
+			c.children[name] = clone(child, path.Join(p, name))
+		}
+	}
+	return &c
+}
+
+func (s *Server) downloadURL(key string, n *node) string {
+	return fmt.Sprintf("%s/download?key=%s&path=%s", s.URL, url.QueryEscape(key), url.QueryEscape(n.path))
+}
+
+// resource renders n the way the API does. The caller holds s.mu.
+func (s *Server) resource(key string, n *node) map[string]any {
+	p := n.path
+	switch key {
+	case "":
+		p = "disk:" + p
+	case trashKey:
+		p = trashKey + p
+	}
+	res := map[string]any{
+		"name":        n.name,
+		"path":        p,
+		"resource_id": strconv.Itoa(len(key)) + ":" + n.path,
+	}
+	switch {
+	case key == trashKey && n.origin != "":
+		res["origin_path"] = "disk:" + n.origin
+		res["deleted"] = n.deleted.Format(time.RFC3339)
+	case key != "" && key != trashKey:
+		res["public_key"] = key
+	}
+	if n.dir {
+		res["type"] = "dir"
+		res["created"] = n.modified.Format(time.RFC3339)
+		res["modified"] = n.modified.Format(time.RFC3339)
+		return res
+	}
+	f := n.file
+	md5sum := md5.Sum(f.Content)
+	shasum := sha256.Sum256(f.Content)
+	res["type"] = "file"
+	res["size"] = len(f.Content)
+	res["md5"] = hex.EncodeToString(md5sum[:])
+	res["sha256"] = hex.EncodeToString(shasum[:])
+	res["media_type"] = f.MediaType
+	res["created"] = f.Created.Format(time.RFC3339)
+	res["modified"] = f.Modified.Format(time.RFC3339)
+	if f.AntivirusStatus != "" {
+		res["antivirus_status"] = f.AntivirusStatus
+	}
+	if !f.Taken.IsZero() {
+		res["exif"] = map[string]any{"date_time": f.Taken.Format(time.RFC3339)}
+	}
+	res["file"] = s.downloadURL(key, n)
+	return res
+}
+
+func sortNodes(nodes []*node, field string) {
+	reverse := strings.HasPrefix(field, "-")
+	field = strings.TrimPrefix(field, "-")
+	slices.SortStableFunc(nodes, func(a, b *node) int {
+		var c int
+		switch field {
+		case "size":
+			c = len(a.file.Content) - len(b.file.Content)
+		case "created":
+			c = a.file.Created.Compare(b.file.Created)
+		case "modified":
+			c = a.file.Modified.Compare(b.file.Modified)
+		case "path":
+			c = strings.Compare(a.path, b.path)
+		default:
+			c = strings.Compare(a.name, b.name)
+		}
+		if reverse {
+			return -c
+		}
+		return c
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, map[string]string{"error": code, "message": message, "description": message})
+}
@@ -0,0 +1,94 @@
+package yadloadertest_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/brandquad/yadloader-go"
+	"github.com/brandquad/yadloader-go/yadloadertest"
+)
+
+const link = "https://disk.yandex.ru/d/abc123"
+
+func newShare(t *testing.T, files int) (*yadloadertest.Server, string) {
+	t.Helper()
+	key, _, err := yadloader.ParsePublicLink(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := yadloadertest.NewServer()
+	t.Cleanup(srv.Close)
+	for i := range files {
+		srv.AddFile(key, yadloadertest.File{
+			Path:    fmt.Sprintf("/docs/%d.txt", i),
+			Content: []byte(fmt.Sprintf("file %d", i)),
+		})
+	}
+	return srv, key
+}
+
+func newClient(srv *yadloadertest.Server, opts ...yadloader.Option) *yadloader.YaDiskClient {
+	opts = append([]yadloader.Option{yadloader.WithBaseURL(srv.URL), yadloader.WithRetries(3, time.Millisecond)}, opts...)
+	return yadloader.NewYaDiskClient(opts...)
+}
+
+func TestPagedListing(t *testing.T) {
+	srv, _ := newShare(t, 5)
+	files, err := newClient(srv, yadloader.WithLimit(2)).GetTree(context.Background(), link, "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 5 {
+		t.Fatalf("listed %d files, want 5", len(files))
+	}
+	// The root, then three pages of the folder.
+	if n := srv.Requests(); n != 4 {
+		t.Errorf("listing took %d requests, want 4", n)
+	}
+}
+
+func TestDownload(t *testing.T) {
+	srv, _ := newShare(t, 3)
+	dest := t.TempDir()
+	report, err := newClient(srv).DownloadTree(context.Background(), link, "/", dest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Downloaded != 3 {
+		t.Errorf("downloaded %d files, want 3", report.Downloaded)
+	}
+	for i := range 3 {
+		got, err := os.ReadFile(filepath.Join(dest, "docs", fmt.Sprintf("%d.txt", i)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := fmt.Sprintf("file %d", i); !bytes.Equal(got, []byte(want)) {
+			t.Errorf("file %d holds %q, want %q", i, got, want)
+		}
+	}
+}
+
+func TestInjectedError(t *testing.T) {
+	srv, _ := newShare(t, 2)
+	srv.FailNext("/docs/0.txt", http.StatusServiceUnavailable, 1)
+	srv.FailNext("/docs/1.txt", http.StatusForbidden, 10)
+
+	report, err := newClient(srv, yadloader.WithErrorPolicy(yadloader.Collect)).DownloadTree(context.Background(), link, "/", t.TempDir())
+	if err == nil {
+		t.Fatal("download of a forbidden file succeeded")
+	}
+	if report.Downloaded != 1 || len(report.Failures) != 1 {
+		t.Fatalf("downloaded %d, failed %d files, want 1 and 1", report.Downloaded, len(report.Failures))
+	}
+	var apiErr *yadloader.APIError
+	if f := report.Failures[0]; f.File.Path != "/docs/1.txt" || !errors.As(f.Err, &apiErr) || apiErr.StatusCode != http.StatusForbidden {
+		t.Errorf("failure %v, want 403 for /docs/1.txt", f)
+	}
+}